@@ -28,6 +28,11 @@ func (n *NetAddress) StringWithProto() string {
 	return fmt.Sprintf("http://%s:%d", n.Host, n.Port)
 }
 
+// StringWithSecureProto returns network address with HTTPS protocol.
+func (n *NetAddress) StringWithSecureProto() string {
+	return fmt.Sprintf("https://%s:%d", n.Host, n.Port)
+}
+
 // Set implements flag.Value.
 func (n *NetAddress) Set(s string) error {
 	n.Host, n.Port = defaultHost, defaultPort
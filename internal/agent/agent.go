@@ -4,6 +4,7 @@ package agent
 import (
 	"context"
 	"crypto/rsa"
+	"crypto/tls"
 	"flag"
 	"log"
 	"os"
@@ -14,13 +15,16 @@ import (
 
 	"github.com/caarlos0/env/v11"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/nekr0z/muhame/internal/addr"
 	confighelper "github.com/nekr0z/muhame/internal/config"
 	"github.com/nekr0z/muhame/internal/crypt"
 	"github.com/nekr0z/muhame/internal/grpcclient"
+	"github.com/nekr0z/muhame/internal/hash"
 	"github.com/nekr0z/muhame/internal/httpclient"
+	"github.com/nekr0z/muhame/internal/tlsconfig"
 	"github.com/nekr0z/muhame/pkg/proto"
 )
 
@@ -29,22 +33,35 @@ type envConfig struct {
 	ReportInterval int             `env:"REPORT_INTERVAL" json:"report_interval"`
 	PollInterval   int             `env:"POLL_INTERVAL" json:"poll_interval"`
 	Key            string          `env:"KEY" json:"key"`
+	KeyID          string          `env:"KEY_ID" json:"key_id"`
 	RateLimit      int             `env:"RATE_LIMIT" json:"rate_limit"`
 	CryptoKey      string          `env:"CRYPTO_KEY" json:"crypto_key"`
 	GRPC           bool            `env:"GRPC" json:"grpc"`
+	GRPCStream     bool            `env:"GRPC_STREAM" json:"grpc_stream"`
+	TLS            bool            `env:"TLS" json:"tls"`
+	TLSCA          string          `env:"TLS_CA" json:"tls_ca"`
+	TLSCert        string          `env:"TLS_CERT" json:"tls_cert"`
+	TLSKey         string          `env:"TLS_KEY" json:"tls_key"`
+	APIKey         string          `env:"API_KEY" json:"api_key"`
 }
 
 // Agent is the metric-sending agent.
 type Agent struct {
 	address        addr.NetAddress
 	useGRPC        bool
+	useStream      bool
+	useTLS         bool
 	reportInterval time.Duration
 	pollInterval   time.Duration
 	signKey        string
+	signKeyID      string
+	apiKey         string
 	workers        int
 
 	pubKey *rsa.PublicKey
+	tls    tlsconfig.Files
 
+	stream *streamer
 	q      *queue
 	workCh chan struct{}
 	wg     *sync.WaitGroup
@@ -76,9 +93,16 @@ func New() Agent {
 	flags.IntVar(&cfg.ReportInterval, "r", cfg.ReportInterval, "seconds between sending consecutive reports")
 	flags.IntVar(&cfg.PollInterval, "p", cfg.PollInterval, "seconds between acquiring metrics")
 	flags.StringVar(&cfg.Key, "k", cfg.Key, "signing key")
+	flags.StringVar(&cfg.KeyID, "key-id", cfg.KeyID, "ID of the signing key on the server, switches to HMAC signatures")
 	flags.IntVar(&cfg.RateLimit, "l", cfg.RateLimit, "simultaneous requests")
 	flags.StringVar(&cfg.CryptoKey, "crypto-key", cfg.CryptoKey, "public key for message encryption")
 	flags.BoolVar(&cfg.GRPC, "g", cfg.GRPC, "use gRPC")
+	flags.BoolVar(&cfg.GRPCStream, "grpc-stream", cfg.GRPCStream, "use a long-lived gRPC stream, implies -g")
+	flags.BoolVar(&cfg.TLS, "tls", cfg.TLS, "connect to the server over TLS")
+	flags.StringVar(&cfg.TLSCA, "tls-ca", cfg.TLSCA, "CA file to verify the server certificate against instead of the system roots, implies -tls")
+	flags.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "client certificate file for mutual TLS, implies -tls")
+	flags.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "private key file for the client certificate")
+	flags.StringVar(&cfg.APIKey, "api-key", cfg.APIKey, "API key token to authenticate with")
 
 	flags.Parse(os.Args[1:])
 
@@ -89,16 +113,26 @@ func New() Agent {
 
 	a := Agent{
 		address:        cfg.Address,
-		useGRPC:        cfg.GRPC,
+		useGRPC:        cfg.GRPC || cfg.GRPCStream,
+		useStream:      cfg.GRPCStream,
 		reportInterval: time.Duration(cfg.ReportInterval) * time.Second,
 		pollInterval:   time.Duration(cfg.PollInterval) * time.Second,
 		signKey:        cfg.Key,
+		signKeyID:      cfg.KeyID,
+		apiKey:         cfg.APIKey,
 		workers:        cfg.RateLimit,
 		q:              &queue{},
 		workCh:         make(chan struct{}),
 		wg:             &sync.WaitGroup{},
+		tls: tlsconfig.Files{
+			Cert: cfg.TLSCert,
+			Key:  cfg.TLSKey,
+			CA:   cfg.TLSCA,
+		},
 	}
 
+	a.useTLS = cfg.TLS || a.tls.Enabled()
+
 	a.pubKey, err = crypt.LoadPublicKey(cfg.CryptoKey)
 	if err != nil {
 		a.pubKey = nil
@@ -110,13 +144,28 @@ func New() Agent {
 // Run starts the agent to collect all metrics and send them to the server.
 func (a Agent) Run(ctx context.Context) {
 	log.Printf("running and sending metrics to %s", a.address.String())
-	if a.signKey != "" {
+	switch {
+	case a.signKeyID != "":
+		log.Printf("using HMAC key %s to sign messages", a.signKeyID)
+	case a.signKey != "":
 		log.Printf("using key \"%s\" to sign messages", a.signKey)
 	}
 
 	ctx, cancel := context.WithCancel(ctx)
 
-	grpcClient, err := a.grpcClient(ctx)
+	var tlsConfig *tls.Config
+	if a.useTLS {
+		var err error
+		tlsConfig, err = a.tls.Client()
+		if err != nil {
+			log.Printf("failed to set up TLS: %s", err)
+			cancel()
+
+			return
+		}
+	}
+
+	grpcClient, err := a.grpcClient(ctx, tlsConfig)
 	if err != nil {
 		log.Printf("failed to create gRPC client: %s", err)
 		cancel()
@@ -124,9 +173,15 @@ func (a Agent) Run(ctx context.Context) {
 		return
 	}
 
+	if a.useStream {
+		a.stream = newStreamer(grpcClient)
+	}
+
+	httpClient := httpclient.New().WithKey(a.signKey).WithKeyID(a.signKeyID).WithCrypto(a.pubKey).WithTLS(tlsConfig).WithToken(a.apiKey)
+
 	a.wg.Add(a.workers)
 	for range a.workers {
-		go a.worker(ctx, grpcClient)
+		go a.worker(ctx, grpcClient, httpClient)
 	}
 
 	a.wg.Add(3)
@@ -149,17 +204,30 @@ func (a Agent) Run(ctx context.Context) {
 	log.Print("Done.")
 }
 
-func (a Agent) grpcClient(ctx context.Context) (proto.MetricsServiceClient, error) {
+func (a Agent) grpcClient(ctx context.Context, tlsConfig *tls.Config) (proto.MetricsServiceClient, error) {
 	if !a.useGRPC {
 		return nil, nil
 	}
 
+	signer := hash.Signer{ID: a.signKeyID, Key: a.signKey}
+
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
 	conn, err := grpc.NewClient(
 		a.address.String(),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
 		grpc.WithChainUnaryInterceptor(
 			grpcclient.EncryptInterceptor(a.pubKey),
-			grpcclient.SignatureInterceptor(a.signKey),
+			grpcclient.SignatureInterceptor(signer),
+			grpcclient.AuthInterceptor(a.apiKey),
+		),
+		grpc.WithChainStreamInterceptor(
+			grpcclient.EncryptStreamInterceptor(a.pubKey),
+			grpcclient.SignatureStreamInterceptor(signer),
+			grpcclient.AuthStreamInterceptor(a.apiKey),
 		),
 	)
 	if err != nil {
@@ -174,17 +242,26 @@ func (a Agent) grpcClient(ctx context.Context) (proto.MetricsServiceClient, erro
 	return proto.NewMetricsServiceClient(conn), nil
 }
 
-func (a Agent) worker(ctx context.Context, grpcClient proto.MetricsServiceClient) {
+func (a Agent) worker(ctx context.Context, grpcClient proto.MetricsServiceClient, httpClient httpclient.Client) {
 	defer a.wg.Done()
+
+	endpoint := a.address.StringWithProto()
+	if a.useTLS {
+		endpoint = a.address.StringWithSecureProto()
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-a.workCh:
-			if a.useGRPC {
+			switch {
+			case a.useStream:
+				a.q.sendMetricsStream(ctx, a.stream)
+			case a.useGRPC:
 				a.q.sendMetricsGRPC(ctx, grpcClient)
-			} else {
-				a.q.sendMetricsHTTP(httpclient.New().WithKey(a.signKey).WithCrypto(a.pubKey), a.address.StringWithProto())
+			default:
+				a.q.sendMetricsHTTP(httpClient, endpoint)
 			}
 		}
 	}
@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/nekr0z/muhame/internal/addr"
+	"github.com/nekr0z/muhame/internal/tlsconfig"
 )
 
 var testConfigFilename = filepath.Join("testdata", "config.json")
@@ -77,6 +78,58 @@ func TestNew(t *testing.T) {
 				signKey:        "flag-key",
 			},
 		},
+		{
+			name: "stream",
+			env:  []string{"GRPC_STREAM=true"},
+			want: Agent{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				reportInterval: 10,
+				pollInterval:   2,
+				workers:        1,
+				useGRPC:        true,
+				useStream:      true,
+			},
+		},
+		{
+			name: "tls and api key",
+			args: []string{"-tls-ca", "ca.pem", "-tls-cert", "client.pem", "-api-key", "flag-token"},
+			env:  []string{"TLS_KEY=client.key", "API_KEY=env-token"},
+			want: Agent{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				reportInterval: 10,
+				pollInterval:   2,
+				workers:        1,
+				useTLS:         true,
+				apiKey:         "env-token",
+				tls: tlsconfig.Files{
+					Cert: "client.pem",
+					Key:  "client.key",
+					CA:   "ca.pem",
+				},
+			},
+		},
+		{
+			name: "key id",
+			args: []string{"-k", "newkey", "-key-id", "flag-id"},
+			env:  []string{"KEY_ID=2024-07"},
+			want: Agent{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				reportInterval: 10,
+				pollInterval:   2,
+				workers:        1,
+				signKey:        "newkey",
+				signKeyID:      "2024-07",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -101,6 +154,12 @@ func TestNew(t *testing.T) {
 			assert.Equal(t, tt.want.workers, got.workers)
 			assert.Equal(t, tt.want.pubKey, got.pubKey)
 			assert.Equal(t, tt.want.signKey, got.signKey)
+			assert.Equal(t, tt.want.signKeyID, got.signKeyID)
+			assert.Equal(t, tt.want.useGRPC, got.useGRPC)
+			assert.Equal(t, tt.want.useStream, got.useStream)
+			assert.Equal(t, tt.want.useTLS, got.useTLS)
+			assert.Equal(t, tt.want.tls, got.tls)
+			assert.Equal(t, tt.want.apiKey, got.apiKey)
 		})
 	}
 }
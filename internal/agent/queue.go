@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -73,6 +74,16 @@ func (q *queue) sendMetricsGRPC(ctx context.Context, c proto.MetricsServiceClien
 	sendAllGRPC(ctx, c, mm)
 }
 
+func (q *queue) sendMetricsStream(ctx context.Context, s *streamer) {
+	mm := q.popAll()
+
+	if len(mm) == 0 {
+		return
+	}
+
+	sendAllStream(ctx, s, mm)
+}
+
 func (q *queue) popAll() []queuedMetric {
 	mm := make([]queuedMetric, 0)
 
@@ -93,6 +104,22 @@ func sendAllHTTP(c httpclient.Client, addr string, mm []queuedMetric) {
 	}
 }
 
+func sendAllStream(ctx context.Context, s *streamer, mm []queuedMetric) {
+	pm := make([]*proto.Metric, len(mm))
+
+	for i, m := range mm {
+		pm[i] = queuedMetricToProto(m)
+	}
+
+	// A batch that may have been stored must not be stored twice, so it is
+	// only sent over the unary calls if it never made it over the stream.
+	if err := s.send(ctx, pm); !errors.Is(err, errNotDelivered) {
+		return
+	}
+
+	sendAllGRPC(ctx, s.c, mm)
+}
+
 func sendAllGRPC(ctx context.Context, c proto.MetricsServiceClient, mm []queuedMetric) {
 	pm := make([]*proto.Metric, len(mm))
 
@@ -241,6 +268,18 @@ func queuedMetricToProto(m queuedMetric) *proto.Metric {
 				},
 			},
 		}
+	case metrics.Histogram:
+		return &proto.Metric{
+			Name: m.name,
+			Value: &proto.Metric_Histogram{
+				Histogram: &proto.Histogram{
+					Bounds: v.Bounds,
+					Counts: v.Counts,
+					Sum:    v.Sum,
+					Count:  v.Count,
+				},
+			},
+		}
 	default:
 		return nil
 	}
@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+// errNotDelivered is returned when the batch could not be sent over the stream
+// at all, so it is safe to send it some other way.
+var errNotDelivered = errors.New("batch not delivered")
+
+// streamer sends the batches of metrics over a long-lived gRPC stream, one
+// batch at a time, waiting for each to be acknowledged. The stream is opened
+// on first use and reopened after it breaks.
+type streamer struct {
+	c proto.MetricsServiceClient
+
+	mu     sync.Mutex
+	stream proto.MetricsService_StreamClient
+	id     uint64
+}
+
+func newStreamer(c proto.MetricsServiceClient) *streamer {
+	return &streamer{c: c}
+}
+
+// send sends the batch and waits for the ack. The error wraps errNotDelivered
+// if the batch never made it to the server. Once it did, the server may have
+// stored it even if the ack never came, so the batch must not be sent again.
+func (s *streamer) send(ctx context.Context, pm []*proto.Metric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stream == nil {
+		stream, err := s.c.Stream(ctx)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errNotDelivered, err)
+		}
+
+		s.stream = stream
+	}
+
+	s.id++
+
+	err := s.stream.Send(&proto.StreamRequest{
+		Id: s.id,
+		Batch: &proto.BulkRequest{
+			Payload: &proto.BulkRequest_Metrics{
+				Metrics: &proto.Metrics{
+					Metrics: pm,
+				},
+			},
+		},
+	})
+	if err != nil {
+		s.stream = nil
+		return fmt.Errorf("%w: %w", errNotDelivered, err)
+	}
+
+	ack, err := s.stream.Recv()
+	if err != nil {
+		s.stream = nil
+		return err
+	}
+
+	if ack.GetId() != s.id {
+		s.stream = nil
+		return fmt.Errorf("got ack for batch %d instead of %d", ack.GetId(), s.id)
+	}
+
+	if ack.GetError() != "" {
+		return errors.New(ack.GetError())
+	}
+
+	return nil
+}
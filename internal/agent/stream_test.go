@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/nekr0z/muhame/internal/grpcserver"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+func TestSendStream(t *testing.T) {
+	ctx := context.Background()
+
+	st, err := storage.New(zap.NewNop().Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	proto.RegisterMetricsServiceServer(srv, grpcserver.New(st))
+
+	go func() {
+		assert.NoError(t, srv.Serve(lis))
+	}()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s := newStreamer(proto.NewMetricsServiceClient(conn))
+
+	for range 3 {
+		sendAllStream(ctx, s, []queuedMetric{
+			{name: "PollCount", val: metrics.Counter(2)},
+			{name: "Alloc", val: metrics.Gauge(1.5)},
+		})
+	}
+
+	assert.Equal(t, uint64(3), s.id)
+	assert.NotNil(t, s.stream)
+
+	m, err := st.Get(ctx, "counter", "PollCount", nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Counter(6), m)
+
+	m, err = st.Get(ctx, "gauge", "Alloc", nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(1.5), m)
+}
+
+func TestSendStream_Fallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		open     error
+		send     error
+		recv     error
+		ack      string
+		fallback bool
+	}{
+		{name: "ok"},
+		{name: "open failed", open: errors.New("unavailable"), fallback: true},
+		{name: "send failed", send: io.EOF, fallback: true},
+		{name: "recv failed", recv: errors.New("connection reset")},
+		{name: "not accepted", ack: "storage failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &fakeStreamClient{
+				open:   tt.open,
+				stream: &fakeStream{send: tt.send, recv: tt.recv, ack: tt.ack},
+			}
+
+			sendAllStream(context.Background(), newStreamer(c), []queuedMetric{
+				{name: "PollCount", val: metrics.Counter(2)},
+			})
+
+			assert.Equal(t, tt.fallback, c.bulk > 0)
+		})
+	}
+}
+
+type fakeStreamClient struct {
+	proto.MetricsServiceClient
+	open   error
+	stream *fakeStream
+	bulk   int
+}
+
+func (c *fakeStreamClient) Stream(context.Context, ...grpc.CallOption) (proto.MetricsService_StreamClient, error) {
+	if c.open != nil {
+		return nil, c.open
+	}
+
+	return c.stream, nil
+}
+
+func (c *fakeStreamClient) BulkUpdate(context.Context, *proto.BulkRequest, ...grpc.CallOption) (*emptypb.Empty, error) {
+	c.bulk++
+	return &emptypb.Empty{}, nil
+}
+
+type fakeStream struct {
+	proto.MetricsService_StreamClient
+	send error
+	recv error
+	ack  string
+	id   uint64
+}
+
+func (s *fakeStream) Send(req *proto.StreamRequest) error {
+	s.id = req.GetId()
+	return s.send
+}
+
+func (s *fakeStream) Recv() (*proto.StreamAck, error) {
+	if s.recv != nil {
+		return nil, s.recv
+	}
+
+	return &proto.StreamAck{Id: s.id, Error: s.ack}, nil
+}
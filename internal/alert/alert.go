@@ -0,0 +1,290 @@
+// Package alert implements threshold alerting on the stored metrics.
+package alert
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// DefaultInterval is used when no evaluation interval is set.
+const DefaultInterval = 15 * time.Second
+
+// resolvedRetention is how long resolved alerts are kept for display.
+const resolvedRetention = 15 * time.Minute
+
+// State is the state of an alert.
+type State string
+
+// Alert states.
+const (
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+// Alert is the state of a rule for a single series.
+type Alert struct {
+	Rule       string         `json:"rule"`
+	Type       string         `json:"type"`
+	Name       string         `json:"name"`
+	Labels     metrics.Labels `json:"labels,omitempty"`
+	State      State          `json:"state"`
+	Value      float64        `json:"value"`
+	ActiveAt   time.Time      `json:"active_at"`
+	FiredAt    *time.Time     `json:"fired_at,omitempty"`
+	ResolvedAt *time.Time     `json:"resolved_at,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. Values that JSON has no numbers for,
+// i.e. NaN and infinities, are encoded as strings.
+func (a Alert) MarshalJSON() ([]byte, error) {
+	type alert Alert
+
+	var v any = a.Value
+	if math.IsNaN(a.Value) || math.IsInf(a.Value, 0) {
+		v = strconv.FormatFloat(a.Value, 'g', -1, 64)
+	}
+
+	return json.Marshal(struct {
+		alert
+		Value any `json:"value"`
+	}{alert(a), v})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *Alert) UnmarshalJSON(b []byte) error {
+	type alert Alert
+
+	aux := struct {
+		*alert
+		Value json.RawMessage `json:"value"`
+	}{alert: (*alert)(a)}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	if len(aux.Value) == 0 {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(aux.Value, &s); err == nil {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("bad value %s", aux.Value)
+		}
+		a.Value = v
+		return nil
+	}
+
+	return json.Unmarshal(aux.Value, &a.Value)
+}
+
+// Storage is where the metrics to evaluate the rules against are read from.
+type Storage interface {
+	List(ctx context.Context, filter metrics.Labels) ([]metrics.Named, error)
+}
+
+// Engine periodically evaluates the rules against the storage and keeps track
+// of the alerts.
+type Engine struct {
+	log   *zap.SugaredLogger
+	st    Storage
+	rules []Rule
+
+	mu     sync.Mutex
+	alerts map[string]*Alert
+
+	stopChan, doneChan chan struct{}
+}
+
+// New returns a new Engine.
+func New(log *zap.SugaredLogger, st Storage, rules []Rule) *Engine {
+	return &Engine{
+		log:    log,
+		st:     st,
+		rules:  rules,
+		alerts: make(map[string]*Alert),
+	}
+}
+
+// Start starts evaluating the rules, right away and then every interval.
+func (e *Engine) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	e.stopChan = make(chan struct{})
+	e.doneChan = make(chan struct{})
+
+	evaluate := func() {
+		if err := e.Evaluate(context.Background(), time.Now()); err != nil {
+			e.log.Errorf("failed to evaluate alert rules: %s", err)
+		}
+	}
+
+	go func() {
+		defer close(e.doneChan)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		evaluate()
+
+		for {
+			select {
+			case <-e.stopChan:
+				return
+			case <-ticker.C:
+				evaluate()
+			}
+		}
+	}()
+}
+
+// Stop stops the evaluation and waits for it to finish.
+func (e *Engine) Stop() {
+	if e == nil || e.stopChan == nil {
+		return
+	}
+
+	close(e.stopChan)
+	<-e.doneChan
+}
+
+// Evaluate evaluates all the rules as of now.
+func (e *Engine) Evaluate(ctx context.Context, now time.Time) error {
+	type result struct {
+		rule Rule
+		mm   []metrics.Named
+	}
+
+	results := make([]result, 0, len(e.rules))
+
+	for _, r := range e.rules {
+		mm, err := e.st.List(ctx, r.Labels)
+		if err != nil {
+			return err
+		}
+
+		results = append(results, result{rule: r, mm: mm})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	seen := make(map[string]struct{})
+
+	for _, res := range results {
+		for _, m := range res.mm {
+			if m.Name != res.rule.Metric || m.Type() != res.rule.Type {
+				continue
+			}
+
+			v, ok := value(m.Metric)
+			if !ok || !res.rule.matches(v) {
+				continue
+			}
+
+			k := key(res.rule.Name, m.Labels)
+			seen[k] = struct{}{}
+			e.activate(k, res.rule, m, v, now)
+		}
+	}
+
+	for k, a := range e.alerts {
+		if _, ok := seen[k]; ok {
+			continue
+		}
+
+		switch a.State {
+		case StatePending:
+			delete(e.alerts, k)
+		case StateFiring:
+			a.State = StateResolved
+			a.ResolvedAt = &now
+			e.log.Infof("alert %s resolved for %s%s", a.Rule, a.Name, a.Labels.Pretty())
+		case StateResolved:
+			if now.Sub(*a.ResolvedAt) >= resolvedRetention {
+				delete(e.alerts, k)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *Engine) activate(k string, r Rule, m metrics.Named, v float64, now time.Time) {
+	a, ok := e.alerts[k]
+	if !ok || a.State == StateResolved {
+		a = &Alert{
+			Rule:     r.Name,
+			Type:     r.Type,
+			Name:     m.Name,
+			Labels:   m.Labels.Clone(),
+			State:    StatePending,
+			ActiveAt: now,
+		}
+		e.alerts[k] = a
+	}
+
+	a.Value = v
+
+	if a.State == StatePending && now.Sub(a.ActiveAt) >= time.Duration(r.For) {
+		a.State = StateFiring
+		a.FiredAt = &now
+		e.log.Infof("alert %s firing for %s%s", a.Rule, a.Name, a.Labels.Pretty())
+	}
+}
+
+// Alerts returns the current pending, firing and recently resolved alerts,
+// sorted by rule and series. A nil Engine has no alerts.
+func (e *Engine) Alerts() []Alert {
+	if e == nil {
+		return []Alert{}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	aa := make([]Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		aa = append(aa, *a)
+	}
+
+	slices.SortFunc(aa, func(a, b Alert) int {
+		if a.Rule != b.Rule {
+			return cmp.Compare(a.Rule, b.Rule)
+		}
+		return cmp.Compare(a.Labels.String(), b.Labels.String())
+	})
+
+	return aa
+}
+
+func value(m metrics.Metric) (float64, bool) {
+	switch v := m.(type) {
+	case metrics.Gauge:
+		return float64(v), true
+	case metrics.Counter:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func key(rule string, labels metrics.Labels) string {
+	return rule + "\x00" + labels.String()
+}
@@ -0,0 +1,109 @@
+package alert_test
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/alert"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+func TestEngine_Evaluate(t *testing.T) {
+	ctx := context.Background()
+	log := zap.NewNop().Sugar()
+
+	st, err := storage.New(log, storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	update := func(v float64, host string) {
+		t.Helper()
+		require.NoError(t, st.Update(ctx, metrics.Named{
+			Name:   "load",
+			Labels: metrics.Labels{"host": host},
+			Metric: metrics.Gauge(v),
+		}))
+	}
+
+	e := alert.New(log, st, []alert.Rule{{
+		Name:      "high_load",
+		Type:      "gauge",
+		Metric:    "load",
+		Op:        ">",
+		Threshold: 2,
+		For:       alert.Duration(time.Minute),
+	}})
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	update(1, "a")
+	update(3, "b")
+
+	require.NoError(t, e.Evaluate(ctx, start))
+
+	aa := e.Alerts()
+	require.Len(t, aa, 1)
+	assert.Equal(t, metrics.Labels{"host": "b"}, aa[0].Labels)
+	assert.Equal(t, alert.StatePending, aa[0].State)
+	assert.Equal(t, 3.0, aa[0].Value)
+
+	update(4, "b")
+	require.NoError(t, e.Evaluate(ctx, start.Add(time.Minute)))
+
+	aa = e.Alerts()
+	require.Len(t, aa, 1)
+	assert.Equal(t, alert.StateFiring, aa[0].State)
+	assert.Equal(t, 4.0, aa[0].Value)
+	assert.Equal(t, start, aa[0].ActiveAt)
+
+	update(1, "b")
+	update(5, "a")
+	require.NoError(t, e.Evaluate(ctx, start.Add(2*time.Minute)))
+
+	aa = e.Alerts()
+	require.Len(t, aa, 2)
+	assert.Equal(t, metrics.Labels{"host": "a"}, aa[0].Labels)
+	assert.Equal(t, alert.StatePending, aa[0].State)
+	assert.Equal(t, metrics.Labels{"host": "b"}, aa[1].Labels)
+	assert.Equal(t, alert.StateResolved, aa[1].State)
+
+	update(1, "a")
+	require.NoError(t, e.Evaluate(ctx, start.Add(time.Hour)))
+
+	assert.Empty(t, e.Alerts())
+}
+
+func TestEngine_Nil(t *testing.T) {
+	var e *alert.Engine
+
+	assert.Empty(t, e.Alerts())
+	assert.NotPanics(t, e.Stop)
+}
+
+func TestAlert_JSON(t *testing.T) {
+	for _, v := range []float64{1.5, math.NaN(), math.Inf(1), math.Inf(-1)} {
+		a := alert.Alert{Rule: "r", Name: "load", State: alert.StateFiring, Value: v}
+
+		b, err := json.Marshal(a)
+		require.NoError(t, err)
+
+		var got alert.Alert
+		require.NoError(t, json.Unmarshal(b, &got))
+
+		assert.Equal(t, a.Rule, got.Rule)
+		assert.Equal(t, a.State, got.State)
+
+		if math.IsNaN(v) {
+			assert.True(t, math.IsNaN(got.Value))
+		} else {
+			assert.Equal(t, v, got.Value)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// Rule is an alerting rule. It applies to every series of the type with the
+// metric name that has the labels. An alert for a series is pending while the
+// value of the series compares to the threshold as set by the operator, and
+// starts firing once it has been pending for the duration.
+type Rule struct {
+	Name      string         `json:"name"`
+	Type      string         `json:"type"`
+	Metric    string         `json:"metric"`
+	Labels    metrics.Labels `json:"labels,omitempty"`
+	Op        string         `json:"op"`
+	Threshold float64        `json:"threshold"`
+	For       Duration       `json:"for,omitempty"`
+}
+
+// Validate checks that the rule is well-formed.
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("rule has no name")
+	}
+
+	if r.Metric == "" {
+		return fmt.Errorf("rule %s: no metric name", r.Name)
+	}
+
+	switch r.Type {
+	case metrics.Gauge(0).Type(), metrics.Counter(0).Type():
+	default:
+		return fmt.Errorf("rule %s: unsupported metric type %q", r.Name, r.Type)
+	}
+
+	if _, ok := ops[r.Op]; !ok {
+		return fmt.Errorf("rule %s: unsupported operator %q", r.Name, r.Op)
+	}
+
+	if r.For < 0 {
+		return fmt.Errorf("rule %s: negative duration", r.Name)
+	}
+
+	if err := r.Labels.Validate(); err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+
+	return nil
+}
+
+// matches reports whether the value triggers the rule.
+func (r Rule) matches(v float64) bool {
+	return ops[r.Op](v, r.Threshold)
+}
+
+var ops = map[string]func(v, threshold float64) bool{
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	"==": func(v, t float64) bool { return v == t },
+	"!=": func(v, t float64) bool { return v != t },
+}
+
+// Duration is a time.Duration that is represented in JSON either as a string
+// parsable by time.ParseDuration or as a number of seconds.
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(v)
+		return nil
+	}
+
+	var sec float64
+	if err := json.Unmarshal(b, &sec); err != nil {
+		return fmt.Errorf("bad duration %s", b)
+	}
+	*d = Duration(sec * float64(time.Second))
+
+	return nil
+}
+
+// LoadRules reads the rules from a JSON file of the form
+// {"rules": [{"name": "high_cpu", "type": "gauge", "metric": "cpu",
+// "op": ">", "threshold": 90, "for": "5m"}]}.
+func LoadRules(fileName string) ([]Rule, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	var f struct {
+		Rules []Rule `json:"rules"`
+	}
+
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+
+	names := make(map[string]struct{}, len(f.Rules))
+	for _, r := range f.Rules {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+
+		if _, ok := names[r.Name]; ok {
+			return nil, fmt.Errorf("duplicate rule %s", r.Name)
+		}
+		names[r.Name] = struct{}{}
+	}
+
+	return f.Rules, nil
+}
@@ -0,0 +1,67 @@
+package alert_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nekr0z/muhame/internal/alert"
+)
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []alert.Rule
+		wantErr bool
+	}{
+		{
+			name:    "good",
+			content: `{"rules":[{"name":"a","type":"counter","metric":"errors","op":">=","threshold":10,"for":60},{"name":"b","type":"gauge","metric":"free","op":"<","threshold":0.1,"for":"30s"}]}`,
+			want: []alert.Rule{
+				{Name: "a", Type: "counter", Metric: "errors", Op: ">=", Threshold: 10, For: alert.Duration(time.Minute)},
+				{Name: "b", Type: "gauge", Metric: "free", Op: "<", Threshold: 0.1, For: alert.Duration(30 * time.Second)},
+			},
+		},
+		{
+			name:    "bad operator",
+			content: `{"rules":[{"name":"a","type":"gauge","metric":"free","op":"~","threshold":1}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "histogram",
+			content: `{"rules":[{"name":"a","type":"histogram","metric":"latency","op":">","threshold":1}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate",
+			content: `{"rules":[{"name":"a","type":"gauge","metric":"x","op":">","threshold":1},{"name":"a","type":"gauge","metric":"y","op":">","threshold":1}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "bad duration",
+			content: `{"rules":[{"name":"a","type":"gauge","metric":"x","op":">","threshold":1,"for":"soon"}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "rules.json")
+			require.NoError(t, os.WriteFile(fn, []byte(tt.content), 0o600))
+
+			got, err := alert.LoadRules(fn)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
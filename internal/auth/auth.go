@@ -0,0 +1,204 @@
+// Package auth implements the API keys that authenticate the clients and
+// limit what they can do.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Header is the HTTP header (and gRPC metadata key) that carries the token.
+const Header = "Authorization"
+
+const bearer = "Bearer "
+
+// ErrForbidden is returned when the key does not allow the operation.
+var ErrForbidden = errors.New("forbidden")
+
+// Scope is what a key allows to do.
+type Scope string
+
+// The known scopes. The admin scope allows everything.
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+// Key is a named API key.
+type Key struct {
+	Name   string  `json:"name"`
+	Token  string  `json:"token"`
+	Scopes []Scope `json:"scopes"`
+	// Prefixes, if any, limit the key to the metrics whose names start with
+	// one of them.
+	Prefixes []string `json:"prefixes,omitempty"`
+}
+
+// Allows reports whether the key allows the operation of the scope on the
+// metric with the given name.
+func (k Key) Allows(scope Scope, name string) bool {
+	return k.Has(scope) && k.allowsName(name)
+}
+
+// Has reports whether the key has the scope.
+func (k Key) Has(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsPrefix reports whether every metric name that starts with the given
+// prefix is allowed by the key.
+func (k Key) AllowsPrefix(prefix string) bool {
+	return k.allowsName(prefix)
+}
+
+func (k Key) allowsName(name string) bool {
+	if len(k.Prefixes) == 0 {
+		return true
+	}
+
+	for _, p := range k.Prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Keys is a set of API keys.
+type Keys []Key
+
+// Load reads the keys from a JSON file, i.e.
+//
+//	[{"name": "agent", "token": "s3cr3t", "scopes": ["write"], "prefixes": ["host_"]}]
+func Load(fileName string) (Keys, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys: %w", err)
+	}
+
+	var kk Keys
+	if err := json.Unmarshal(b, &kk); err != nil {
+		return nil, fmt.Errorf("failed to parse keys: %w", err)
+	}
+
+	if err := kk.validate(); err != nil {
+		return nil, err
+	}
+
+	return kk, nil
+}
+
+func (kk Keys) validate() error {
+	names := make(map[string]bool, len(kk))
+	tokens := make(map[string]bool, len(kk))
+
+	for i, k := range kk {
+		if k.Name == "" {
+			return fmt.Errorf("key %d has no name", i)
+		}
+
+		if names[k.Name] {
+			return fmt.Errorf("duplicate key name %s", k.Name)
+		}
+		names[k.Name] = true
+
+		if k.Token == "" {
+			return fmt.Errorf("key %s has no token", k.Name)
+		}
+
+		if tokens[k.Token] {
+			return fmt.Errorf("key %s reuses a token", k.Name)
+		}
+		tokens[k.Token] = true
+
+		if len(k.Scopes) == 0 {
+			return fmt.Errorf("key %s has no scopes", k.Name)
+		}
+
+		for _, s := range k.Scopes {
+			switch s {
+			case ScopeRead, ScopeWrite, ScopeAdmin:
+			default:
+				return fmt.Errorf("key %s has unknown scope %s", k.Name, s)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Find returns the key with the token. All the keys are compared in
+// constant time.
+func (kk Keys) Find(token string) (Key, bool) {
+	var (
+		found Key
+		ok    bool
+	)
+
+	want := sha256.Sum256([]byte(token))
+
+	for _, k := range kk {
+		have := sha256.Sum256([]byte(k.Token))
+		if subtle.ConstantTimeCompare(want[:], have[:]) == 1 {
+			found, ok = k, true
+		}
+	}
+
+	return found, ok
+}
+
+// Authenticate returns the key for the value of the Authorization header,
+// i.e. "Bearer s3cr3t".
+func (kk Keys) Authenticate(header string) (Key, bool) {
+	token, ok := strings.CutPrefix(header, bearer)
+	if !ok || token == "" {
+		return Key{}, false
+	}
+
+	return kk.Find(token)
+}
+
+// Bearer returns the value of the Authorization header for the token.
+func Bearer(token string) string {
+	return bearer + token
+}
+
+type keyCtx struct{}
+
+// WithKey returns the context of a request authenticated with the key.
+func WithKey(ctx context.Context, k Key) context.Context {
+	return context.WithValue(ctx, keyCtx{}, k)
+}
+
+// FromContext returns the key the request was authenticated with.
+func FromContext(ctx context.Context) (Key, bool) {
+	k, ok := ctx.Value(keyCtx{}).(Key)
+	return k, ok
+}
+
+// Allowed reports whether the key of the context allows the operation of the
+// scope on the metric with the given name. Everything is allowed if the
+// request was not authenticated with a key, which is the case when the keys
+// are not in use.
+func Allowed(ctx context.Context, scope Scope, name string) bool {
+	k, ok := FromContext(ctx)
+	if !ok {
+		return true
+	}
+
+	return k.Allows(scope, name)
+}
@@ -0,0 +1,106 @@
+package auth_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nekr0z/muhame/internal/auth"
+)
+
+var testKeys = auth.Keys{
+	{Name: "agent", Token: "agent-token", Scopes: []auth.Scope{auth.ScopeWrite}, Prefixes: []string{"host_"}},
+	{Name: "dashboard", Token: "dashboard-token", Scopes: []auth.Scope{auth.ScopeRead}},
+	{Name: "ops", Token: "ops-token", Scopes: []auth.Scope{auth.ScopeAdmin}},
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	kk, err := auth.Load(filepath.Join("testdata", "keys.json"))
+	require.NoError(t, err)
+	assert.Equal(t, testKeys, kk)
+
+	_, err = auth.Load(filepath.Join("testdata", "missing.json"))
+	assert.Error(t, err)
+
+	for name, in := range map[string]string{
+		"no name":         `[{"token": "t", "scopes": ["read"]}]`,
+		"no token":        `[{"name": "a", "scopes": ["read"]}]`,
+		"no scopes":       `[{"name": "a", "token": "t"}]`,
+		"unknown scope":   `[{"name": "a", "token": "t", "scopes": ["delete"]}]`,
+		"duplicate name":  `[{"name": "a", "token": "t", "scopes": ["read"]}, {"name": "a", "token": "u", "scopes": ["read"]}]`,
+		"duplicate token": `[{"name": "a", "token": "t", "scopes": ["read"]}, {"name": "b", "token": "t", "scopes": ["read"]}]`,
+		"not JSON":        `name=a`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fileName := filepath.Join(t.TempDir(), "keys.json")
+			require.NoError(t, os.WriteFile(fileName, []byte(in), 0600))
+
+			_, err := auth.Load(fileName)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestKeys_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	k, ok := testKeys.Authenticate("Bearer dashboard-token")
+	assert.True(t, ok)
+	assert.Equal(t, "dashboard", k.Name)
+
+	for _, header := range []string{"", "Bearer ", "dashboard-token", "Bearer other", "Basic ZGFzaGJvYXJkLXRva2Vu"} {
+		_, ok := testKeys.Authenticate(header)
+		assert.False(t, ok, header)
+	}
+}
+
+func TestKey_Allows(t *testing.T) {
+	t.Parallel()
+
+	agent, dashboard, ops := testKeys[0], testKeys[1], testKeys[2]
+
+	tests := []struct {
+		key   auth.Key
+		scope auth.Scope
+		name  string
+		want  bool
+	}{
+		{agent, auth.ScopeWrite, "host_cpu", true},
+		{agent, auth.ScopeWrite, "cpu", false},
+		{agent, auth.ScopeRead, "host_cpu", false},
+		{dashboard, auth.ScopeRead, "cpu", true},
+		{dashboard, auth.ScopeWrite, "cpu", false},
+		{dashboard, auth.ScopeAdmin, "cpu", false},
+		{ops, auth.ScopeAdmin, "cpu", true},
+		{ops, auth.ScopeWrite, "cpu", true},
+		{ops, auth.ScopeRead, "cpu", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.key.Allows(tt.scope, tt.name), "%s %s %s", tt.key.Name, tt.scope, tt.name)
+	}
+
+	assert.True(t, agent.AllowsPrefix("host_a"))
+	assert.False(t, agent.AllowsPrefix("ho"))
+	assert.False(t, agent.AllowsPrefix(""))
+	assert.True(t, dashboard.AllowsPrefix(""))
+}
+
+func TestAllowed(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	assert.True(t, auth.Allowed(ctx, auth.ScopeAdmin, "anything"))
+
+	ctx = auth.WithKey(ctx, testKeys[0])
+	assert.True(t, auth.Allowed(ctx, auth.ScopeWrite, "host_cpu"))
+	assert.False(t, auth.Allowed(ctx, auth.ScopeWrite, "cpu"))
+}
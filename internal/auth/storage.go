@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+// Wrap returns the storage that only lets the requests authenticated with a
+// key at the metrics the key allows, see Allowed. Updates of other metrics
+// fail with ErrForbidden, the other metrics are not found and not listed.
+// A key that can write a metric can also get its value, since /update/
+// responds with it. Bulk updates and pings are supported if the storage
+// supports them.
+func Wrap(st storage.Storage) storage.Storage {
+	as := &authorized{Storage: st}
+
+	bu, isBulk := st.(bulkUpdater)
+	p, isPing := st.(pingable)
+
+	switch {
+	case isBulk && isPing:
+		return struct {
+			*authorized
+			bulkAuthorized
+			pinger
+		}{as, bulkAuthorized{bu}, pinger{p}}
+	case isBulk:
+		return struct {
+			*authorized
+			bulkAuthorized
+		}{as, bulkAuthorized{bu}}
+	case isPing:
+		return struct {
+			*authorized
+			pinger
+		}{as, pinger{p}}
+	default:
+		return as
+	}
+}
+
+type authorized struct {
+	storage.Storage
+}
+
+// Update implements storage.Storage.
+func (s *authorized) Update(ctx context.Context, m metrics.Named) error {
+	if err := checkWrite(ctx, m); err != nil {
+		return err
+	}
+
+	return s.Storage.Update(ctx, m)
+}
+
+// Get implements storage.Storage.
+func (s *authorized) Get(ctx context.Context, t, name string, labels metrics.Labels) (metrics.Metric, error) {
+	if !Allowed(ctx, ScopeRead, name) && !Allowed(ctx, ScopeWrite, name) {
+		return nil, storage.ErrMetricNotFound
+	}
+
+	return s.Storage.Get(ctx, t, name, labels)
+}
+
+// Describe implements storage.Storage.
+func (s *authorized) Describe(ctx context.Context, t, name string, labels metrics.Labels) (storage.Series, error) {
+	if !Allowed(ctx, ScopeRead, name) {
+		return storage.Series{}, storage.ErrMetricNotFound
+	}
+
+	return s.Storage.Describe(ctx, t, name, labels)
+}
+
+// List implements storage.Storage.
+func (s *authorized) List(ctx context.Context, filter metrics.Labels) ([]metrics.Named, error) {
+	mm, err := s.Storage.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []metrics.Named
+	for _, m := range mm {
+		if Allowed(ctx, ScopeRead, m.Name) {
+			res = append(res, m)
+		}
+	}
+
+	return res, nil
+}
+
+// ListSeries implements storage.Storage.
+func (s *authorized) ListSeries(ctx context.Context, filter metrics.Labels) ([]storage.Series, error) {
+	ss, err := s.Storage.ListSeries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []storage.Series
+	for _, m := range ss {
+		if Allowed(ctx, ScopeRead, m.Name) {
+			res = append(res, m)
+		}
+	}
+
+	return res, nil
+}
+
+// Delete implements storage.Storage.
+func (s *authorized) Delete(ctx context.Context, t, name string, labels metrics.Labels) error {
+	if !Allowed(ctx, ScopeAdmin, name) {
+		return storage.ErrMetricNotFound
+	}
+
+	return s.Storage.Delete(ctx, t, name, labels)
+}
+
+// Reset implements storage.Storage.
+func (s *authorized) Reset(ctx context.Context, t, name string, labels metrics.Labels) error {
+	if !Allowed(ctx, ScopeAdmin, name) {
+		return storage.ErrMetricNotFound
+	}
+
+	return s.Storage.Reset(ctx, t, name, labels)
+}
+
+// Range queries the history of the underlying storage.
+func (s *authorized) Range(ctx context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error) {
+	rq, ok := s.Storage.(rangeQuerier)
+	if !ok {
+		return nil, storage.ErrHistoryDisabled
+	}
+
+	if !Allowed(ctx, ScopeRead, name) {
+		return nil, storage.ErrMetricNotFound
+	}
+
+	return rq.Range(ctx, t, name, labels, from, to)
+}
+
+func checkWrite(ctx context.Context, mm ...metrics.Named) error {
+	for _, m := range mm {
+		if !Allowed(ctx, ScopeWrite, m.Name) {
+			return fmt.Errorf("%w: key may not write %s", ErrForbidden, m.Name)
+		}
+	}
+
+	return nil
+}
+
+type bulkAuthorized struct {
+	bu bulkUpdater
+}
+
+// BulkUpdate updates the metrics in the underlying storage if the key allows
+// writing all of them.
+func (s bulkAuthorized) BulkUpdate(ctx context.Context, mm []metrics.Named) error {
+	if err := checkWrite(ctx, mm...); err != nil {
+		return err
+	}
+
+	return s.bu.BulkUpdate(ctx, mm)
+}
+
+type pinger struct {
+	p pingable
+}
+
+// Ping pings the underlying storage.
+func (p pinger) Ping(ctx context.Context) error {
+	return p.p.Ping(ctx)
+}
+
+type bulkUpdater interface {
+	BulkUpdate(context.Context, []metrics.Named) error
+}
+
+type pingable interface {
+	Ping(context.Context) error
+}
+
+type rangeQuerier interface {
+	Range(ctx context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error)
+}
@@ -0,0 +1,62 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+func TestWrap(t *testing.T) {
+	t.Parallel()
+
+	ms, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	st := auth.Wrap(ms)
+
+	ctx := context.Background()
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "cpu", Metric: metrics.Gauge(1)}))
+
+	agent := auth.WithKey(ctx, testKeys[0])
+	dashboard := auth.WithKey(ctx, testKeys[1])
+
+	assert.NoError(t, st.Update(agent, metrics.Named{Name: "host_cpu", Metric: metrics.Gauge(2)}))
+
+	err = st.Update(agent, metrics.Named{Name: "cpu", Metric: metrics.Gauge(3)})
+	assert.ErrorIs(t, err, auth.ErrForbidden)
+
+	err = st.Update(dashboard, metrics.Named{Name: "cpu", Metric: metrics.Gauge(3)})
+	assert.ErrorIs(t, err, auth.ErrForbidden)
+
+	m, err := st.Get(agent, "gauge", "host_cpu", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(2), m)
+
+	_, err = st.Get(agent, "gauge", "cpu", nil)
+	assert.ErrorIs(t, err, storage.ErrMetricNotFound)
+
+	_, err = st.Describe(agent, "gauge", "host_cpu", nil)
+	assert.ErrorIs(t, err, storage.ErrMetricNotFound)
+
+	m, err = st.Get(dashboard, "gauge", "cpu", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(1), m)
+
+	mm, err := st.List(dashboard, nil)
+	assert.NoError(t, err)
+	assert.Len(t, mm, 2)
+
+	mm, err = st.List(agent, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, mm)
+
+	assert.ErrorIs(t, st.Delete(dashboard, "gauge", "cpu", nil), storage.ErrMetricNotFound)
+	assert.NoError(t, st.Delete(auth.WithKey(ctx, testKeys[2]), "gauge", "cpu", nil))
+}
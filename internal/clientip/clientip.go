@@ -0,0 +1,126 @@
+// Package clientip resolves the addresses of the clients behind the proxies
+// and checks them against the trusted subnets.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/nekr0z/muhame/internal/httpclient"
+)
+
+// ForwardedForHeader is the header (and gRPC metadata key) the proxies list
+// the addresses the request was forwarded for in.
+const ForwardedForHeader = "X-Forwarded-For"
+
+// Subnets is a set of IPv4 and IPv6 subnets.
+type Subnets []*net.IPNet
+
+// ParseSubnets parses a comma-separated list of subnets in CIDR notation, i.e.
+// "10.0.0.0/8,fd00::/8". A bare address is a subnet of its own.
+func ParseSubnets(s string) (Subnets, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var ss Subnets
+
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, fmt.Errorf("bad address %q", c)
+			}
+
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+
+			c = fmt.Sprintf("%s/%d", c, bits)
+		}
+
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+
+		ss = append(ss, n)
+	}
+
+	return ss, nil
+}
+
+// Contains reports whether the address is in any of the subnets.
+func (ss Subnets) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range ss {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolver resolves the address of the client.
+//
+// The address of the peer is only replaced with the one from the
+// X-Forwarded-For (or X-Real-IP) header if the peer is one of the trusted
+// proxies, and the forwarded chain is only followed for as long as it goes
+// through them. The zero Resolver trusts no proxies, so it always resolves to
+// the address of the peer, whatever the headers say.
+type Resolver struct {
+	Proxies Subnets
+}
+
+// Resolve returns the address of the client given the address of the peer
+// (with or without the port), and the values of the X-Real-IP and
+// X-Forwarded-For headers. It returns nil if there is no valid address.
+func (r Resolver) Resolve(peer, realIP string, forwardedFor []string) net.IP {
+	ip := parseHost(peer)
+
+	if !r.Proxies.Contains(ip) {
+		return ip
+	}
+
+	var hops []string
+	for _, v := range forwardedFor {
+		for _, h := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(h))
+		}
+	}
+
+	if len(hops) == 0 && realIP != "" {
+		hops = []string{strings.TrimSpace(realIP)}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip = net.ParseIP(hops[i])
+		if !r.Proxies.Contains(ip) {
+			return ip
+		}
+	}
+
+	return ip
+}
+
+// FromRequest resolves the address of the client of the HTTP request.
+func (r Resolver) FromRequest(req *http.Request) net.IP {
+	return r.Resolve(req.RemoteAddr, req.Header.Get(httpclient.HeaderRealIP), req.Header.Values(ForwardedForHeader))
+}
+
+func parseHost(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	return net.ParseIP(addr)
+}
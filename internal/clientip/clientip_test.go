@@ -0,0 +1,98 @@
+package clientip_test
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nekr0z/muhame/internal/clientip"
+)
+
+func TestParseSubnets(t *testing.T) {
+	t.Parallel()
+
+	ss, err := clientip.ParseSubnets("10.0.0.0/8, fd00::/8,192.0.2.1,2001:db8::1")
+	require.NoError(t, err)
+	require.Len(t, ss, 4)
+
+	assert.True(t, ss.Contains(net.ParseIP("10.1.2.3")))
+	assert.True(t, ss.Contains(net.ParseIP("fd12::1")))
+	assert.True(t, ss.Contains(net.ParseIP("192.0.2.1")))
+	assert.False(t, ss.Contains(net.ParseIP("192.0.2.2")))
+	assert.True(t, ss.Contains(net.ParseIP("2001:db8::1")))
+	assert.False(t, ss.Contains(net.ParseIP("2001:db8::2")))
+	assert.False(t, ss.Contains(nil))
+
+	ss, err = clientip.ParseSubnets("")
+	assert.NoError(t, err)
+	assert.Empty(t, ss)
+
+	for _, bad := range []string{"whatever", "10.0.0.0/33", "10.0.0.0/8,"} {
+		_, err := clientip.ParseSubnets(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	t.Parallel()
+
+	proxies, err := clientip.ParseSubnets("192.0.2.0/24,2001:db8::/32")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		proxies      clientip.Subnets
+		peer         string
+		realIP       string
+		forwardedFor []string
+		want         string
+	}{
+		{name: "peer", peer: "198.51.100.1:1234", want: "198.51.100.1"},
+		{name: "peer without port", peer: "198.51.100.1", want: "198.51.100.1"},
+		{name: "IPv6 peer", peer: "[2001:db8::1]:1234", want: "2001:db8::1"},
+		{name: "real IP ignored", peer: "198.51.100.1:1234", realIP: "10.0.0.1", want: "198.51.100.1"},
+		{name: "forwarded ignored", peer: "198.51.100.1:1234", forwardedFor: []string{"10.0.0.1"}, want: "198.51.100.1"},
+		{name: "untrusted peer", proxies: proxies, peer: "198.51.100.1:1234", realIP: "10.0.0.1", forwardedFor: []string{"10.0.0.1"}, want: "198.51.100.1"},
+		{name: "proxy", proxies: proxies, peer: "192.0.2.1:1234", forwardedFor: []string{"10.0.0.1"}, want: "10.0.0.1"},
+		{name: "proxy real IP", proxies: proxies, peer: "192.0.2.1:1234", realIP: "10.0.0.1", want: "10.0.0.1"},
+		{name: "proxy chain", proxies: proxies, peer: "192.0.2.1:1234", forwardedFor: []string{"10.0.0.9, 10.0.0.1", "2001:db8::5"}, want: "10.0.0.1"},
+		{name: "only proxies", proxies: proxies, peer: "192.0.2.1:1234", forwardedFor: []string{"192.0.2.7"}, want: "192.0.2.7"},
+		{name: "proxy without headers", proxies: proxies, peer: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "bad forwarded", proxies: proxies, peer: "192.0.2.1:1234", forwardedFor: []string{"unknown"}},
+		{name: "bad peer", peer: "pipe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := clientip.Resolver{Proxies: tt.proxies}
+			got := r.Resolve(tt.peer, tt.realIP, tt.forwardedFor)
+
+			if tt.want == "" {
+				assert.Nil(t, got)
+				return
+			}
+
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestResolver_FromRequest(t *testing.T) {
+	t.Parallel()
+
+	proxies, err := clientip.ParseSubnets("192.0.2.1")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Add("X-Forwarded-For", "10.0.0.1")
+	req.Header.Set("X-Real-IP", "10.0.0.2")
+
+	assert.Equal(t, "10.0.0.1", clientip.Resolver{Proxies: proxies}.FromRequest(req).String())
+	assert.Equal(t, "192.0.2.1", clientip.Resolver{}.FromRequest(req).String())
+}
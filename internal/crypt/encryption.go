@@ -1,46 +1,155 @@
 // Package crypt provides cryptographic functions.
+//
+// Messages are encrypted with a random AES-256-GCM key that is itself
+// encrypted with RSA-OAEP (SHA-256), so there is no limit on the message size.
+// The wire format of an encrypted message is:
+//
+//	version (1 byte) | wrapped key length (2 bytes, big endian) |
+//	wrapped key | GCM nonce (12 bytes) | sealed message
+//
+// The version, length and wrapped key are authenticated as additional data.
+// The messages produced by the older versions, i.e. a single RSA-OAEP block
+// in PEM, are still accepted by Decrypt.
 package crypt
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/pem"
+	"errors"
 	"fmt"
 )
 
+// Version is the version of the wire format produced by Encrypt.
+const Version byte = 1
+
+const (
+	aesKeySize = 32
+	headerSize = 3
+)
+
+// ErrUnsupportedVersion is returned when the message is in an unknown format.
+var ErrUnsupportedVersion = errors.New("unsupported message version")
+
 // Encrypt encrypts data with public key.
 func Encrypt(msg []byte, pubkey *rsa.PublicKey) ([]byte, error) {
 	if pubkey == nil {
 		return nil, fmt.Errorf("public key is nil")
 	}
 
-	hash := sha256.New()
+	key := make([]byte, aesKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
 
-	ciphertext, err := rsa.EncryptOAEP(hash, rand.Reader, pubkey, msg, nil)
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pubkey, key, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt message: %w", err)
+		return nil, fmt.Errorf("failed to encrypt key: %w", err)
 	}
 
-	return pem.EncodeToMemory(&pem.Block{
-		Type:  "MESSAGE",
-		Bytes: ciphertext,
-	}), nil
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, headerSize, headerSize+len(wrapped)+gcm.NonceSize()+len(msg)+gcm.Overhead())
+	out[0] = Version
+	binary.BigEndian.PutUint16(out[1:], uint16(len(wrapped)))
+	out = append(out, wrapped...)
+	ad := out
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out = append(out, nonce...)
+
+	return gcm.Seal(out, nonce, msg, ad), nil
 }
 
 // Decrypt decrypts data with private key.
 func Decrypt(ciphertext []byte, privkey *rsa.PrivateKey) ([]byte, error) {
-	hash := sha256.New()
+	if privkey == nil {
+		return nil, fmt.Errorf("private key is nil")
+	}
+
+	if len(ciphertext) == 0 {
+		return nil, fmt.Errorf("failed to decode message")
+	}
+
+	switch ciphertext[0] {
+	case Version:
+		return decryptEnvelope(ciphertext, privkey)
+	case '-':
+		return decryptLegacy(ciphertext, privkey)
+	default:
+		return nil, ErrUnsupportedVersion
+	}
+}
+
+func decryptEnvelope(ciphertext []byte, privkey *rsa.PrivateKey) ([]byte, error) {
+	if len(ciphertext) < headerSize {
+		return nil, fmt.Errorf("failed to decode message")
+	}
+
+	n := int(binary.BigEndian.Uint16(ciphertext[1:]))
+	if len(ciphertext) < headerSize+n {
+		return nil, fmt.Errorf("failed to decode message")
+	}
+
+	ad := ciphertext[:headerSize+n]
 
+	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privkey, ciphertext[headerSize:headerSize+n], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := ciphertext[headerSize+n:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, fmt.Errorf("failed to decode message")
+	}
+
+	plaintext, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], ad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt message: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func decryptLegacy(ciphertext []byte, privkey *rsa.PrivateKey) ([]byte, error) {
 	block, _ := pem.Decode(ciphertext)
 	if block == nil {
 		return nil, fmt.Errorf("failed to decode message")
 	}
 
-	plaintext, err := rsa.DecryptOAEP(hash, rand.Reader, privkey, block.Bytes, nil)
+	plaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privkey, block.Bytes, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt message: %w", err)
 	}
 
 	return plaintext, nil
 }
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return gcm, nil
+}
@@ -95,6 +95,51 @@ func TestEncrypt(t *testing.T) {
 		assert.Equal(t, message, plaintext)
 	})
 
+	t.Run("large message", func(t *testing.T) {
+		t.Parallel()
+
+		large := bytes.Repeat(message, 10000)
+
+		ciphertext, err := crypt.Encrypt(large, &privateKey.PublicKey)
+		require.NoError(t, err)
+		assert.Equal(t, crypt.Version, ciphertext[0])
+
+		plaintext, err := crypt.Decrypt(ciphertext, privateKey)
+		assert.NoError(t, err)
+
+		assert.Equal(t, large, plaintext)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		t.Parallel()
+
+		ciphertext, err := crypt.Encrypt(message, &privateKey.PublicKey)
+		require.NoError(t, err)
+
+		for _, i := range []int{1, 10, len(ciphertext) - 1} {
+			tampered := bytes.Clone(ciphertext)
+			tampered[i] ^= 0xff
+
+			_, err = crypt.Decrypt(tampered, privateKey)
+			assert.Error(t, err, i)
+		}
+
+		_, err = crypt.Decrypt(ciphertext[:len(ciphertext)/2], privateKey)
+		assert.Error(t, err)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		t.Parallel()
+
+		ciphertext, err := crypt.Encrypt(message, &privateKey.PublicKey)
+		require.NoError(t, err)
+
+		ciphertext[0] = crypt.Version + 1
+
+		_, err = crypt.Decrypt(ciphertext, privateKey)
+		assert.ErrorIs(t, err, crypt.ErrUnsupportedVersion)
+	})
+
 	t.Run("nil key", func(t *testing.T) {
 		t.Parallel()
 
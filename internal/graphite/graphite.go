@@ -0,0 +1,205 @@
+// Package graphite implements a server for the Graphite plaintext protocol.
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// ErrServerClosed is returned by ListenAndServe after Shutdown.
+var ErrServerClosed = errors.New("graphite: server closed")
+
+// Server receives metrics in the Graphite plaintext format, i.e. lines of
+// "path value timestamp", over TCP and saves them as gauges.
+type Server struct {
+	log *zap.SugaredLogger
+	st  Updater
+	mm  Mappings
+
+	mu     sync.Mutex
+	closed bool
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+
+	wg       sync.WaitGroup
+	stopChan chan struct{}
+}
+
+// New returns a new Graphite server that maps paths to names and labels
+// according to the mappings.
+func New(log *zap.SugaredLogger, st Updater, mm Mappings) *Server {
+	return &Server{
+		log:      log,
+		st:       st,
+		mm:       mm,
+		conns:    make(map[net.Conn]struct{}),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address and serves until Shutdown is
+// called, then returns ErrServerClosed.
+func (s *Server) ListenAndServe(address string) error {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = ln.Close()
+		return ErrServerClosed
+	}
+	s.ln = ln
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go s.serve()
+
+	<-s.stopChan
+
+	return ErrServerClosed
+}
+
+// Shutdown stops the server and waits for the connections to be closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+
+	close(s.stopChan)
+
+	var errs []error
+	if s.ln != nil {
+		errs = append(errs, s.ln.Close())
+	}
+	for c := range s.conns {
+		errs = append(errs, c.Close())
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, ctx.Err())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+			s.log.Errorf("failed to accept Graphite connection: %s", err)
+			continue
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		m, err := s.parseLine(line)
+		if err != nil {
+			s.log.Debugf("skipping Graphite line from %s: %s", conn.RemoteAddr(), err)
+			continue
+		}
+
+		if err := s.st.Update(context.Background(), m); err != nil {
+			s.log.Errorf("failed to save Graphite metric %s: %s", m.Name, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil && !s.isClosed() {
+		s.log.Errorf("failed to read from Graphite connection: %s", err)
+	}
+}
+
+// parseLine parses a "path value timestamp" line. The timestamp is validated,
+// but not used.
+func (s *Server) parseLine(line string) (metrics.Named, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return metrics.Named{}, fmt.Errorf("malformed line %q", line)
+	}
+
+	v, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return metrics.Named{}, fmt.Errorf("bad value in %q: %w", line, err)
+	}
+
+	if _, err := strconv.ParseFloat(fields[2], 64); err != nil {
+		return metrics.Named{}, fmt.Errorf("bad timestamp in %q: %w", line, err)
+	}
+
+	name, labels := s.mm.Map(fields[0])
+
+	return metrics.Named{
+		Name:   name,
+		Labels: labels,
+		Metric: metrics.Gauge(v),
+	}, nil
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+// Updater is where the received metrics are written to.
+type Updater interface {
+	Update(context.Context, metrics.Named) error
+}
@@ -0,0 +1,68 @@
+package graphite_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/graphite"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	log := zap.NewNop().Sugar()
+
+	st, err := storage.New(log, storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	address := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	srv := graphite.New(log, st, graphite.Mappings{{Pattern: "servers.*.*", Template: ".host.name"}})
+
+	served := make(chan error)
+	go func() {
+		served <- srv.ListenAndServe(address)
+	}()
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		conn, err = net.Dial("tcp", address)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	_, err = fmt.Fprint(conn, "servers.web1.load 1.5 1700000000\nbackups.size 42 1700000000\nbad line\nbackups.count x 1700000000\n")
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	assert.Eventually(t, func() bool {
+		_, err := st.Get(ctx, "gauge", "backups.size", nil)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, srv.Shutdown(ctx))
+	assert.True(t, errors.Is(<-served, graphite.ErrServerClosed))
+
+	m, err := st.Get(ctx, "gauge", "load", metrics.Labels{"host": "web1"})
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(1.5), m)
+
+	m, err = st.Get(ctx, "gauge", "backups.size", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(42), m)
+
+	list, err := st.List(ctx, nil)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+}
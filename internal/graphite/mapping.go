@@ -0,0 +1,135 @@
+package graphite
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// Mapping maps the dotted paths that match the pattern to names and labels
+// according to the template.
+//
+// Both pattern and template are dotted, with a segment of the template for
+// each segment of the pattern. Pattern segments are matched with path.Match.
+// A template segment "name" adds the path segment to the metric name, an empty
+// segment drops the path segment, and any other segment is the name of the
+// label the path segment is the value of. E.g. the template ".host.name.name"
+// for the pattern "servers.*.cpu.*" maps "servers.web1.cpu.load" to "cpu.load"
+// with the label host="web1".
+type Mapping struct {
+	Pattern  string
+	Template string
+}
+
+// Mappings is a list of mappings, the first one that matches applies. Paths
+// that match no mapping are used as names as is.
+type Mappings []Mapping
+
+// ParseMappings parses a comma-separated list of "pattern:template" mappings.
+func ParseMappings(s string) (Mappings, error) {
+	var mm Mappings
+
+	for _, m := range strings.Split(s, ",") {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+
+		p, t, ok := strings.Cut(m, ":")
+		if !ok {
+			return nil, fmt.Errorf("bad mapping %q: no template", m)
+		}
+
+		mapping := Mapping{Pattern: p, Template: t}
+		if err := mapping.validate(); err != nil {
+			return nil, fmt.Errorf("bad mapping %q: %w", m, err)
+		}
+
+		mm = append(mm, mapping)
+	}
+
+	return mm, nil
+}
+
+func (m Mapping) validate() error {
+	ps := strings.Split(m.Pattern, ".")
+	ts := strings.Split(m.Template, ".")
+
+	if len(ps) != len(ts) {
+		return fmt.Errorf("pattern and template have different number of segments")
+	}
+
+	for _, p := range ps {
+		if _, err := path.Match(p, ""); err != nil {
+			return err
+		}
+	}
+
+	hasName := false
+	labels := make(metrics.Labels)
+
+	for _, t := range ts {
+		switch t {
+		case "name":
+			hasName = true
+		case "":
+		default:
+			labels[t] = ""
+		}
+	}
+
+	if !hasName {
+		return fmt.Errorf("template has no name segments")
+	}
+
+	return labels.Validate()
+}
+
+// apply maps the path if it matches the mapping.
+func (m Mapping) apply(segments []string) (string, metrics.Labels, bool) {
+	ps := strings.Split(m.Pattern, ".")
+	if len(ps) != len(segments) {
+		return "", nil, false
+	}
+
+	for i, p := range ps {
+		if ok, _ := path.Match(p, segments[i]); !ok {
+			return "", nil, false
+		}
+	}
+
+	var (
+		name   []string
+		labels metrics.Labels
+	)
+
+	for i, t := range strings.Split(m.Template, ".") {
+		switch t {
+		case "name":
+			name = append(name, segments[i])
+		case "":
+		default:
+			if labels == nil {
+				labels = make(metrics.Labels)
+			}
+			labels[t] = segments[i]
+		}
+	}
+
+	return strings.Join(name, "."), labels, true
+}
+
+// Map returns the name and labels for the dotted path.
+func (mm Mappings) Map(p string) (string, metrics.Labels) {
+	segments := strings.Split(p, ".")
+
+	for _, m := range mm {
+		if name, labels, ok := m.apply(segments); ok {
+			return name, labels
+		}
+	}
+
+	return p, nil
+}
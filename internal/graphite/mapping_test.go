@@ -0,0 +1,54 @@
+package graphite_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nekr0z/muhame/internal/graphite"
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+func TestParseMappings(t *testing.T) {
+	mm, err := graphite.ParseMappings("servers.*.cpu.*:.host.name.name, apps.*.*:.app.name")
+	require.NoError(t, err)
+	assert.Equal(t, graphite.Mappings{
+		{Pattern: "servers.*.cpu.*", Template: ".host.name.name"},
+		{Pattern: "apps.*.*", Template: ".app.name"},
+	}, mm)
+
+	for _, s := range []string{
+		"servers.*",
+		"servers.*:name",
+		"servers.*:.host",
+		"servers.*:bad-label.name",
+		"servers.[:.name",
+	} {
+		_, err := graphite.ParseMappings(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestMappings_Map(t *testing.T) {
+	mm := graphite.Mappings{
+		{Pattern: "servers.*.cpu.*", Template: ".host.name.name"},
+		{Pattern: "servers.*.*", Template: ".host.name"},
+	}
+
+	tests := []struct {
+		path   string
+		name   string
+		labels metrics.Labels
+	}{
+		{"servers.web1.cpu.load", "cpu.load", metrics.Labels{"host": "web1"}},
+		{"servers.web1.uptime", "uptime", metrics.Labels{"host": "web1"}},
+		{"backups.size", "backups.size", nil},
+	}
+
+	for _, tt := range tests {
+		name, labels := mm.Map(tt.path)
+		assert.Equal(t, tt.name, name, tt.path)
+		assert.Equal(t, tt.labels, labels, tt.path)
+	}
+}
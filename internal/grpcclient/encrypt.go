@@ -58,3 +58,49 @@ func EncryptInterceptor(key *rsa.PublicKey) grpc.UnaryClientInterceptor {
 		return invoker(ctx, method, req, reply, cc, opts...)
 	}
 }
+
+// EncryptStreamInterceptor returns a grpc.StreamClientInterceptor that
+// encrypts every batch sent over the stream. If the key is nil, the
+// interceptor does nothing.
+func EncryptStreamInterceptor(key *rsa.PublicKey) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil || key == nil {
+			return cs, err
+		}
+
+		return &encryptedStream{ClientStream: cs, key: key}, nil
+	}
+}
+
+type encryptedStream struct {
+	grpc.ClientStream
+	key *rsa.PublicKey
+}
+
+// SendMsg implements grpc.ClientStream.
+func (s *encryptedStream) SendMsg(m interface{}) error {
+	r, ok := m.(*proto.StreamRequest)
+	if !ok {
+		return s.ClientStream.SendMsg(m)
+	}
+
+	data, err := pb.Marshal(r.GetBatch())
+	if err != nil {
+		return err
+	}
+
+	data, err = crypt.Encrypt(data, s.key)
+	if err != nil {
+		return err
+	}
+
+	return s.ClientStream.SendMsg(&proto.StreamRequest{
+		Id: r.GetId(),
+		Batch: &proto.BulkRequest{
+			Payload: &proto.BulkRequest_Data{
+				Data: data,
+			},
+		},
+	})
+}
@@ -13,30 +13,105 @@ import (
 )
 
 // SignatureInterceptor returns a grpc.UnaryClientInterceptor that signs the
-// request. If the key is empty, the interceptor does nothing.
-func SignatureInterceptor(key string) grpc.UnaryClientInterceptor {
+// request stamped with the current time and a nonce. The ID of the key, if
+// any, is sent along. If the key is empty, the interceptor does nothing.
+func SignatureInterceptor(s hash.Signer) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		if key == "" {
+		if s.Key == "" {
 			return invoker(ctx, method, req, reply, cc, opts...)
 		}
 
-		var m pb.Message
-		switch req := req.(type) {
-		case *proto.MetricRequest:
-			m = req
-		case *proto.BulkRequest:
-			m = req
+		m, ok := req.(pb.Message)
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
 		}
 
-		out, err := pb.Marshal(m)
+		out, err := pb.MarshalOptions{Deterministic: true}.Marshal(m)
 		if err != nil {
 			return err
 		}
 
-		sig := hash.Signature(out, key)
+		stamp := hash.NewStamp()
+
+		ctx = metadata.AppendToOutgoingContext(ctx,
+			hash.Header, s.Sign(out, stamp),
+			hash.TimestampHeader, stamp.Timestamp(),
+			hash.NonceHeader, stamp.Nonce,
+		)
 
-		ctx = metadata.AppendToOutgoingContext(ctx, hash.Header, sig)
+		if s.ID != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, hash.KeyIDHeader, s.ID)
+		}
 
 		return invoker(ctx, method, req, reply, cc, opts...)
 	}
 }
+
+// SignatureStreamInterceptor returns a grpc.StreamClientInterceptor that signs
+// every batch sent over the stream, as well as the watch request. If the key
+// is empty, the interceptor does nothing.
+func SignatureStreamInterceptor(s hash.Signer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil || s.Key == "" {
+			return cs, err
+		}
+
+		return &signedStream{ClientStream: cs, signer: s}, nil
+	}
+}
+
+type signedStream struct {
+	grpc.ClientStream
+	signer hash.Signer
+}
+
+// SendMsg implements grpc.ClientStream.
+func (s *signedStream) SendMsg(m interface{}) error {
+	switch r := m.(type) {
+	case *proto.StreamRequest:
+		stamp := hash.NewStamp()
+
+		sig, err := s.sign(r.GetBatch(), stamp)
+		if err != nil {
+			return err
+		}
+
+		return s.ClientStream.SendMsg(&proto.StreamRequest{
+			Id:        r.GetId(),
+			Batch:     r.GetBatch(),
+			Signature: sig,
+			Timestamp: stamp.Time.Unix(),
+			Nonce:     stamp.Nonce,
+			KeyId:     s.signer.ID,
+		})
+	case *proto.WatchRequest:
+		stamp := hash.NewStamp()
+
+		c := pb.Clone(r).(*proto.WatchRequest)
+		c.Signature = ""
+		c.Timestamp = stamp.Time.Unix()
+		c.Nonce = stamp.Nonce
+		c.KeyId = s.signer.ID
+
+		sig, err := s.sign(c, stamp)
+		if err != nil {
+			return err
+		}
+
+		c.Signature = sig
+
+		return s.ClientStream.SendMsg(c)
+	default:
+		return s.ClientStream.SendMsg(m)
+	}
+}
+
+func (s *signedStream) sign(m pb.Message, stamp hash.Stamp) (string, error) {
+	out, err := pb.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	return s.signer.Sign(out, stamp), nil
+}
@@ -0,0 +1,85 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/nekr0z/muhame/internal/auth"
+)
+
+// AuthInterceptor returns a grpc.UnaryServerInterceptor that authenticates the
+// calls with the API keys in the authorization metadata and only lets them
+// through if the key has the scope of the method. The methods not in the
+// scopes need the admin scope. The key is added to the context, so that the
+// storage wrapped with auth.Wrap checks the metric names. If there are no
+// keys, the interceptor does nothing.
+func AuthInterceptor(keys auth.Keys, scopes map[string]auth.Scope) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if len(keys) == 0 {
+			return handler(ctx, req)
+		}
+
+		ctx, err := authorize(ctx, keys, scopes, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// AuthStreamInterceptor is the AuthInterceptor for the streaming methods.
+func AuthStreamInterceptor(keys auth.Keys, scopes map[string]auth.Scope) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if len(keys) == 0 {
+			return handler(srv, ss)
+		}
+
+		ctx, err := authorize(ss.Context(), keys, scopes, info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func authorize(ctx context.Context, keys auth.Keys, scopes map[string]auth.Scope, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	v := md.Get(strings.ToLower(auth.Header))
+	if len(v) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "no API key")
+	}
+
+	k, ok := keys.Authenticate(v[0])
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unknown API key")
+	}
+
+	scope, ok := scopes[method]
+	if !ok {
+		scope = auth.ScopeAdmin
+	}
+
+	if !k.Has(scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "key %s lacks %s scope", k.Name, scope)
+	}
+
+	return auth.WithKey(ctx, k), nil
+}
+
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context with the key.
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
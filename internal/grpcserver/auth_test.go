@@ -0,0 +1,90 @@
+package grpcserver_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/grpcserver"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/internal/watch"
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+func TestAuth(t *testing.T) {
+	t.Parallel()
+
+	keys := auth.Keys{
+		{Name: "agent", Token: "agent-token", Scopes: []auth.Scope{auth.ScopeWrite}, Prefixes: []string{"host_"}},
+		{Name: "dashboard", Token: "dashboard-token", Scopes: []auth.Scope{auth.ScopeRead}},
+	}
+
+	scopes := map[string]auth.Scope{
+		proto.MetricsService_Update_FullMethodName: auth.ScopeWrite,
+		proto.MetricsService_Get_FullMethodName:    auth.ScopeRead,
+		proto.MetricsService_Watch_FullMethodName:  auth.ScopeRead,
+	}
+
+	ms, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+	require.NoError(t, ms.Update(context.Background(), metrics.Named{Name: "cpu", Metric: metrics.Gauge(1)}))
+
+	cl := client(t, grpcserver.New(auth.Wrap(ms), grpcserver.WithHub(watch.NewHub(0))),
+		grpc.ChainUnaryInterceptor(grpcserver.AuthInterceptor(keys, scopes)),
+		grpc.ChainStreamInterceptor(grpcserver.AuthStreamInterceptor(keys, scopes)),
+	)
+
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", auth.Bearer(token))
+	}
+
+	update := func(name string) *proto.MetricRequest {
+		return &proto.MetricRequest{Payload: &proto.MetricRequest_Metric{Metric: &proto.Metric{
+			Name:  name,
+			Value: &proto.Metric_Gauge{Gauge: &proto.Gauge{Value: 2}},
+		}}}
+	}
+
+	_, err = cl.Update(context.Background(), update("host_cpu"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = cl.Update(withToken("other"), update("host_cpu"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = cl.Update(withToken("agent-token"), update("host_cpu"))
+	assert.NoError(t, err)
+
+	_, err = cl.Update(withToken("agent-token"), update("cpu"))
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = cl.Update(withToken("dashboard-token"), update("cpu"))
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	res, err := cl.Get(withToken("dashboard-token"), &proto.SeriesID{Name: "cpu", Type: "gauge"})
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, res.GetMetric().GetGauge().GetValue())
+
+	_, err = cl.Get(withToken("agent-token"), &proto.SeriesID{Name: "cpu", Type: "gauge"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = cl.Delete(withToken("dashboard-token"), &proto.SeriesRequest{Series: []*proto.SeriesID{{Name: "cpu", Type: "gauge"}}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "methods not in scopes need admin")
+
+	stream, err := cl.Watch(context.Background(), &proto.WatchRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	m, err := ms.Get(context.Background(), "gauge", "cpu", nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(1), m)
+}
@@ -13,8 +13,24 @@ import (
 	"github.com/nekr0z/muhame/pkg/proto"
 )
 
+// otlpExportMethod is the full name of the OTLP MetricsService Export method.
+const otlpExportMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+
+// plaintextMethods are the unary methods whose requests can not carry
+// encrypted data.
+var plaintextMethods = map[string]bool{
+	proto.MetricsService_Delete_FullMethodName: true,
+	proto.MetricsService_Reset_FullMethodName:  true,
+	proto.MetricsService_Get_FullMethodName:    true,
+	proto.MetricsService_List_FullMethodName:   true,
+	proto.MetricsService_Query_FullMethodName:  true,
+	otlpExportMethod: true,
+}
+
 // DecryptInterceptor returns a grpc.UnaryServerInterceptor that decrypts the
-// request. If the private key is nil, the interceptor does nothing.
+// request. If the private key is nil, the interceptor does nothing. Requests
+// of the methods that can not carry encrypted data (e.g. OTLP exports) are
+// passed as is, any other requests are rejected.
 func DecryptInterceptor(privateKey *rsa.PrivateKey) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		if privateKey == nil {
@@ -29,6 +45,9 @@ func DecryptInterceptor(privateKey *rsa.PrivateKey) grpc.UnaryServerInterceptor
 		case *proto.BulkRequest:
 			in = r.GetData()
 		default:
+			if info != nil && plaintextMethods[info.FullMethod] {
+				return handler(ctx, req)
+			}
 			return nil, status.Error(codes.InvalidArgument, "invalid request type")
 		}
 
@@ -54,3 +73,48 @@ func DecryptInterceptor(privateKey *rsa.PrivateKey) grpc.UnaryServerInterceptor
 		return handler(ctx, m)
 	}
 }
+
+// DecryptStreamInterceptor returns a grpc.StreamServerInterceptor that
+// decrypts every batch received over the stream. If the private key is nil,
+// the interceptor does nothing. Requests that can not carry encrypted data
+// (e.g. watch requests) are passed as is.
+func DecryptStreamInterceptor(privateKey *rsa.PrivateKey) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if privateKey == nil {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &decryptedStream{ServerStream: ss, key: privateKey})
+	}
+}
+
+type decryptedStream struct {
+	grpc.ServerStream
+	key *rsa.PrivateKey
+}
+
+// RecvMsg implements grpc.ServerStream.
+func (s *decryptedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	r, ok := m.(*proto.StreamRequest)
+	if !ok {
+		return nil
+	}
+
+	mm, err := crypt.Decrypt(r.GetBatch().GetData(), s.key)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	batch := &proto.BulkRequest{}
+	if err := pb.Unmarshal(mm, batch); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	r.Batch = batch
+
+	return nil
+}
@@ -14,6 +14,10 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	pb "google.golang.org/protobuf/proto"
 
 	"github.com/nekr0z/muhame/internal/crypt"
@@ -124,6 +128,55 @@ func TestDecrypt(t *testing.T) {
 		assert.Equal(t, met.GetCounter().GetDelta(), mm[0].GetCounter().GetDelta())
 		assert.Equal(t, met.GetName(), mm[0].GetName())
 	})
+
+	t.Run("large bulk", func(t *testing.T) {
+		mm := make([]*proto.Metric, 1000)
+		for i := range mm {
+			mm[i] = met
+		}
+
+		message, err := pb.Marshal(&proto.BulkRequest{
+			Payload: &proto.BulkRequest_Metrics{
+				Metrics: &proto.Metrics{
+					Metrics: mm,
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		ciphermsg, err := crypt.Encrypt(message, &privateKey.PublicKey)
+		require.NoError(t, err)
+
+		req := &proto.BulkRequest{
+			Payload: &proto.BulkRequest_Data{
+				Data: ciphermsg,
+			},
+		}
+
+		res, err := interceptor(context.Background(), req, nil, handler)
+		require.NoError(t, err)
+
+		got, ok := res.(*proto.BulkRequest)
+		require.True(t, ok)
+		assert.Len(t, got.GetMetrics().GetMetrics(), len(mm))
+	})
+}
+
+func TestDecrypt_Plaintext(t *testing.T) {
+	interceptor := grpcserver.DecryptInterceptor(privateKey)
+
+	req := &proto.SeriesRequest{Series: []*proto.SeriesID{{Name: "test", Type: "counter"}}}
+
+	res, err := interceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: proto.MetricsService_Delete_FullMethodName}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, req, res)
+
+	res, err = interceptor(context.Background(), &colmetricspb.ExportMetricsServiceRequest{}, &grpc.UnaryServerInfo{FullMethod: "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"}, handler)
+	require.NoError(t, err)
+	assert.NotNil(t, res)
+
+	_, err = interceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: "/metrics.MetricsService/Unknown"}, handler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
 }
 
 func handler(_ context.Context, req any) (any, error) {
@@ -0,0 +1,72 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+// Delete implements the Delete method. The series that do not exist are
+// skipped.
+func (s *MetricsServer) Delete(ctx context.Context, in *proto.SeriesRequest) (*proto.SeriesResponse, error) {
+	d, ok := s.st.(deleter)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "deletion is not supported")
+	}
+
+	return eachSeries(ctx, in, d.Delete)
+}
+
+// Reset implements the Reset method. The series that do not exist are
+// skipped.
+func (s *MetricsServer) Reset(ctx context.Context, in *proto.SeriesRequest) (*proto.SeriesResponse, error) {
+	d, ok := s.st.(deleter)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "reset is not supported")
+	}
+
+	return eachSeries(ctx, in, d.Reset)
+}
+
+func eachSeries(ctx context.Context, in *proto.SeriesRequest, op func(ctx context.Context, t, name string, labels metrics.Labels) error) (*proto.SeriesResponse, error) {
+	if len(in.GetSeries()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no series provided")
+	}
+
+	for _, id := range in.GetSeries() {
+		if id.GetName() == "" || id.GetType() == "" {
+			return nil, status.Error(codes.InvalidArgument, "no metric name or type was provided")
+		}
+
+		if err := metrics.Labels(id.GetLabels()).Validate(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	res := &proto.SeriesResponse{}
+
+	for _, id := range in.GetSeries() {
+		err := op(ctx, id.GetType(), id.GetName(), metrics.Labels(id.GetLabels()))
+		if errors.Is(err, storage.ErrMetricNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		res.Affected++
+	}
+
+	return res, nil
+}
+
+type deleter interface {
+	Delete(ctx context.Context, t, name string, labels metrics.Labels) error
+	Reset(ctx context.Context, t, name string, labels metrics.Labels) error
+}
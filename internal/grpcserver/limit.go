@@ -0,0 +1,93 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/limit"
+)
+
+// LimitInterceptor returns a grpc.UnaryServerInterceptor that rejects the
+// calls of the clients over their rate with ResourceExhausted, and passes the
+// limit of metrics per bulk on in the context. The clients are told apart by
+// their API keys, or by their addresses if they have none. The address is that
+// of the peer unless the peer is one of the trusted proxies of the resolver, so
+// that the clients can not dodge the limit by making up the x-real-ip or
+// x-forwarded-for metadata. The size of the messages is limited with
+// grpc.MaxRecvMsgSize instead.
+func LimitInterceptor(l *limit.Limiter, keys auth.Keys, res clientip.Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if l.Rate > 0 && !l.Allow(limitClient(ctx, keys, res)) {
+			return nil, status.Error(codes.ResourceExhausted, limit.ErrRateLimited.Error())
+		}
+
+		if l.MaxBulk > 0 {
+			ctx = limit.WithMaxBulk(ctx, l.MaxBulk)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// LimitStreamInterceptor is the LimitInterceptor for the streaming methods.
+// Every message received over the stream counts against the rate as a call,
+// and the stream is ended with ResourceExhausted once the client is over it.
+func LimitStreamInterceptor(l *limit.Limiter, keys auth.Keys, res clientip.Resolver) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+
+		var client string
+		if l.Rate > 0 {
+			client = limitClient(ctx, keys, res)
+		}
+
+		if l.MaxBulk > 0 {
+			ctx = limit.WithMaxBulk(ctx, l.MaxBulk)
+		}
+
+		return handler(srv, &limitedStream{ServerStream: ss, ctx: ctx, l: l, client: client})
+	}
+}
+
+func limitClient(ctx context.Context, keys auth.Keys, res clientip.Resolver) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var header string
+	if v := md.Get(strings.ToLower(auth.Header)); len(v) > 0 {
+		header = v[0]
+	}
+
+	return limit.Client(keys, header, clientIP(ctx, res))
+}
+
+type limitedStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	l      *limit.Limiter
+	client string
+}
+
+// Context implements grpc.ServerStream.
+func (s *limitedStream) Context() context.Context {
+	return s.ctx
+}
+
+// RecvMsg implements grpc.ServerStream.
+func (s *limitedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if !s.l.Allow(s.client) {
+		return status.Error(codes.ResourceExhausted, limit.ErrRateLimited.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package grpcserver_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/grpcserver"
+	"github.com/nekr0z/muhame/internal/limit"
+	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+func TestLimit(t *testing.T) {
+	t.Parallel()
+
+	from := func(ip string) context.Context {
+		return metadata.NewOutgoingContext(context.Background(), metadata.Pairs(peerHeader, ip))
+	}
+
+	bulk := func(n int) *proto.BulkRequest {
+		mm := make([]*proto.Metric, n)
+		for i := range mm {
+			mm[i] = &proto.Metric{Name: "test", Value: &proto.Metric_Counter{Counter: &proto.Counter{Delta: 1}}}
+		}
+
+		return &proto.BulkRequest{Payload: &proto.BulkRequest_Metrics{Metrics: &proto.Metrics{Metrics: mm}}}
+	}
+
+	t.Run("rate", func(t *testing.T) {
+		t.Parallel()
+
+		l := limit.New(limit.Config{Rate: 0.001, Burst: 2})
+		cl := client(t, grpcserver.New(mockBU{}), grpc.ChainUnaryInterceptor(fakePeer, grpcserver.LimitInterceptor(l, nil, clientip.Resolver{})))
+
+		for i := 0; i < 2; i++ {
+			_, err := cl.BulkUpdate(from("10.0.0.1"), bulk(2))
+			require.NoError(t, err)
+		}
+
+		_, err := cl.BulkUpdate(from("10.0.0.1"), bulk(2))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err), err)
+
+		_, err = cl.BulkUpdate(from("10.0.0.2"), bulk(2))
+		assert.NoError(t, err, "other client")
+	})
+
+	t.Run("spoofed real IP", func(t *testing.T) {
+		t.Parallel()
+
+		l := limit.New(limit.Config{Rate: 0.001, Burst: 1})
+		cl := client(t, grpcserver.New(mockBU{}), grpc.ChainUnaryInterceptor(fakePeer, grpcserver.LimitInterceptor(l, nil, clientip.Resolver{})))
+
+		spoofed := func(realIP string) context.Context {
+			return metadata.NewOutgoingContext(context.Background(), metadata.Pairs(peerHeader, "192.0.2.1", "x-real-ip", realIP))
+		}
+
+		_, err := cl.BulkUpdate(spoofed("10.0.0.1"), bulk(2))
+		require.NoError(t, err)
+
+		_, err = cl.BulkUpdate(spoofed("10.0.0.2"), bulk(2))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err), err)
+	})
+
+	t.Run("bulk", func(t *testing.T) {
+		t.Parallel()
+
+		l := limit.New(limit.Config{MaxBulk: 2})
+		cl := client(t, grpcserver.New(mockBU{}), grpc.UnaryInterceptor(grpcserver.LimitInterceptor(l, nil, clientip.Resolver{})))
+
+		_, err := cl.BulkUpdate(context.Background(), bulk(2))
+		assert.NoError(t, err)
+
+		_, err = cl.BulkUpdate(context.Background(), bulk(3))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err), err)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+
+		st, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+		require.NoError(t, err)
+
+		l := limit.New(limit.Config{Rate: 0.001, Burst: 2, MaxBulk: 2})
+		cl := streamClient(t, grpcserver.New(st), []grpc.StreamServerInterceptor{
+			grpcserver.LimitStreamInterceptor(l, nil, clientip.Resolver{}),
+		}, nil)
+
+		stream, err := cl.Stream(context.Background())
+		require.NoError(t, err)
+
+		require.NoError(t, stream.Send(&proto.StreamRequest{Id: 1, Batch: bulk(2)}))
+		ack, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Empty(t, ack.GetError())
+
+		require.NoError(t, stream.Send(&proto.StreamRequest{Id: 2, Batch: bulk(3)}))
+		ack, err = stream.Recv()
+		require.NoError(t, err)
+		assert.Contains(t, ack.GetError(), limit.ErrTooLarge.Error())
+
+		require.NoError(t, stream.Send(&proto.StreamRequest{Id: 3, Batch: bulk(1)}))
+		_, err = stream.Recv()
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err), err)
+	})
+}
@@ -2,28 +2,49 @@ package grpcserver
 
 import (
 	"context"
+	"errors"
 	"strings"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/limit"
 	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/watch"
 	"github.com/nekr0z/muhame/pkg/proto"
 )
 
 // MetricsServer implements the grpc metrics service.
 type MetricsServer struct {
-	st Updater
+	st  Updater
+	hub *watch.Hub
 
 	proto.UnimplementedMetricsServiceServer
 }
 
+// Option configures the MetricsServer.
+type Option func(*MetricsServer)
+
+// WithHub makes the server stream the events of the hub to the watchers.
+func WithHub(h *watch.Hub) Option {
+	return func(s *MetricsServer) {
+		s.hub = h
+	}
+}
+
 // New returns a new MetricsServer.
-func New(st Updater) *MetricsServer {
-	return &MetricsServer{
+func New(st Updater, opts ...Option) *MetricsServer {
+	s := &MetricsServer{
 		st: st,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 // Update implements the Update method.
@@ -42,6 +63,9 @@ func (s *MetricsServer) Update(ctx context.Context, in *proto.MetricRequest) (*e
 	}
 
 	if err := s.st.Update(ctx, *m); err != nil {
+		if errors.Is(err, auth.ErrForbidden) {
+			return &emptypb.Empty{}, status.Error(codes.PermissionDenied, err.Error())
+		}
 		return &emptypb.Empty{}, status.Error(codes.Internal, err.Error())
 	}
 
@@ -67,6 +91,10 @@ func (s *MetricsServer) BulkUpdate(ctx context.Context, in *proto.BulkRequest) (
 		return &emptypb.Empty{}, status.Error(codes.InvalidArgument, "no metrics provided")
 	}
 
+	if err := limit.CheckBulk(ctx, len(in.GetMetrics().Metrics)); err != nil {
+		return &emptypb.Empty{}, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
 	var ms []metrics.Named
 	for _, m := range in.GetMetrics().Metrics {
 		nm, msg := fromProto(m)
@@ -78,6 +106,9 @@ func (s *MetricsServer) BulkUpdate(ctx context.Context, in *proto.BulkRequest) (
 	}
 
 	err := bu.BulkUpdate(ctx, ms)
+	if errors.Is(err, auth.ErrForbidden) {
+		return &emptypb.Empty{}, status.Error(codes.PermissionDenied, err.Error())
+	}
 	if err != nil {
 		msg := err.Error()
 		return &emptypb.Empty{}, status.Error(codes.Internal, msg)
@@ -92,7 +123,12 @@ func fromProto(in *proto.Metric) (*metrics.Named, string) {
 	}
 
 	m := metrics.Named{
-		Name: in.GetName(),
+		Name:   in.GetName(),
+		Labels: metrics.Labels(in.GetLabels()).Clone(),
+	}
+
+	if err := m.Labels.Validate(); err != nil {
+		return nil, err.Error()
 	}
 
 	switch in.GetValue().(type) {
@@ -100,6 +136,17 @@ func fromProto(in *proto.Metric) (*metrics.Named, string) {
 		m.Metric = metrics.Gauge(in.GetGauge().GetValue())
 	case *proto.Metric_Counter:
 		m.Metric = metrics.Counter(in.GetCounter().GetDelta())
+	case *proto.Metric_Histogram:
+		h := metrics.Histogram{
+			Bounds: in.GetHistogram().GetBounds(),
+			Counts: in.GetHistogram().GetCounts(),
+			Sum:    in.GetHistogram().GetSum(),
+			Count:  in.GetHistogram().GetCount(),
+		}
+		if err := h.Validate(); err != nil {
+			return nil, err.Error()
+		}
+		m.Metric = h
 	default:
 		return nil, "no metric of known type was provided"
 	}
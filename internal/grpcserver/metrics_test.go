@@ -10,6 +10,8 @@ import (
 	"go.uber.org/zap/zaptest"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/nekr0z/muhame/internal/grpcserver"
@@ -48,7 +50,7 @@ func TestUpdate(t *testing.T) {
 		})
 		assert.NoError(t, err)
 
-		metric, err := st.Get(ctx, metrics.Counter.Type(0), "testcounter")
+		metric, err := st.Get(ctx, metrics.Counter.Type(0), "testcounter", nil)
 		assert.NoError(t, err)
 		assert.Equal(t, metrics.Counter(1), metric)
 	})
@@ -75,11 +77,95 @@ func TestUpdate(t *testing.T) {
 		})
 		assert.NoError(t, err)
 
-		metric, err := st.Get(ctx, metrics.Gauge.Type(0), "testgauge")
+		metric, err := st.Get(ctx, metrics.Gauge.Type(0), "testgauge", nil)
 		assert.NoError(t, err)
 		assert.Equal(t, metrics.Gauge(1.15), metric)
 	})
 
+	t.Run("happy path histogram", func(t *testing.T) {
+		t.Parallel()
+
+		s := grpcserver.New(st)
+		cl := client(t, s)
+
+		m := &proto.Metric{
+			Name: "testhistogram",
+			Value: &proto.Metric_Histogram{
+				Histogram: &proto.Histogram{
+					Bounds: []float64{1},
+					Counts: []uint64{2, 1},
+					Sum:    3.5,
+					Count:  3,
+				},
+			},
+		}
+
+		_, err := cl.Update(ctx, &proto.MetricRequest{
+			Payload: &proto.MetricRequest_Metric{
+				Metric: m,
+			},
+		})
+		assert.NoError(t, err)
+
+		metric, err := st.Get(ctx, metrics.Histogram{}.Type(), "testhistogram", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, metrics.Histogram{
+			Bounds: []float64{1},
+			Counts: []uint64{2, 1},
+			Sum:    3.5,
+			Count:  3,
+		}, metric)
+	})
+
+	t.Run("labels", func(t *testing.T) {
+		t.Parallel()
+
+		s := grpcserver.New(st)
+		cl := client(t, s)
+
+		_, err := cl.Update(ctx, &proto.MetricRequest{
+			Payload: &proto.MetricRequest_Metric{
+				Metric: &proto.Metric{
+					Name:   "labelledgauge",
+					Labels: map[string]string{"host": "a"},
+					Value: &proto.Metric_Gauge{
+						Gauge: &proto.Gauge{
+							Value: 2.5,
+						},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+
+		metric, err := st.Get(ctx, metrics.Gauge.Type(0), "labelledgauge", metrics.Labels{"host": "a"})
+		assert.NoError(t, err)
+		assert.Equal(t, metrics.Gauge(2.5), metric)
+
+		_, err = st.Get(ctx, metrics.Gauge.Type(0), "labelledgauge", nil)
+		assert.ErrorIs(t, err, storage.ErrMetricNotFound)
+	})
+
+	t.Run("bad histogram", func(t *testing.T) {
+		t.Parallel()
+
+		s := grpcserver.New(st)
+		cl := client(t, s)
+
+		_, err := cl.Update(ctx, &proto.MetricRequest{
+			Payload: &proto.MetricRequest_Metric{
+				Metric: &proto.Metric{
+					Name: "badhistogram",
+					Value: &proto.Metric_Histogram{
+						Histogram: &proto.Histogram{
+							Bounds: []float64{1},
+						},
+					},
+				},
+			}})
+		assert.Error(t, err)
+	})
+
 	t.Run("nil request", func(t *testing.T) {
 		t.Parallel()
 
@@ -330,3 +416,17 @@ func client(t *testing.T, ms *grpcserver.MetricsServer, opts ...grpc.ServerOptio
 
 	return proto.NewMetricsServiceClient(conn)
 }
+
+// peerHeader is the metadata the test clients put the address they pretend to
+// call from in, since the bufconn connections have no addresses.
+const peerHeader = "x-test-peer"
+
+// fakePeer makes the call come from the address in the peerHeader metadata.
+func fakePeer(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(peerHeader); len(v) > 0 {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(v[0]), Port: 1234}})
+	}
+
+	return handler(ctx, req)
+}
@@ -0,0 +1,43 @@
+package grpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/hash"
+)
+
+// PrivilegedInterceptor returns a grpc.UnaryServerInterceptor that only lets
+// the calls of the methods through if they come from the trusted subnets, and
+// none at all unless both a signing key and the subnets are set. The
+// interceptor is meant to be chained with the SignatureInterceptor, that makes
+// sure the calls are signed. The client address is resolved by the resolver,
+// see TrustedInterceptor.
+func PrivilegedInterceptor(keys hash.Keys, subnet string, res clientip.Resolver, methods ...string) grpc.UnaryServerInterceptor {
+	privileged := make(map[string]struct{}, len(methods))
+	for _, m := range methods {
+		privileged[m] = struct{}{}
+	}
+
+	trusted := trustedCheck(subnet, res)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := privileged[info.FullMethod]; !ok {
+			return handler(ctx, req)
+		}
+
+		if !keys.Enabled() || subnet == "" {
+			return nil, status.Error(codes.PermissionDenied, "requires signing key and trusted subnet to be set")
+		}
+
+		if err := trusted(ctx); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
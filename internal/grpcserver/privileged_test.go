@@ -0,0 +1,135 @@
+package grpcserver_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	pb "google.golang.org/protobuf/proto"
+
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/grpcserver"
+	"github.com/nekr0z/muhame/internal/hash"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+func TestPrivileged(t *testing.T) {
+	t.Parallel()
+
+	const (
+		key    = "testkey"
+		subnet = "10.0.0.0/8"
+	)
+
+	req := &proto.SeriesRequest{
+		Series: []*proto.SeriesID{
+			{Name: "test", Type: "counter"},
+			{Name: "test", Type: "gauge", Labels: map[string]string{"host": "a"}},
+			{Name: "missing", Type: "gauge"},
+		},
+	}
+
+	b, err := pb.Marshal(req)
+	require.NoError(t, err)
+
+	sig := hash.Signature(b, key)
+
+	tests := []struct {
+		name   string
+		key    string
+		subnet string
+		ip     string
+		realIP string
+		sig    string
+		want   codes.Code
+	}{
+		{name: "ok", key: key, subnet: subnet, ip: "10.1.2.3", sig: sig, want: codes.OK},
+		{name: "untrusted", key: key, subnet: subnet, ip: "192.168.1.1", sig: sig, want: codes.PermissionDenied},
+		{name: "no address", key: key, subnet: subnet, sig: sig, want: codes.PermissionDenied},
+		{name: "spoofed real IP", key: key, subnet: subnet, ip: "192.168.1.1", realIP: "10.1.2.3", sig: sig, want: codes.PermissionDenied},
+		{name: "unsigned", key: key, subnet: subnet, ip: "10.1.2.3", want: codes.InvalidArgument},
+		{name: "no key", subnet: subnet, ip: "10.1.2.3", want: codes.PermissionDenied},
+		{name: "no subnet", key: key, ip: "10.1.2.3", sig: sig, want: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			st, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+			require.NoError(t, err)
+
+			require.NoError(t, st.Update(ctx, metrics.Named{Name: "test", Metric: metrics.Counter(5)}))
+			require.NoError(t, st.Update(ctx, metrics.Named{Name: "test", Labels: metrics.Labels{"host": "a"}, Metric: metrics.Gauge(1)}))
+
+			cl := client(t, grpcserver.New(st), grpc.ChainUnaryInterceptor(
+				fakePeer,
+				grpcserver.PrivilegedInterceptor(hash.Keys{Legacy: tt.key}, tt.subnet, clientip.Resolver{},
+					proto.MetricsService_Delete_FullMethodName,
+					proto.MetricsService_Reset_FullMethodName,
+				),
+				grpcserver.SignatureInterceptor(hash.Keys{Legacy: tt.key}, nil),
+			))
+
+			md := metadata.Pairs()
+			if tt.ip != "" {
+				md.Set(peerHeader, tt.ip)
+			}
+			if tt.realIP != "" {
+				md.Set("X-Real-IP", tt.realIP)
+			}
+			if tt.sig != "" {
+				md.Set(hash.Header, tt.sig)
+			}
+			ctx = metadata.NewOutgoingContext(ctx, md)
+
+			res, err := cl.Reset(ctx, req)
+			assert.Equal(t, tt.want, status.Code(err), err)
+
+			if tt.want != codes.OK {
+				m, err := st.Get(ctx, "counter", "test", nil)
+				assert.NoError(t, err)
+				assert.Equal(t, metrics.Counter(5), m)
+				return
+			}
+
+			assert.Equal(t, int64(2), res.GetAffected())
+
+			m, err := st.Get(ctx, "counter", "test", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, metrics.Counter(0), m)
+
+			res, err = cl.Delete(ctx, req)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), res.GetAffected())
+
+			_, err = st.Get(ctx, "gauge", "test", metrics.Labels{"host": "a"})
+			assert.ErrorIs(t, err, storage.ErrMetricNotFound)
+		})
+	}
+}
+
+func TestDelete_BadRequest(t *testing.T) {
+	t.Parallel()
+
+	st, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	cl := client(t, grpcserver.New(st))
+
+	_, err = cl.Delete(context.Background(), &proto.SeriesRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = cl.Delete(context.Background(), &proto.SeriesRequest{Series: []*proto.SeriesID{{Name: "test"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
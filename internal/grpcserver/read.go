@@ -0,0 +1,252 @@
+package grpcserver
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+const (
+	// defaultPageSize is used when the List request sets no page size.
+	defaultPageSize = 100
+	// maxPageSize is the most metrics the List call returns at once.
+	maxPageSize = 1000
+)
+
+// Get implements the Get method.
+func (s *MetricsServer) Get(ctx context.Context, in *proto.SeriesID) (*proto.GetResponse, error) {
+	d, ok := s.st.(describer)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "reading is not supported")
+	}
+
+	if in.GetName() == "" || in.GetType() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name and type are required")
+	}
+
+	labels := metrics.Labels(in.GetLabels())
+	if err := labels.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	series, err := d.Describe(ctx, in.GetType(), in.GetName(), labels)
+	if errors.Is(err, storage.ErrMetricNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	res := &proto.GetResponse{
+		Metric: toProto(series.Named),
+		Source: series.Source,
+	}
+	if !series.Updated.IsZero() {
+		res.Updated = timestamppb.New(series.Updated)
+	}
+
+	return res, nil
+}
+
+// List implements the List method. The metrics are sorted by type, name and
+// labels; the page token of the response, if not empty, is to be passed in the
+// next request to get the next page.
+func (s *MetricsServer) List(ctx context.Context, in *proto.ListRequest) (*proto.ListResponse, error) {
+	l, ok := s.st.(lister)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "reading is not supported")
+	}
+
+	filter := metrics.Labels(in.GetLabels())
+	if err := filter.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	size := int(in.GetPageSize())
+	switch {
+	case size < 0:
+		return nil, status.Error(codes.InvalidArgument, "negative page size")
+	case size == 0:
+		size = defaultPageSize
+	case size > maxPageSize:
+		size = maxPageSize
+	}
+
+	after, err := decodePageToken(in.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "bad page token")
+	}
+
+	mm, err := l.List(ctx, filter)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	type keyed struct {
+		key string
+		m   metrics.Named
+	}
+
+	var kk []keyed
+	for _, m := range mm {
+		if in.GetName() != "" && m.Name != in.GetName() {
+			continue
+		}
+		if in.GetType() != "" && m.Type() != in.GetType() {
+			continue
+		}
+
+		k := pageKey(m)
+		if after != "" && k <= after {
+			continue
+		}
+
+		kk = append(kk, keyed{key: k, m: m})
+	}
+
+	slices.SortFunc(kk, func(a, b keyed) int {
+		return cmp.Compare(a.key, b.key)
+	})
+
+	res := &proto.ListResponse{}
+
+	if len(kk) > size {
+		kk = kk[:size]
+		res.NextPageToken = encodePageToken(kk[size-1].key)
+	}
+
+	for _, k := range kk {
+		res.Metrics = append(res.Metrics, toProto(k.m))
+	}
+
+	return res, nil
+}
+
+// Query implements the Query method. The range is the last hour unless set,
+// and the samples are downsampled to one per step if the step is set.
+func (s *MetricsServer) Query(ctx context.Context, in *proto.QueryRequest) (*proto.QueryResponse, error) {
+	rq, ok := s.st.(rangeQuerier)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, storage.ErrHistoryDisabled.Error())
+	}
+
+	series := in.GetSeries()
+	if series.GetName() == "" || series.GetType() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name and type are required")
+	}
+
+	labels := metrics.Labels(series.GetLabels())
+	if err := labels.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	to := time.Now()
+	if in.GetTo() != nil {
+		to = in.GetTo().AsTime()
+	}
+
+	from := to.Add(-storage.DefaultRange)
+	if in.GetFrom() != nil {
+		from = in.GetFrom().AsTime()
+	}
+
+	if from.After(to) {
+		return nil, status.Error(codes.InvalidArgument, "from is after to")
+	}
+
+	var step time.Duration
+	if in.GetStep() != nil {
+		step = in.GetStep().AsDuration()
+		if step <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "step must be positive")
+		}
+
+		if to.Sub(from)/step >= storage.MaxPoints {
+			return nil, status.Error(codes.InvalidArgument, "too many points, increase step")
+		}
+	}
+
+	ss, err := rq.Range(ctx, series.GetType(), series.GetName(), labels, from, to)
+	switch {
+	case errors.Is(err, storage.ErrMetricNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrHistoryDisabled):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if step > 0 {
+		ss = storage.Downsample(ss, from, to, step)
+	}
+
+	res := &proto.QueryResponse{}
+	for _, s := range ss {
+		res.Samples = append(res.Samples, &proto.Sample{
+			Time:  timestamppb.New(s.Time),
+			Value: s.Value,
+		})
+	}
+
+	return res, nil
+}
+
+func pageKey(m metrics.Named) string {
+	return strings.Join([]string{m.Type(), m.Name, m.Labels.String()}, "\x00")
+}
+
+func encodePageToken(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodePageToken(token string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	return string(b), err
+}
+
+func toProto(m metrics.Named) *proto.Metric {
+	p := &proto.Metric{
+		Name:   m.Name,
+		Labels: m.Labels,
+	}
+
+	switch v := m.Metric.(type) {
+	case metrics.Gauge:
+		p.Value = &proto.Metric_Gauge{Gauge: &proto.Gauge{Value: float64(v)}}
+	case metrics.Counter:
+		p.Value = &proto.Metric_Counter{Counter: &proto.Counter{Delta: int64(v)}}
+	case metrics.Histogram:
+		p.Value = &proto.Metric_Histogram{Histogram: &proto.Histogram{
+			Bounds: v.Bounds,
+			Counts: v.Counts,
+			Sum:    v.Sum,
+			Count:  v.Count,
+		}}
+	}
+
+	return p
+}
+
+type describer interface {
+	Describe(ctx context.Context, t, name string, labels metrics.Labels) (storage.Series, error)
+}
+
+type lister interface {
+	List(ctx context.Context, filter metrics.Labels) ([]metrics.Named, error)
+}
+
+type rangeQuerier interface {
+	Range(ctx context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error)
+}
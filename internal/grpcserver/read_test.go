@@ -0,0 +1,158 @@
+package grpcserver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/nekr0z/muhame/internal/grpcserver"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	require.NoError(t, st.Update(storage.WithSource(ctx, "10.0.0.7"), metrics.Named{
+		Name:   "load",
+		Labels: metrics.Labels{"host": "a"},
+		Metric: metrics.Gauge(1.5),
+	}))
+
+	cl := client(t, grpcserver.New(st))
+
+	res, err := cl.Get(ctx, &proto.SeriesID{Name: "load", Type: "gauge", Labels: map[string]string{"host": "a"}})
+	require.NoError(t, err)
+	assert.Equal(t, "load", res.GetMetric().GetName())
+	assert.Equal(t, map[string]string{"host": "a"}, res.GetMetric().GetLabels())
+	assert.Equal(t, 1.5, res.GetMetric().GetGauge().GetValue())
+	assert.Equal(t, "10.0.0.7", res.GetSource())
+	assert.WithinDuration(t, time.Now(), res.GetUpdated().AsTime(), time.Minute)
+
+	_, err = cl.Get(ctx, &proto.SeriesID{Name: "load", Type: "gauge"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = cl.Get(ctx, &proto.SeriesID{Name: "load"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	for _, m := range []metrics.Named{
+		{Name: "load", Labels: metrics.Labels{"host": "a"}, Metric: metrics.Gauge(1)},
+		{Name: "load", Labels: metrics.Labels{"host": "b"}, Metric: metrics.Gauge(2)},
+		{Name: "load", Labels: metrics.Labels{"host": "c"}, Metric: metrics.Gauge(3)},
+		{Name: "requests", Labels: metrics.Labels{"host": "a"}, Metric: metrics.Counter(4)},
+		{Name: "free", Metric: metrics.Gauge(5)},
+	} {
+		require.NoError(t, st.Update(ctx, m))
+	}
+
+	cl := client(t, grpcserver.New(st))
+
+	names := func(res *proto.ListResponse) []string {
+		var ss []string
+		for _, m := range res.GetMetrics() {
+			ss = append(ss, m.GetName()+metrics.Labels(m.GetLabels()).Pretty())
+		}
+		return ss
+	}
+
+	res, err := cl.List(ctx, &proto.ListRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"requests{host=\"a\"}", "free", "load{host=\"a\"}", "load{host=\"b\"}", "load{host=\"c\"}"}, names(res))
+	assert.Empty(t, res.GetNextPageToken())
+
+	res, err = cl.List(ctx, &proto.ListRequest{Type: "gauge", Labels: map[string]string{"host": "a"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"load{host=\"a\"}"}, names(res))
+
+	var got []string
+	req := &proto.ListRequest{Name: "load", PageSize: 2}
+	for {
+		res, err = cl.List(ctx, req)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(res.GetMetrics()), 2)
+
+		got = append(got, names(res)...)
+		if res.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = res.GetNextPageToken()
+	}
+	assert.Equal(t, []string{"load{host=\"a\"}", "load{host=\"b\"}", "load{host=\"c\"}"}, got)
+
+	_, err = cl.List(ctx, &proto.ListRequest{PageToken: "!"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	st, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	cl := client(t, grpcserver.New(st))
+
+	_, err = cl.Query(ctx, &proto.QueryRequest{Series: &proto.SeriesID{Name: "load", Type: "gauge"}})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	st, err = storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true, History: time.Hour})
+	require.NoError(t, err)
+
+	from := time.Now().Add(-time.Minute)
+
+	for _, v := range []float64{1, 2, 3} {
+		require.NoError(t, st.Update(ctx, metrics.Named{Name: "load", Metric: metrics.Gauge(v)}))
+	}
+
+	cl = client(t, grpcserver.New(st))
+
+	res, err := cl.Query(ctx, &proto.QueryRequest{Series: &proto.SeriesID{Name: "load", Type: "gauge"}})
+	require.NoError(t, err)
+	require.Len(t, res.GetSamples(), 3)
+	assert.Equal(t, 3.0, res.GetSamples()[2].GetValue())
+
+	to := from.Add(2 * time.Minute)
+
+	res, err = cl.Query(ctx, &proto.QueryRequest{
+		Series: &proto.SeriesID{Name: "load", Type: "gauge"},
+		From:   timestamppb.New(from),
+		To:     timestamppb.New(to),
+		Step:   durationpb.New(2 * time.Minute),
+	})
+	require.NoError(t, err)
+	require.Len(t, res.GetSamples(), 1)
+	assert.Equal(t, 3.0, res.GetSamples()[0].GetValue())
+	assert.True(t, to.Equal(res.GetSamples()[0].GetTime().AsTime()))
+
+	_, err = cl.Query(ctx, &proto.QueryRequest{Series: &proto.SeriesID{Name: "nope", Type: "gauge"}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = cl.Query(ctx, &proto.QueryRequest{
+		Series: &proto.SeriesID{Name: "load", Type: "gauge"},
+		From:   timestamppb.New(to),
+		To:     timestamppb.New(from),
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -2,6 +2,7 @@ package grpcserver
 
 import (
 	"context"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -10,31 +11,39 @@ import (
 	pb "google.golang.org/protobuf/proto"
 
 	"github.com/nekr0z/muhame/internal/hash"
+	"github.com/nekr0z/muhame/internal/replay"
 	"github.com/nekr0z/muhame/pkg/proto"
 )
 
 // SignatureInterceptor returns a grpc.UnaryServerInterceptor that verifies the
-// signature of the request. If the key is empty, the interceptor does nothing.
-func SignatureInterceptor(key string) grpc.UnaryServerInterceptor {
+// signature of the request, made with the key of the ID in the metadata or
+// with the legacy key if there is no ID. If the signature is stamped, the
+// guard checks the stamp has not been seen before. If there are no keys, the
+// interceptor does nothing.
+//
+// The OTLP exporters can not sign their requests, so, like on the HTTP
+// /v1/metrics endpoint, the unsigned OTLP exports are let through unless the
+// guard requires the stamps.
+func SignatureInterceptor(keys hash.Keys, g *replay.Guard) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		if key == "" {
+		if !keys.Enabled() {
 			return handler(ctx, req)
 		}
 
-		var (
-			in  []byte
-			err error
-		)
+		if info != nil && info.FullMethod == otlpExportMethod && len(metadata.ValueFromIncomingContext(ctx, hash.Header)) == 0 {
+			if err := g.Unstamped(); err != nil {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
 
-		switch r := req.(type) {
-		case *proto.MetricRequest:
-			in, err = pb.Marshal(r)
-		case *proto.BulkRequest:
-			in, err = pb.Marshal(r)
-		default:
+			return handler(ctx, req)
+		}
+
+		r, ok := req.(pb.Message)
+		if !ok {
 			return nil, status.Error(codes.InvalidArgument, "invalid request type")
 		}
 
+		in, err := pb.MarshalOptions{Deterministic: true}.Marshal(r)
 		if err != nil {
 			return nil, status.Error(codes.InvalidArgument, "failed to marshal request")
 		}
@@ -49,10 +58,123 @@ func SignatureInterceptor(key string) grpc.UnaryServerInterceptor {
 			return nil, status.Error(codes.InvalidArgument, "invalid signature")
 		}
 
-		if sig[0] != hash.Signature(in, key) {
-			return nil, status.Error(codes.Unauthenticated, "invalid signature")
+		var stamp hash.Stamp
+		if ts := md.Get(hash.TimestampHeader); len(ts) > 0 {
+			var nonce string
+			if n := md.Get(hash.NonceHeader); len(n) > 0 {
+				nonce = n[0]
+			}
+
+			stamp, err = hash.ParseStamp(ts[0], nonce)
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, "invalid signature timestamp")
+			}
+		}
+
+		var id string
+		if v := md.Get(hash.KeyIDHeader); len(v) > 0 {
+			id = v[0]
+		}
+
+		if err := verify(in, sig[0], id, stamp, keys, g); err != nil {
+			return nil, err
 		}
 
 		return handler(ctx, req)
 	}
 }
+
+// SignatureStreamInterceptor returns a grpc.StreamServerInterceptor that
+// verifies the signature of every batch received over the stream, as well as
+// that of the watch request, checking the stamps with the guard like the
+// SignatureInterceptor does. If there are no keys, the interceptor does
+// nothing.
+func SignatureStreamInterceptor(keys hash.Keys, g *replay.Guard) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !keys.Enabled() {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &signedStream{ServerStream: ss, keys: keys, g: g})
+	}
+}
+
+type signedStream struct {
+	grpc.ServerStream
+	keys hash.Keys
+	g    *replay.Guard
+}
+
+// RecvMsg implements grpc.ServerStream.
+func (s *signedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	var (
+		signed    pb.Message
+		signature string
+		id        string
+		stamp     hash.Stamp
+	)
+
+	switch r := m.(type) {
+	case *proto.StreamRequest:
+		signed, signature, id = r.GetBatch(), r.GetSignature(), r.GetKeyId()
+		stamp = messageStamp(r.GetTimestamp(), r.GetNonce())
+	case *proto.WatchRequest:
+		c := pb.Clone(r).(*proto.WatchRequest)
+		c.Signature = ""
+		signed, signature, id = c, r.GetSignature(), r.GetKeyId()
+		stamp = messageStamp(r.GetTimestamp(), r.GetNonce())
+	default:
+		return status.Error(codes.InvalidArgument, "invalid request type")
+	}
+
+	if signature == "" {
+		return status.Error(codes.InvalidArgument, "missing signature")
+	}
+
+	in, err := pb.MarshalOptions{Deterministic: true}.Marshal(signed)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "failed to marshal request")
+	}
+
+	return verify(in, signature, id, stamp, s.keys, s.g)
+}
+
+// verify checks the signature of the message made with the key with the ID,
+// stamped if the stamp is not zero.
+func verify(msg []byte, sig, id string, stamp hash.Stamp, keys hash.Keys, g *replay.Guard) error {
+	stamped := stamp != (hash.Stamp{})
+
+	if !stamped {
+		if err := g.Unstamped(); err != nil {
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
+	}
+
+	if err := keys.Verify(msg, sig, id, stamp); err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if !stamped {
+		return nil
+	}
+
+	if err := g.Check(stamp); err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return nil
+}
+
+// messageStamp returns the stamp carried in the fields of a message, or the
+// zero stamp if there is none.
+func messageStamp(timestamp int64, nonce string) hash.Stamp {
+	if timestamp == 0 {
+		return hash.Stamp{}
+	}
+
+	return hash.Stamp{Time: time.Unix(timestamp, 0), Nonce: nonce}
+}
@@ -2,18 +2,27 @@ package grpcserver_test
 
 import (
 	"context"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
 	"go.uber.org/zap/zaptest"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 	pb "google.golang.org/protobuf/proto"
 
+	"github.com/nekr0z/muhame/internal/grpcclient"
 	"github.com/nekr0z/muhame/internal/grpcserver"
 	"github.com/nekr0z/muhame/internal/hash"
 	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/replay"
 	"github.com/nekr0z/muhame/internal/storage"
 	"github.com/nekr0z/muhame/pkg/proto"
 )
@@ -32,7 +41,7 @@ func TestSignature(t *testing.T) {
 		require.NoError(t, err)
 
 		srv := grpcserver.New(st)
-		cl := client(t, srv, grpc.UnaryInterceptor(grpcserver.SignatureInterceptor(key)))
+		cl := client(t, srv, grpc.UnaryInterceptor(grpcserver.SignatureInterceptor(hash.Keys{Legacy: key}, nil)))
 
 		mr := &proto.MetricRequest{
 			Payload: &proto.MetricRequest_Metric{
@@ -52,7 +61,7 @@ func TestSignature(t *testing.T) {
 			_, err := cl.Update(ctx, mr)
 			assert.Error(t, err)
 
-			r, err := st.Get(context.Background(), metrics.Counter.Type(0), "test")
+			r, err := st.Get(context.Background(), metrics.Counter.Type(0), "test", nil)
 			assert.Error(t, err, r)
 		})
 
@@ -61,7 +70,7 @@ func TestSignature(t *testing.T) {
 			_, err := cl.Update(ctx, mr)
 			assert.Error(t, err)
 
-			r, err := st.Get(context.Background(), metrics.Counter.Type(0), "test")
+			r, err := st.Get(context.Background(), metrics.Counter.Type(0), "test", nil)
 			assert.Error(t, err, r)
 		})
 
@@ -70,7 +79,7 @@ func TestSignature(t *testing.T) {
 			_, err := cl.Update(ctx, mr)
 			assert.Error(t, err)
 
-			r, err := st.Get(context.Background(), metrics.Counter.Type(0), "test")
+			r, err := st.Get(context.Background(), metrics.Counter.Type(0), "test", nil)
 			assert.Error(t, err, r)
 		})
 
@@ -85,7 +94,7 @@ func TestSignature(t *testing.T) {
 			_, err = cl.Update(ctx, mr)
 			assert.NoError(t, err)
 
-			r, err := st.Get(context.Background(), metrics.Counter.Type(0), "test")
+			r, err := st.Get(context.Background(), metrics.Counter.Type(0), "test", nil)
 			assert.NoError(t, err, r)
 
 			assert.Equal(t, metrics.Counter(1), r)
@@ -95,7 +104,7 @@ func TestSignature(t *testing.T) {
 
 	t.Run("bulk update", func(t *testing.T) {
 		srv := grpcserver.New(mockBU{})
-		cl := client(t, srv, grpc.UnaryInterceptor(grpcserver.SignatureInterceptor(key)))
+		cl := client(t, srv, grpc.UnaryInterceptor(grpcserver.SignatureInterceptor(hash.Keys{Legacy: key}, nil)))
 		mr := &proto.BulkRequest{
 			Payload: &proto.BulkRequest_Metrics{
 				Metrics: &proto.Metrics{
@@ -132,3 +141,181 @@ func TestSignature(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestSignature_Labels(t *testing.T) {
+	t.Parallel()
+
+	signer := hash.Signer{ID: "new", Key: "newkey"}
+	keys := hash.Keys{HMAC: map[string]string{"new": "newkey"}}
+
+	cl := unaryClient(t, grpcserver.New(mockBU{}),
+		grpcserver.SignatureInterceptor(keys, nil),
+		grpcclient.SignatureInterceptor(signer),
+	)
+
+	labels := map[string]string{"host": "a", "region": "eu", "dc": "1", "rack": "7", "env": "prod"}
+
+	req := &proto.BulkRequest{
+		Payload: &proto.BulkRequest_Metrics{
+			Metrics: &proto.Metrics{
+				Metrics: []*proto.Metric{
+					{Name: "test", Labels: labels, Value: &proto.Metric_Counter{Counter: &proto.Counter{Delta: 1}}},
+					{Name: "test2", Labels: labels, Value: &proto.Metric_Gauge{Gauge: &proto.Gauge{Value: 1}}},
+				},
+			},
+		},
+	}
+
+	for range 20 {
+		_, err := cl.BulkUpdate(context.Background(), req)
+		require.NoError(t, err)
+	}
+}
+
+func TestSignature_OTLP(t *testing.T) {
+	t.Parallel()
+
+	keys := hash.Keys{Legacy: "testkey"}
+	export := &grpc.UnaryServerInfo{FullMethod: "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"}
+	req := &colmetricspb.ExportMetricsServiceRequest{}
+
+	_, err := grpcserver.SignatureInterceptor(keys, nil)(context.Background(), req, export, handler)
+	assert.NoError(t, err, "unsigned export")
+
+	_, err = grpcserver.SignatureInterceptor(keys, replay.New(time.Minute, 0, true))(context.Background(), req, export, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "unsigned export with stamps required")
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(hash.Header, "bad"))
+	_, err = grpcserver.SignatureInterceptor(keys, nil)(ctx, req, export, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "badly signed export")
+
+	other := &grpc.UnaryServerInfo{FullMethod: proto.MetricsService_BulkUpdate_FullMethodName}
+	_, err = grpcserver.SignatureInterceptor(keys, nil)(context.Background(), &proto.BulkRequest{}, other, handler)
+	assert.Error(t, err, "unsigned bulk update")
+}
+
+func TestSignature_Replay(t *testing.T) {
+	t.Parallel()
+
+	const key = "testkey"
+
+	st, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	g := replay.New(time.Minute, 0, true)
+	cl := client(t, grpcserver.New(st), grpc.UnaryInterceptor(grpcserver.SignatureInterceptor(hash.Keys{Legacy: key}, g)))
+
+	mr := &proto.MetricRequest{
+		Payload: &proto.MetricRequest_Metric{
+			Metric: &proto.Metric{
+				Name:  "test",
+				Value: &proto.Metric_Counter{Counter: &proto.Counter{Delta: 1}},
+			},
+		},
+	}
+
+	in, err := pb.Marshal(mr)
+	require.NoError(t, err)
+
+	stamped := func(s hash.Stamp) context.Context {
+		return metadata.NewOutgoingContext(context.Background(), metadata.Pairs(
+			hash.Header, s.Signature(in, key),
+			hash.TimestampHeader, s.Timestamp(),
+			hash.NonceHeader, s.Nonce,
+		))
+	}
+
+	stamp := hash.NewStamp()
+
+	_, err = cl.Update(stamped(stamp), mr)
+	assert.NoError(t, err)
+
+	_, err = cl.Update(stamped(stamp), mr)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "replayed")
+
+	_, err = cl.Update(stamped(hash.Stamp{Time: time.Now().Add(-time.Hour), Nonce: "old"}), mr)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "stale")
+
+	_, err = cl.Update(metadata.NewOutgoingContext(context.Background(), metadata.Pairs(hash.Header, hash.Signature(in, key))), mr)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "not stamped")
+
+	r, err := st.Get(context.Background(), "counter", "test", nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Counter(1), r)
+}
+
+func TestSignature_Rotation(t *testing.T) {
+	t.Parallel()
+
+	st, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	keys := hash.Keys{HMAC: map[string]string{"old": "oldkey", "new": "newkey"}}
+	cl := client(t, grpcserver.New(st), grpc.UnaryInterceptor(grpcserver.SignatureInterceptor(keys, nil)))
+
+	mr := &proto.MetricRequest{
+		Payload: &proto.MetricRequest_Metric{
+			Metric: &proto.Metric{
+				Name:  "test",
+				Value: &proto.Metric_Counter{Counter: &proto.Counter{Delta: 1}},
+			},
+		},
+	}
+
+	in, err := pb.Marshal(mr)
+	require.NoError(t, err)
+
+	signed := func(s hash.Signer) context.Context {
+		return metadata.NewOutgoingContext(context.Background(), metadata.Pairs(
+			hash.Header, s.Sign(in, hash.Stamp{}),
+			hash.KeyIDHeader, s.ID,
+		))
+	}
+
+	_, err = cl.Update(signed(hash.Signer{ID: "old", Key: "oldkey"}), mr)
+	assert.NoError(t, err, "old key")
+
+	_, err = cl.Update(signed(hash.Signer{ID: "new", Key: "newkey"}), mr)
+	assert.NoError(t, err, "new key")
+
+	_, err = cl.Update(signed(hash.Signer{ID: "new", Key: "oldkey"}), mr)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "wrong key")
+
+	_, err = cl.Update(signed(hash.Signer{ID: "older", Key: "olderkey"}), mr)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "retired key")
+
+	_, err = cl.Update(metadata.NewOutgoingContext(context.Background(), metadata.Pairs(hash.Header, hash.Signature(in, ""))), mr)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "no legacy key")
+
+	r, err := st.Get(context.Background(), "counter", "test", nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Counter(2), r)
+}
+
+func unaryClient(t *testing.T, ms *grpcserver.MetricsServer, server grpc.UnaryServerInterceptor, client grpc.UnaryClientInterceptor) proto.MetricsServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(grpc.UnaryInterceptor(server))
+
+	proto.RegisterMetricsServiceServer(s, ms)
+
+	go func() {
+		err := s.Serve(lis)
+		require.NoError(t, err)
+	}()
+
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithUnaryInterceptor(client))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		err := conn.Close()
+		assert.NoError(t, err)
+	})
+
+	return proto.NewMetricsServiceClient(conn)
+}
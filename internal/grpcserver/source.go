@@ -0,0 +1,47 @@
+package grpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+// SourceInterceptor returns a grpc.UnaryServerInterceptor that records the
+// address of the client in the context, so that the storage knows where the
+// updates come from. The address is resolved by the resolver, see
+// TrustedInterceptor.
+func SourceInterceptor(res clientip.Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if ip := clientIP(ctx, res); ip != nil {
+			ctx = storage.WithSource(ctx, ip.String())
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// SourceStreamInterceptor is the grpc.StreamServerInterceptor counterpart of
+// the SourceInterceptor.
+func SourceStreamInterceptor(res clientip.Resolver) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		if ip := clientIP(ctx, res); ip != nil {
+			ctx = storage.WithSource(ctx, ip.String())
+		}
+
+		return handler(srv, &sourcedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type sourcedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context implements grpc.ServerStream.
+func (s *sourcedStream) Context() context.Context {
+	return s.ctx
+}
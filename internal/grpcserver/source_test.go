@@ -0,0 +1,48 @@
+package grpcserver_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/grpcserver"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+func TestSourceInterceptor(t *testing.T) {
+	t.Parallel()
+
+	proxies, err := clientip.ParseSubnets("192.0.2.1")
+	require.NoError(t, err)
+
+	interceptor := grpcserver.SourceInterceptor(clientip.Resolver{Proxies: proxies})
+
+	var got string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		got = storage.Source(ctx)
+		return nil, nil
+	}
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}})
+
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", got)
+
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-real-ip", "10.0.0.7"))
+
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.7", got)
+
+	_, err = grpcserver.SourceInterceptor(clientip.Resolver{})(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", got, "real IP without proxies")
+}
@@ -0,0 +1,68 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/nekr0z/muhame/internal/limit"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+// Stream implements the Stream method. Each batch received is acknowledged
+// with the ack of the same id; a batch that could not be stored is
+// acknowledged with the error, and the stream goes on.
+func (s *MetricsServer) Stream(stream proto.MetricsService_StreamServer) error {
+	for {
+		in, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		ack := &proto.StreamAck{Id: in.GetId()}
+		if err := s.storeBatch(stream.Context(), in.GetBatch()); err != nil {
+			ack.Error = err.Error()
+		}
+
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *MetricsServer) storeBatch(ctx context.Context, in *proto.BulkRequest) error {
+	if len(in.GetMetrics().GetMetrics()) == 0 {
+		return errors.New("no metrics provided")
+	}
+
+	if err := limit.CheckBulk(ctx, len(in.GetMetrics().GetMetrics())); err != nil {
+		return err
+	}
+
+	var ms []metrics.Named
+	for _, m := range in.GetMetrics().GetMetrics() {
+		nm, msg := fromProto(m)
+		if nm == nil {
+			return errors.New(strings.ToLower(msg))
+		}
+
+		ms = append(ms, *nm)
+	}
+
+	if bu, ok := s.st.(bulkUpdater); ok {
+		return bu.BulkUpdate(ctx, ms)
+	}
+
+	for _, m := range ms {
+		if err := s.st.Update(ctx, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
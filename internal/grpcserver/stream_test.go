@@ -0,0 +1,178 @@
+package grpcserver_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/grpcclient"
+	"github.com/nekr0z/muhame/internal/grpcserver"
+	"github.com/nekr0z/muhame/internal/hash"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/replay"
+	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	const key = "testkey"
+
+	tests := []struct {
+		name    string
+		server  []grpc.StreamServerInterceptor
+		client  []grpc.StreamClientInterceptor
+		wantErr codes.Code
+	}{
+		{
+			name: "plain",
+		},
+		{
+			name: "signed and encrypted",
+			server: []grpc.StreamServerInterceptor{
+				grpcserver.SignatureStreamInterceptor(hash.Keys{Legacy: key}, replay.New(time.Minute, 0, true)),
+				grpcserver.DecryptStreamInterceptor(privateKey),
+				grpcserver.SourceStreamInterceptor(clientip.Resolver{}),
+			},
+			client: []grpc.StreamClientInterceptor{
+				grpcclient.EncryptStreamInterceptor(&privateKey.PublicKey),
+				grpcclient.SignatureStreamInterceptor(hash.Signer{Key: key}),
+			},
+		},
+		{
+			name: "hmac key",
+			server: []grpc.StreamServerInterceptor{
+				grpcserver.SignatureStreamInterceptor(hash.Keys{HMAC: map[string]string{"old": key, "new": "newkey"}}, nil),
+			},
+			client: []grpc.StreamClientInterceptor{
+				grpcclient.SignatureStreamInterceptor(hash.Signer{ID: "new", Key: "newkey"}),
+			},
+		},
+		{
+			name: "bad signature",
+			server: []grpc.StreamServerInterceptor{
+				grpcserver.SignatureStreamInterceptor(hash.Keys{Legacy: key}, nil),
+			},
+			client: []grpc.StreamClientInterceptor{
+				grpcclient.SignatureStreamInterceptor(hash.Signer{Key: "wrongkey"}),
+			},
+			wantErr: codes.Unauthenticated,
+		},
+		{
+			name: "unsigned",
+			server: []grpc.StreamServerInterceptor{
+				grpcserver.SignatureStreamInterceptor(hash.Keys{Legacy: key}, nil),
+			},
+			wantErr: codes.InvalidArgument,
+		},
+		{
+			name: "not encrypted",
+			server: []grpc.StreamServerInterceptor{
+				grpcserver.DecryptStreamInterceptor(privateKey),
+			},
+			wantErr: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			st, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+			require.NoError(t, err)
+
+			cl := streamClient(t, grpcserver.New(st), tt.server, tt.client)
+
+			stream, err := cl.Stream(ctx)
+			require.NoError(t, err)
+
+			for i := uint64(1); i <= 2; i++ {
+				err = stream.Send(streamRequest(i, &proto.Metric{
+					Name:   "requests",
+					Labels: map[string]string{"host": "a", "dc": "b"},
+					Value:  &proto.Metric_Counter{Counter: &proto.Counter{Delta: 2}},
+				}))
+				require.NoError(t, err)
+
+				ack, err := stream.Recv()
+				if tt.wantErr != codes.OK {
+					assert.Equal(t, tt.wantErr, status.Code(err), err)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, i, ack.GetId())
+				assert.Empty(t, ack.GetError())
+			}
+
+			err = stream.Send(streamRequest(3, &proto.Metric{Name: "requests"}))
+			require.NoError(t, err)
+
+			ack, err := stream.Recv()
+			require.NoError(t, err)
+			assert.Equal(t, uint64(3), ack.GetId())
+			assert.NotEmpty(t, ack.GetError())
+
+			require.NoError(t, stream.CloseSend())
+
+			m, err := st.Get(ctx, "counter", "requests", metrics.Labels{"host": "a", "dc": "b"})
+			require.NoError(t, err)
+			assert.Equal(t, metrics.Counter(4), m)
+		})
+	}
+}
+
+func streamRequest(id uint64, mm ...*proto.Metric) *proto.StreamRequest {
+	return &proto.StreamRequest{
+		Id: id,
+		Batch: &proto.BulkRequest{
+			Payload: &proto.BulkRequest_Metrics{
+				Metrics: &proto.Metrics{
+					Metrics: mm,
+				},
+			},
+		},
+	}
+}
+
+func streamClient(t *testing.T, ms *grpcserver.MetricsServer, server []grpc.StreamServerInterceptor, client []grpc.StreamClientInterceptor) proto.MetricsServiceClient {
+	t.Helper()
+
+	const bufSize = 1024 * 1024
+
+	lis := bufconn.Listen(bufSize)
+	s := grpc.NewServer(grpc.ChainStreamInterceptor(server...))
+
+	proto.RegisterMetricsServiceServer(s, ms)
+
+	go func() {
+		err := s.Serve(lis)
+		require.NoError(t, err)
+	}()
+
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithChainStreamInterceptor(client...))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		err := conn.Close()
+		assert.NoError(t, err)
+	})
+
+	return proto.NewMetricsServiceClient(conn)
+}
@@ -0,0 +1,83 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/httpclient"
+)
+
+// TrustedInterceptor returns a grpc.UnaryServerInterceptor that only lets the
+// calls from the trusted subnets through. The client address is resolved by
+// the resolver from the address of the peer and the X-Forwarded-For and
+// X-Real-IP metadata. If the subnet is empty, the interceptor does nothing.
+func TrustedInterceptor(subnet string, res clientip.Resolver) grpc.UnaryServerInterceptor {
+	check := trustedCheck(subnet, res)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := check(ctx); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// TrustedStreamInterceptor is the grpc.StreamServerInterceptor counterpart of
+// the TrustedInterceptor.
+func TrustedStreamInterceptor(subnet string, res clientip.Resolver) grpc.StreamServerInterceptor {
+	check := trustedCheck(subnet, res)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := check(ss.Context()); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+func trustedCheck(subnet string, res clientip.Resolver) func(context.Context) error {
+	if subnet == "" {
+		return func(context.Context) error { return nil }
+	}
+
+	subnets, err := clientip.ParseSubnets(subnet)
+
+	return func(ctx context.Context) error {
+		if err != nil {
+			return status.Error(codes.PermissionDenied, "bad trusted subnet")
+		}
+
+		if !subnets.Contains(clientIP(ctx, res)) {
+			return status.Error(codes.PermissionDenied, "not in trusted subnet")
+		}
+
+		return nil
+	}
+}
+
+// clientIP resolves the address of the client of the call.
+func clientIP(ctx context.Context, res clientip.Resolver) net.IP {
+	var addr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var realIP string
+	if v := md.Get(httpclient.HeaderRealIP); len(v) > 0 {
+		realIP = v[0]
+	}
+
+	return res.Resolve(addr, realIP, md.Get(strings.ToLower(clientip.ForwardedForHeader)))
+}
@@ -0,0 +1,85 @@
+package grpcserver_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/grpcserver"
+)
+
+func TestTrustedInterceptor(t *testing.T) {
+	t.Parallel()
+
+	const subnets = "10.0.0.0/8,fd00::/8"
+
+	proxies, err := clientip.ParseSubnets("192.0.2.0/24")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		subnet  string
+		proxies clientip.Subnets
+		peer    string
+		md      metadata.MD
+		want    codes.Code
+	}{
+		{name: "no subnet", peer: "198.51.100.1", want: codes.OK},
+		{name: "IPv4 peer", subnet: subnets, peer: "10.1.2.3", want: codes.OK},
+		{name: "IPv6 peer", subnet: subnets, peer: "fd00::1", want: codes.OK},
+		{name: "untrusted peer", subnet: subnets, peer: "198.51.100.1", want: codes.PermissionDenied},
+		{name: "spoofed real IP", subnet: subnets, peer: "198.51.100.1", md: metadata.Pairs("x-real-ip", "10.1.2.3"), want: codes.PermissionDenied},
+		{name: "real IP by proxy", subnet: subnets, proxies: proxies, peer: "192.0.2.1", md: metadata.Pairs("x-real-ip", "10.1.2.3"), want: codes.OK},
+		{name: "forwarded without proxies", subnet: subnets, peer: "198.51.100.1", md: metadata.Pairs("x-forwarded-for", "10.1.2.3"), want: codes.PermissionDenied},
+		{name: "forwarded by proxy", subnet: subnets, proxies: proxies, peer: "192.0.2.1", md: metadata.Pairs("x-forwarded-for", "198.51.100.1, 10.1.2.3"), want: codes.OK},
+		{name: "spoofed forwarded by proxy", subnet: subnets, proxies: proxies, peer: "192.0.2.1", md: metadata.Pairs("x-forwarded-for", "10.1.2.3, 198.51.100.1"), want: codes.PermissionDenied},
+		{name: "forwarded by other", subnet: subnets, proxies: proxies, peer: "198.51.100.1", md: metadata.Pairs("x-forwarded-for", "10.1.2.3"), want: codes.PermissionDenied},
+		{name: "real IP from other", subnet: subnets, proxies: proxies, peer: "198.51.100.1", md: metadata.Pairs("x-real-ip", "10.1.2.3"), want: codes.PermissionDenied},
+		{name: "bad subnet", subnet: "whatever", peer: "10.1.2.3", want: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := clientip.Resolver{Proxies: tt.proxies}
+
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(tt.peer), Port: 1234}})
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			}
+
+			_, err := grpcserver.TrustedInterceptor(tt.subnet, res)(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+			assert.Equal(t, tt.want, status.Code(err), err)
+
+			streamHandler := func(srv interface{}, ss grpc.ServerStream) error {
+				return nil
+			}
+
+			err = grpcserver.TrustedStreamInterceptor(tt.subnet, res)(nil, &contextStream{ctx: ctx}, &grpc.StreamServerInfo{}, streamHandler)
+			assert.Equal(t, tt.want, status.Code(err), err)
+		})
+	}
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,48 @@
+package grpcserver
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/watch"
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+// Watch implements the Watch method. It streams the values of the series as
+// they are updated until the client goes away or the server shuts down.
+func (s *MetricsServer) Watch(in *proto.WatchRequest, stream proto.MetricsService_WatchServer) error {
+	if s.hub == nil {
+		return status.Error(codes.FailedPrecondition, "watching is not enabled")
+	}
+
+	if k, ok := auth.FromContext(stream.Context()); ok && !k.AllowsPrefix(in.GetNamePrefix()) {
+		return status.Errorf(codes.PermissionDenied, "key %s may not watch %q", k.Name, in.GetNamePrefix())
+	}
+
+	sub := s.hub.Subscribe(watch.Filter{
+		NamePrefix: in.GetNamePrefix(),
+		Type:       in.GetType(),
+	})
+	defer sub.Close()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-sub.Events():
+			if !ok {
+				return nil
+			}
+
+			err := stream.Send(&proto.WatchEvent{
+				Metric: toProto(e.Named),
+				Time:   timestamppb.New(e.Time),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
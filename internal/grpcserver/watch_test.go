@@ -0,0 +1,112 @@
+package grpcserver_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nekr0z/muhame/internal/grpcclient"
+	"github.com/nekr0z/muhame/internal/grpcserver"
+	"github.com/nekr0z/muhame/internal/hash"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/internal/watch"
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	const key = "testkey"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ms, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	h := watch.NewHub(0)
+	st := watch.Wrap(ms, h)
+
+	cl := streamClient(t, grpcserver.New(st, grpcserver.WithHub(h)),
+		[]grpc.StreamServerInterceptor{
+			grpcserver.SignatureStreamInterceptor(hash.Keys{Legacy: key}, nil),
+			grpcserver.DecryptStreamInterceptor(privateKey),
+		},
+		[]grpc.StreamClientInterceptor{
+			grpcclient.EncryptStreamInterceptor(&privateKey.PublicKey),
+			grpcclient.SignatureStreamInterceptor(hash.Signer{Key: key}),
+		},
+	)
+
+	stream, err := cl.Watch(ctx, &proto.WatchRequest{NamePrefix: "req", Type: "counter"})
+	require.NoError(t, err)
+
+	// wait for the watch to subscribe
+	require.Eventually(t, func() bool {
+		return h.Wants(metrics.Named{Name: "requests", Metric: metrics.Counter(0)})
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "requests", Metric: metrics.Counter(2)}))
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "requests", Metric: metrics.Gauge(1)}))
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "load", Metric: metrics.Counter(1)}))
+
+	recv := func(want int64) {
+		t.Helper()
+
+		e, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "requests", e.GetMetric().GetName())
+		assert.Equal(t, want, e.GetMetric().GetCounter().GetDelta())
+	}
+
+	// the totals of counters are read back in the background, so the event
+	// of an update is awaited before the next one
+	recv(2)
+
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "requests", Metric: metrics.Counter(3)}))
+	recv(5)
+
+	h.Close()
+
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+
+	stream, err = cl.Watch(ctx, &proto.WatchRequest{})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestWatch_Unsigned(t *testing.T) {
+	t.Parallel()
+
+	ms, err := storage.New(zaptest.NewLogger(t).Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	cl := streamClient(t, grpcserver.New(ms, grpcserver.WithHub(watch.NewHub(0))),
+		[]grpc.StreamServerInterceptor{grpcserver.SignatureStreamInterceptor(hash.Keys{Legacy: "testkey"}, nil)}, nil)
+
+	stream, err := cl.Watch(context.Background(), &proto.WatchRequest{})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	cl = streamClient(t, grpcserver.New(ms), nil, nil)
+
+	stream, err = cl.Watch(context.Background(), &proto.WatchRequest{})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/nekr0z/muhame/internal/alert"
+)
+
+// AlertsHandleFunc returns the handler for the /alerts endpoint that lists the
+// pending, firing and recently resolved alerts as JSON.
+func AlertsHandleFunc(al *alert.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.Marshal(al.Alerts())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		_, err = w.Write(b)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/nekr0z/muhame/internal/auth"
+)
+
+// forbidden responds with 403 if the error is due to the API key not allowing
+// the operation, and reports whether it did.
+func forbidden(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, auth.ErrForbidden) {
+		return false
+	}
+
+	http.Error(w, fmt.Sprintf("Forbidden: %s", err), http.StatusForbidden)
+
+	return true
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+// DeleteHandleFunc returns the DELETE handler for the /value/*/* endpoint.
+// Query parameters, if any, are the labels of the series.
+func DeleteHandleFunc(st deleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		labels, err := metrics.FromValues(r.URL.Query())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		err = st.Delete(r.Context(), chi.URLParam(r, "type"), chi.URLParam(r, "name"), labels)
+		if err != nil {
+			if errors.Is(err, storage.ErrMetricNotFound) {
+				http.Error(w, "Metric not found.", http.StatusNotFound)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// BulkDeleteHandleFunc returns the handler for the /delete/ endpoint. The
+// request is a JSON array of series, only "id", "type" and "labels" are used.
+// The series that do not exist are skipped, the response is the number of the
+// deleted series, i.e. {"affected": 2}.
+func BulkDeleteHandleFunc(st deleter) http.HandlerFunc {
+	return bulkSeriesHandleFunc(st.Delete)
+}
+
+// BulkResetHandleFunc returns the handler for the /reset/ endpoint that sets
+// the series to zero. The request and response are the same as for the
+// /delete/ endpoint.
+func BulkResetHandleFunc(st resetter) http.HandlerFunc {
+	return bulkSeriesHandleFunc(st.Reset)
+}
+
+func bulkSeriesHandleFunc(op func(ctx context.Context, t, name string, labels metrics.Labels) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var jms []metrics.JSONMetric
+		if err := json.NewDecoder(r.Body).Decode(&jms); err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		for _, jm := range jms {
+			if jm.ID == "" || jm.MType == "" {
+				http.Error(w, "Bad request: no metric name or type", http.StatusBadRequest)
+				return
+			}
+
+			if err := jm.Labels.Validate(); err != nil {
+				http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var res struct {
+			Affected int `json:"affected"`
+		}
+
+		for _, jm := range jms {
+			err := op(r.Context(), jm.MType, jm.ID, jm.Labels)
+			if errors.Is(err, storage.ErrMetricNotFound) {
+				continue
+			}
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+				return
+			}
+
+			res.Affected++
+		}
+
+		b, err := json.Marshal(res)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		_, err = w.Write(b)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+type deleter interface {
+	Delete(ctx context.Context, t, name string, labels metrics.Labels) error
+}
+
+type resetter interface {
+	Reset(ctx context.Context, t, name string, labels metrics.Labels) error
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/nekr0z/muhame/internal/influx"
+	"github.com/nekr0z/muhame/internal/limit"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+// InfluxWriteHandleFunc returns the handler for the /write endpoint that
+// accepts metrics in the InfluxDB line protocol. Nothing is saved if any of
+// the lines fails to parse.
+func InfluxWriteHandleFunc(st storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nms, err := influx.Parse(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		if tooLarge(w, limit.CheckBulk(r.Context(), len(nms))) {
+			return
+		}
+
+		if len(nms) > 0 {
+			if err := updateAll(r.Context(), st, nms); err != nil {
+				if forbidden(w, err) {
+					return
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/nekr0z/muhame/internal/limit"
+)
+
+// tooLarge responds with 413 if the error is due to the request exceeding a
+// limit, and reports whether it did.
+func tooLarge(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, limit.ErrTooLarge) {
+		return false
+	}
+
+	http.Error(w, fmt.Sprintf("Request entity too large: %s", err), http.StatusRequestEntityTooLarge)
+
+	return true
+}
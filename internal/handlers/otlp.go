@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/nekr0z/muhame/internal/otlp"
+)
+
+// OTLPHandleFunc returns the handler for the OTLP/HTTP /v1/metrics endpoint.
+// Both binary protobuf and JSON requests are supported, the response is
+// encoded the same way as the request.
+func OTLPHandleFunc(rcv *otlp.Receiver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		isJSON := mt == "application/json"
+
+		var req colmetricspb.ExportMetricsServiceRequest
+		if isJSON {
+			err = protojson.Unmarshal(b, &req)
+		} else {
+			err = proto.Unmarshal(b, &req)
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		res, err := rcv.Export(r.Context(), &req)
+		if status.Code(err) == codes.PermissionDenied {
+			http.Error(w, fmt.Sprintf("Forbidden: %s", status.Convert(err).Message()), http.StatusForbidden)
+			return
+		}
+		if status.Code(err) == codes.ResourceExhausted {
+			http.Error(w, fmt.Sprintf("Request entity too large: %s", status.Convert(err).Message()), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		if isJSON {
+			w.Header().Set("Content-Type", "application/json")
+			b, err = protojson.Marshal(res)
+		} else {
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			b, err = proto.Marshal(res)
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		_, err = w.Write(b)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
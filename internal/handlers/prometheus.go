@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+// prometheusContentType is the content type of the Prometheus text exposition
+// format.
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// MetricsHandleFunc returns the handler for the /metrics endpoint that exposes
+// all the stored series in the Prometheus text format. Names are sanitised to
+// fit the Prometheus data model; if different metrics end up with the same
+// name, the type is appended to the name of all but the first of them, and the
+// ones that still clash are skipped.
+func MetricsHandleFunc(st storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mm, err := st.List(r.Context(), nil)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", prometheusContentType)
+
+		bw := bufio.NewWriter(w)
+
+		for _, f := range families(mm) {
+			writeFamily(bw, f)
+		}
+
+		err = bw.Flush()
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// family is a group of series of the same type with the same name.
+type family struct {
+	name   string
+	t      string
+	series []metrics.Named
+}
+
+// families groups the series into families with sanitised names, sorted by
+// name.
+func families(mm []metrics.Named) []family {
+	slices.SortFunc(mm, func(a, b metrics.Named) int {
+		return cmp.Or(
+			cmp.Compare(a.Name, b.Name),
+			-cmp.Compare(a.Type(), b.Type()),
+			cmp.Compare(a.Labels.String(), b.Labels.String()),
+		)
+	})
+
+	var ff []family
+	idx := make(map[string]int)
+	owners := make(map[string]string)
+
+	for _, m := range mm {
+		name := promName(m.Name)
+		t := m.Type()
+		owner := m.Name + "\x00" + t
+
+		if have, ok := owners[name]; ok && have != owner {
+			name = name + "_" + t
+		}
+		if have, ok := owners[name]; ok && have != owner {
+			continue
+		}
+		owners[name] = owner
+
+		i, ok := idx[name]
+		if !ok {
+			i = len(ff)
+			idx[name] = i
+			ff = append(ff, family{name: name, t: t})
+		}
+
+		ff[i].series = append(ff[i].series, m)
+	}
+
+	slices.SortStableFunc(ff, func(a, b family) int {
+		return cmp.Compare(a.name, b.name)
+	})
+
+	return ff
+}
+
+func writeFamily(w *bufio.Writer, f family) {
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.t)
+
+	for _, s := range f.series {
+		switch m := s.Metric.(type) {
+		case metrics.Gauge:
+			writeSample(w, f.name, s.Labels, "", "", float64(m))
+		case metrics.Counter:
+			writeSample(w, f.name, s.Labels, "", "", float64(m))
+		case metrics.Histogram:
+			var cum uint64
+			for i, c := range m.Counts {
+				cum += c
+
+				le := "+Inf"
+				if i < len(m.Bounds) {
+					le = formatFloat(m.Bounds[i])
+				}
+
+				writeSample(w, f.name+"_bucket", s.Labels, "le", le, float64(cum))
+			}
+			writeSample(w, f.name+"_sum", s.Labels, "", "", m.Sum)
+			writeSample(w, f.name+"_count", s.Labels, "", "", float64(m.Count))
+		}
+	}
+}
+
+// writeSample writes a sample line, the extra label is only added if its name
+// is not empty.
+func writeSample(w *bufio.Writer, name string, labels metrics.Labels, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != extraName {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	if len(keys) > 0 || extraName != "" {
+		w.WriteByte('{')
+
+		for i, k := range keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, k, labels[k])
+		}
+
+		if extraName != "" {
+			if len(keys) > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, extraName, extraValue)
+		}
+
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeLabel(w *bufio.Writer, k, v string) {
+	w.WriteString(k)
+	w.WriteString(`="`)
+	labelValueReplacer.WriteString(w, v)
+	w.WriteByte('"')
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// promName sanitises the name to be a valid Prometheus metric name.
+func promName(name string) string {
+	if name == "" {
+		return "_"
+	}
+
+	var sb strings.Builder
+
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+func TestMetricsHandleFunc(t *testing.T) {
+	st, err := storage.New(zap.NewNop().Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	h := metrics.NewHistogram([]float64{0.5, 1})
+	h.Observe(0.2)
+	h.Observe(0.7)
+	h.Observe(3)
+
+	for _, m := range []metrics.Named{
+		{Name: "HeapAlloc", Metric: metrics.Gauge(1.5)},
+		{Name: "PollCount", Metric: metrics.Counter(3)},
+		{Name: "cpu.utilization-1", Labels: metrics.Labels{"host": "a\"b"}, Metric: metrics.Gauge(0.25)},
+		{Name: "cpu.utilization-1", Labels: metrics.Labels{"core": "1", "host": "a"}, Metric: metrics.Gauge(0.5)},
+		{Name: "1st", Metric: metrics.Counter(1)},
+		{Name: "same", Metric: metrics.Gauge(2)},
+		{Name: "same", Metric: metrics.Counter(2)},
+		{Name: "clash.name", Metric: metrics.Gauge(1)},
+		{Name: "clash_name", Metric: metrics.Gauge(2)},
+		{Name: "clash-name", Metric: metrics.Gauge(3)},
+		{Name: "latency", Labels: metrics.Labels{"host": "a"}, Metric: h},
+	} {
+		require.NoError(t, st.Update(ctx, m))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	MetricsHandleFunc(st)(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, prometheusContentType, res.Header.Get("Content-Type"))
+	assert.Equal(t, `# TYPE HeapAlloc gauge
+HeapAlloc 1.5
+# TYPE PollCount counter
+PollCount 3
+# TYPE _1st counter
+_1st 1
+# TYPE clash_name gauge
+clash_name 3
+# TYPE clash_name_gauge gauge
+clash_name_gauge 1
+# TYPE cpu_utilization_1 gauge
+cpu_utilization_1{core="1",host="a"} 0.5
+cpu_utilization_1{host="a\"b"} 0.25
+# TYPE latency histogram
+latency_bucket{host="a",le="0.5"} 1
+latency_bucket{host="a",le="1"} 2
+latency_bucket{host="a",le="+Inf"} 3
+latency_sum{host="a"} 3.9
+latency_count{host="a"} 3
+# TYPE same gauge
+same 2
+# TYPE same_counter counter
+same_counter 2
+`, w.Body.String())
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+// QueryRangeHandleFunc returns the handler for the /query_range endpoint.
+//
+// The "name" and "type" query parameters select the series, "from" and "to"
+// set the time range (RFC 3339 or unix seconds, the last hour by default),
+// and "step", if set, downsamples the result to one point per step (Go
+// duration or seconds). All the other query parameters are the labels of the
+// series.
+func QueryRangeHandleFunc(st storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rq, ok := st.(rangeQuerier)
+		if !ok {
+			http.Error(w, "storage does not support range queries", http.StatusConflict)
+			return
+		}
+
+		q, err := parseRangeQuery(r.URL.Query(), time.Now())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		ss, err := rq.Range(r.Context(), q.t, q.name, q.labels, q.from, q.to)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrMetricNotFound):
+				http.Error(w, "Metric not found.", http.StatusNotFound)
+			case errors.Is(err, storage.ErrHistoryDisabled):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if q.step > 0 {
+			ss = storage.Downsample(ss, q.from, q.to, q.step)
+		}
+
+		if ss == nil {
+			ss = []storage.Sample{}
+		}
+
+		bb, err := json.Marshal(rangeResponse{
+			ID:     q.name,
+			MType:  q.t,
+			Labels: q.labels,
+			Points: ss,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+
+		_, err = w.Write(bb)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+type rangeResponse struct {
+	ID     string           `json:"id"`
+	MType  string           `json:"type"`
+	Labels metrics.Labels   `json:"labels,omitempty"`
+	Points []storage.Sample `json:"points"`
+}
+
+type rangeQuery struct {
+	name, t  string
+	labels   metrics.Labels
+	from, to time.Time
+	step     time.Duration
+}
+
+func parseRangeQuery(v url.Values, now time.Time) (rangeQuery, error) {
+	q := rangeQuery{
+		name: v.Get("name"),
+		t:    v.Get("type"),
+		to:   now,
+	}
+
+	if q.name == "" || q.t == "" {
+		return q, fmt.Errorf("name and type are required")
+	}
+
+	var err error
+
+	if s := v.Get("to"); s != "" {
+		q.to, err = parseTime(s)
+		if err != nil {
+			return q, fmt.Errorf("bad \"to\": %w", err)
+		}
+	}
+
+	q.from = q.to.Add(-storage.DefaultRange)
+	if s := v.Get("from"); s != "" {
+		q.from, err = parseTime(s)
+		if err != nil {
+			return q, fmt.Errorf("bad \"from\": %w", err)
+		}
+	}
+
+	if q.from.After(q.to) {
+		return q, fmt.Errorf("\"from\" is after \"to\"")
+	}
+
+	if s := v.Get("step"); s != "" {
+		q.step, err = parseStep(s)
+		if err != nil {
+			return q, fmt.Errorf("bad \"step\": %w", err)
+		}
+
+		if q.to.Sub(q.from)/q.step >= storage.MaxPoints {
+			return q, fmt.Errorf("too many points, increase step")
+		}
+	}
+
+	lv := make(url.Values, len(v))
+	for k, vv := range v {
+		switch k {
+		case "name", "type", "from", "to", "step":
+			continue
+		}
+		lv[k] = vv
+	}
+
+	q.labels, err = metrics.FromValues(lv)
+
+	return q, err
+}
+
+// parseTime parses time either as RFC 3339 or as unix seconds.
+func parseTime(s string) (time.Time, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*float64(time.Second))), nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseStep parses step either as a duration or as seconds.
+func parseStep(s string) (time.Duration, error) {
+	var d time.Duration
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		d = time.Duration(f * float64(time.Second))
+	} else {
+		d, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("step must be positive")
+	}
+
+	return d, nil
+}
+
+type rangeQuerier interface {
+	Range(ctx context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error)
+}
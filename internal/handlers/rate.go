@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/rate"
+)
+
+// RateHandleFunc returns the handler for the /rate/* endpoint that responds
+// with the per-second rates of the counter as JSON, i.e.
+// {"id":"PollCount","last":2,"m1":1.9,"m5":1.8,"m15":1.8}. Query parameters,
+// if any, are the labels of the series.
+func RateHandleFunc(tr *rate.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		labels, err := metrics.FromValues(r.URL.Query())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		name := chi.URLParam(r, "name")
+
+		rr, ok := tr.Get(name, labels)
+		if !ok {
+			http.Error(w, "Rate not known.", http.StatusNotFound)
+			return
+		}
+
+		b, err := json.Marshal(struct {
+			ID     string         `json:"id"`
+			Labels metrics.Labels `json:"labels,omitempty"`
+			rate.Rates
+		}{name, labels, rr})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		_, err = w.Write(b)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
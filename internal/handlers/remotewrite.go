@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/nekr0z/muhame/internal/limit"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/pkg/prompb"
+)
+
+// staleNaN is the value Prometheus uses to mark a series as stale.
+const staleNaN = 0x7ff0000000000002
+
+// RemoteWriteHandleFunc returns the handler for the Prometheus remote write
+// endpoint. The latest sample of each series is stored as a gauge, the
+// "__name__" label being the name of the metric and all the other labels the
+// labels of the series. Series that can not be stored are skipped.
+func RemoteWriteHandleFunc(st storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		compressed, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		n, err := snappy.DecodedLen(compressed)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		if tooLarge(w, limit.CheckDecompressed(r.Context(), int64(n))) {
+			return
+		}
+
+		b, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		var req prompb.WriteRequest
+		if err := proto.Unmarshal(b, &req); err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		if tooLarge(w, limit.CheckBulk(r.Context(), len(req.GetTimeseries()))) {
+			return
+		}
+
+		nms := fromTimeSeries(req.GetTimeseries())
+
+		if len(nms) > 0 {
+			if err := updateAll(r.Context(), st, nms); err != nil {
+				if forbidden(w, err) {
+					return
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func fromTimeSeries(tss []*prompb.TimeSeries) []metrics.Named {
+	nms := make([]metrics.Named, 0, len(tss))
+
+	for _, ts := range tss {
+		nm, ok := namedFromTimeSeries(ts)
+		if !ok {
+			continue
+		}
+
+		nms = append(nms, nm)
+	}
+
+	return nms
+}
+
+func namedFromTimeSeries(ts *prompb.TimeSeries) (metrics.Named, bool) {
+	var nm metrics.Named
+
+	for _, l := range ts.GetLabels() {
+		if l.GetName() == "__name__" {
+			nm.Name = l.GetValue()
+			continue
+		}
+
+		if nm.Labels == nil {
+			nm.Labels = make(metrics.Labels)
+		}
+		nm.Labels[l.GetName()] = l.GetValue()
+	}
+
+	if nm.Name == "" || nm.Labels.Validate() != nil {
+		return nm, false
+	}
+
+	var latest *prompb.Sample
+	for _, s := range ts.GetSamples() {
+		if math.Float64bits(s.GetValue()) == staleNaN {
+			continue
+		}
+
+		if latest == nil || s.GetTimestamp() >= latest.GetTimestamp() {
+			latest = s
+		}
+	}
+
+	if latest == nil {
+		return nm, false
+	}
+
+	nm.Metric = metrics.Gauge(latest.GetValue())
+
+	return nm, true
+}
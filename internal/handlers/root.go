@@ -4,16 +4,34 @@ import (
 	"cmp"
 	"context"
 	"fmt"
+	"html"
+	"io"
 	"net/http"
+	"net/url"
 	"slices"
+	"strconv"
+	"time"
 
+	"github.com/nekr0z/muhame/internal/alert"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/rate"
 	"github.com/nekr0z/muhame/internal/storage"
 )
 
-// RootHandleFunc returns the handler for the / endpoint.
-func RootHandleFunc(st storage.Storage) func(http.ResponseWriter, *http.Request) {
+// RootHandleFunc returns the handler for the / endpoint. Query parameters, if
+// any, are used to filter the series by labels. The alerts, if any, are listed
+// before the metrics. The series that have not been updated for longer than
+// staleAfter are marked as stale. The counters are shown along with their
+// rates, if known to the tracker.
+func RootHandleFunc(st storage.Storage, al *alert.Engine, staleAfter time.Duration, tr *rate.Tracker) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		mm, err := listAllMetrics(r.Context(), st)
+		filter, err := metrics.FromValues(r.URL.Query())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		mm, err := listAllMetrics(r.Context(), st, filter, staleAfter, tr)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
 			return
@@ -27,9 +45,23 @@ func RootHandleFunc(st storage.Storage) func(http.ResponseWriter, *http.Request)
 			return
 		}
 
+		if err := writeAlerts(w, al.Alerts()); err != nil {
+			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		_, err = fmt.Fprint(w, metricsBegin)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+			return
+		}
+
 		for _, met := range mm {
-			link := fmt.Sprintf("/value/%s/%s", met.t, met.name)
-			_, err = fmt.Fprintf(w, "<li><a href=\"%s\">%s (%s)</a>: %s</li>\n", link, met.name, met.t, met.value)
+			link := fmt.Sprintf("/value/%s/%s", met.t, url.PathEscape(met.name))
+			if met.labels != "" {
+				link += "?" + met.labels
+			}
+			_, err = fmt.Fprintf(w, "<li><a href=\"%s\">%s%s (%s)</a>: %s%s</li>\n", html.EscapeString(link), html.EscapeString(met.name), html.EscapeString(met.pretty), met.t, met.value, html.EscapeString(met.info))
 			if err != nil {
 				http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
 				return
@@ -45,24 +77,69 @@ func RootHandleFunc(st storage.Storage) func(http.ResponseWriter, *http.Request)
 	}
 }
 
+func writeAlerts(w io.Writer, aa []alert.Alert) error {
+	if len(aa) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprint(w, alertsBegin); err != nil {
+		return err
+	}
+
+	for _, a := range aa {
+		since := a.ActiveAt
+		switch a.State {
+		case alert.StateFiring:
+			since = *a.FiredAt
+		case alert.StateResolved:
+			since = *a.ResolvedAt
+		}
+
+		_, err := fmt.Fprintf(w, "<li>%s: %s%s (%s) is %s since %s, value %s</li>\n",
+			html.EscapeString(a.Rule), html.EscapeString(a.Name), html.EscapeString(a.Labels.Pretty()), a.Type,
+			a.State, since.Format(time.RFC3339), strconv.FormatFloat(a.Value, 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprint(w, alertsEnd)
+	return err
+}
+
 type displayedMetric struct {
-	name  string
-	t     string
-	value string
+	name   string
+	labels string
+	pretty string
+	t      string
+	value  string
+	info   string
 }
 
-func listAllMetrics(ctx context.Context, st storage.Storage) ([]displayedMetric, error) {
-	mm, err := st.List(ctx)
+func listAllMetrics(ctx context.Context, st storage.Storage, filter metrics.Labels, staleAfter time.Duration, tr *rate.Tracker) ([]displayedMetric, error) {
+	ss, err := st.ListSeries(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	mms := make([]displayedMetric, 0, len(mm))
-	for _, named := range mm {
+	now := time.Now()
+
+	mms := make([]displayedMetric, 0, len(ss))
+	for _, s := range ss {
+		value := s.String()
+		if _, ok := s.Metric.(metrics.Counter); ok {
+			if rr, ok := tr.Get(s.Name, s.Labels); ok {
+				value += " " + formatRates(rr)
+			}
+		}
+
 		mms = append(mms, displayedMetric{
-			name:  named.Name,
-			t:     named.Type(),
-			value: named.String(),
+			name:   s.Name,
+			labels: s.Labels.String(),
+			pretty: s.Labels.Pretty(),
+			t:      s.Type(),
+			value:  value,
+			info:   seriesInfo(s, staleAfter, now),
 		})
 	}
 
@@ -71,12 +148,44 @@ func listAllMetrics(ctx context.Context, st storage.Storage) ([]displayedMetric,
 			// gauges before counters
 			return -cmp.Compare(a.t, b.t)
 		}
-		return cmp.Compare(a.name, b.name)
+		if a.name != b.name {
+			return cmp.Compare(a.name, b.name)
+		}
+		return cmp.Compare(a.labels, b.labels)
 	})
 
 	return mms, nil
 }
 
+// formatRates returns the rates in the form of
+// "(2.00/s, 1m 1.90/s, 5m 1.80/s, 15m 1.80/s)".
+func formatRates(rr rate.Rates) string {
+	f := func(r float64) string {
+		return strconv.FormatFloat(r, 'f', 2, 64) + "/s"
+	}
+
+	return fmt.Sprintf("(%s, 1m %s, 5m %s, 15m %s)", f(rr.Last), f(rr.M1), f(rr.M5), f(rr.M15))
+}
+
+// seriesInfo returns the description of when and by whom the series was last
+// updated, if known.
+func seriesInfo(s storage.Series, staleAfter time.Duration, now time.Time) string {
+	if s.Updated.IsZero() {
+		return ""
+	}
+
+	info := ", updated " + s.Updated.Format(time.RFC3339)
+	if s.Source != "" {
+		info += " by " + s.Source
+	}
+
+	if s.Stale(staleAfter, now) {
+		info += " (stale)"
+	}
+
+	return info
+}
+
 const (
 	begin = `<!DOCTYPE html>
 <html>
@@ -85,7 +194,13 @@ const (
 	<title>Metrics</title>
 </head>
 <body>
-	<h1>Metrics</h1>
+`
+	alertsBegin = `	<h1>Alerts</h1>
+	<ul>
+`
+	alertsEnd = `</ul>
+`
+	metricsBegin = `	<h1>Metrics</h1>
 	<ul>
 `
 	end = `</ul>
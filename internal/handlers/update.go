@@ -12,7 +12,8 @@ import (
 	"github.com/nekr0z/muhame/internal/metrics"
 )
 
-// UpdateHandleFunc returns the handler for the /update/*/* endpoint.
+// UpdateHandleFunc returns the handler for the /update/*/* endpoint. Query
+// parameters, if any, are the labels of the series.
 func UpdateHandleFunc(st updater) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		value := chi.URLParam(r, "value")
@@ -24,10 +25,20 @@ func UpdateHandleFunc(st updater) func(http.ResponseWriter, *http.Request) {
 			return
 		}
 
+		labels, err := metrics.FromValues(r.URL.Query())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
 		if err := st.Update(r.Context(), metrics.Named{
 			Name:   chi.URLParam(r, "name"),
+			Labels: labels,
 			Metric: m,
 		}); err != nil {
+			if forbidden(w, err) {
+				return
+			}
 			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
 			return
 		}
@@ -50,8 +61,6 @@ func UpdateJSONHandleFunc(st getUpdater) func(http.ResponseWriter, *http.Request
 			return
 		}
 
-		name := jm.ID
-
 		nm, err := jm.Named()
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
@@ -60,11 +69,14 @@ func UpdateJSONHandleFunc(st getUpdater) func(http.ResponseWriter, *http.Request
 
 		err = st.Update(r.Context(), nm)
 		if err != nil {
+			if forbidden(w, err) {
+				return
+			}
 			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
 			return
 		}
 
-		m, err := st.Get(r.Context(), nm.Type(), nm.Name)
+		nm.Metric, err = st.Get(r.Context(), nm.Type(), nm.Name, nm.Labels)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
 			return
@@ -72,7 +84,7 @@ func UpdateJSONHandleFunc(st getUpdater) func(http.ResponseWriter, *http.Request
 
 		w.Header().Add("Content-Type", "application/json")
 
-		_, err = w.Write(metrics.ToJSON(m, name))
+		_, err = w.Write(nm.ToJSON())
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
 		}
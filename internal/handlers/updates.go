@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/nekr0z/muhame/internal/limit"
 	"github.com/nekr0z/muhame/internal/metrics"
 	"github.com/nekr0z/muhame/internal/storage"
 )
@@ -25,6 +26,10 @@ func BulkUpdateHandleFunc(st storage.Storage) http.HandlerFunc {
 			return
 		}
 
+		if tooLarge(w, limit.CheckBulk(r.Context(), len(jms))) {
+			return
+		}
+
 		nms := toNamed(jms)
 
 		if len(nms) == 0 {
@@ -35,6 +40,9 @@ func BulkUpdateHandleFunc(st storage.Storage) http.HandlerFunc {
 		err := bu.BulkUpdate(r.Context(), nms)
 
 		if err != nil {
+			if forbidden(w, err) {
+				return
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -58,6 +66,22 @@ func toNamed(jms []metrics.JSONMetric) []metrics.Named {
 	return nms
 }
 
+// updateAll writes the metrics in bulk if the storage supports that, or one by
+// one otherwise.
+func updateAll(ctx context.Context, st storage.Storage, nms []metrics.Named) error {
+	if bu, ok := st.(bulkUpdater); ok {
+		return bu.BulkUpdate(ctx, nms)
+	}
+
+	for _, nm := range nms {
+		if err := st.Update(ctx, nm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 type bulkUpdater interface {
 	BulkUpdate(context.Context, []metrics.Named) error
 }
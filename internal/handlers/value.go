@@ -6,17 +6,26 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/rate"
 	"github.com/nekr0z/muhame/internal/storage"
 )
 
-// ValueHandleFunc returns the handler for the /value/*/* endpoint.
+// ValueHandleFunc returns the handler for the /value/*/* endpoint. Query
+// parameters, if any, are the labels of the series.
 func ValueHandleFunc(st getter) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		m, err := st.Get(r.Context(), chi.URLParam(r, "type"), chi.URLParam(r, "name"))
+		labels, err := metrics.FromValues(r.URL.Query())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		m, err := st.Get(r.Context(), chi.URLParam(r, "type"), chi.URLParam(r, "name"), labels)
 		if err != nil {
 			if errors.Is(err, storage.ErrMetricNotFound) {
 				http.Error(w, "Metric not found.", http.StatusNotFound)
@@ -33,8 +42,12 @@ func ValueHandleFunc(st getter) func(http.ResponseWriter, *http.Request) {
 	}
 }
 
-// ValueJSONHandleFunc returns the handler for the /value/ endpoint.
-func ValueJSONHandleFunc(st getter) func(http.ResponseWriter, *http.Request) {
+// ValueJSONHandleFunc returns the handler for the /value/ endpoint. Along with
+// the metric, the response tells when the series was last updated and by which
+// client, and whether it is stale, i.e. has not been updated for longer than
+// staleAfter. Series never go stale if staleAfter is not positive. For the
+// counters, the rates known to the tracker are included, too.
+func ValueJSONHandleFunc(st describer, staleAfter time.Duration, tr *rate.Tracker) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			err := r.Body.Close()
@@ -52,10 +65,15 @@ func ValueJSONHandleFunc(st getter) func(http.ResponseWriter, *http.Request) {
 		name := jm.ID
 		t := jm.MType
 
-		m, err := st.Get(r.Context(), t, name)
+		if err := jm.Labels.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		s, err := st.Describe(r.Context(), t, name, jm.Labels)
 		if err != nil {
 			if errors.Is(err, storage.ErrMetricNotFound) {
-				respondJSONNotFound(w, t, name)
+				respondJSONNotFound(w, t, name, jm.Labels)
 				return
 			}
 
@@ -63,9 +81,33 @@ func ValueJSONHandleFunc(st getter) func(http.ResponseWriter, *http.Request) {
 			return
 		}
 
+		out, ok := metrics.Named{Name: name, Labels: jm.Labels, Metric: s.Metric}.JSONMetric()
+		if !ok {
+			http.Error(w, "Internal server error: unknown metric type", http.StatusInternalServerError)
+			return
+		}
+
+		resp := seriesJSON{
+			JSONMetric: out,
+			Source:     s.Source,
+			Stale:      s.Stale(staleAfter, time.Now()),
+		}
+		if !s.Updated.IsZero() {
+			resp.Updated = &s.Updated
+		}
+		if rr, ok := tr.Get(name, jm.Labels); ok && t == metrics.Counter(0).Type() {
+			resp.Rate = &rr
+		}
+
+		b, err := json.Marshal(resp)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
+			return
+		}
+
 		w.Header().Add("Content-Type", "application/json")
 
-		_, err = w.Write(metrics.ToJSON(m, name))
+		_, err = w.Write(b)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Internal server error: %s", err), http.StatusInternalServerError)
 			return
@@ -73,11 +115,21 @@ func ValueJSONHandleFunc(st getter) func(http.ResponseWriter, *http.Request) {
 	}
 }
 
-func respondJSONNotFound(w http.ResponseWriter, t, name string) {
+// seriesJSON is the metric along with the information on its series.
+type seriesJSON struct {
+	metrics.JSONMetric
+	Updated *time.Time  `json:"updated,omitempty"`
+	Source  string      `json:"source,omitempty"`
+	Stale   bool        `json:"stale,omitempty"`
+	Rate    *rate.Rates `json:"rate,omitempty"`
+}
+
+func respondJSONNotFound(w http.ResponseWriter, t, name string, labels metrics.Labels) {
 	bb, err := json.Marshal(
 		metrics.JSONMetric{
-			ID:    name,
-			MType: t,
+			ID:     name,
+			MType:  t,
+			Labels: labels,
 		},
 	)
 	if err != nil {
@@ -97,5 +149,9 @@ func respondJSONNotFound(w http.ResponseWriter, t, name string) {
 }
 
 type getter interface {
-	Get(context.Context, string, string) (metrics.Metric, error)
+	Get(context.Context, string, string, metrics.Labels) (metrics.Metric, error)
+}
+
+type describer interface {
+	Describe(context.Context, string, string, metrics.Labels) (storage.Series, error)
 }
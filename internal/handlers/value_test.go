@@ -73,7 +73,7 @@ func TestValueHandleFunc(t *testing.T) {
 
 type oneMetricStorage struct{}
 
-func (oneMetricStorage) Get(_ context.Context, t, n string) (metrics.Metric, error) {
+func (oneMetricStorage) Get(_ context.Context, t, n string, _ metrics.Labels) (metrics.Metric, error) {
 	if t == "gauge" && n == "test" {
 		return metrics.Gauge(1.1), nil
 	}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/watch"
+)
+
+// keepAlive is the interval between the comments sent to keep an idle event
+// stream open.
+const keepAlive = 15 * time.Second
+
+// WatchHandleFunc returns the handler for the /watch endpoint that streams the
+// values of the series as they are updated as Server-Sent Events, i.e.
+//
+//	event: metric
+//	data: {"id":"PollCount","type":"counter","delta":5,"time":"2024-01-01T00:00:00Z"}
+//
+// The "prefix" and "type" query parameters, if set, select the series by name
+// prefix and type.
+func WatchHandleFunc(h *watch.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h == nil {
+			http.Error(w, "watching is not enabled", http.StatusConflict)
+			return
+		}
+
+		f := watch.Filter{
+			NamePrefix: r.URL.Query().Get("prefix"),
+			Type:       r.URL.Query().Get("type"),
+		}
+
+		if k, ok := auth.FromContext(r.Context()); ok && !k.AllowsPrefix(f.NamePrefix) {
+			http.Error(w, fmt.Sprintf("Forbidden: key %s may not watch %q", k.Name, f.NamePrefix), http.StatusForbidden)
+			return
+		}
+
+		rc := http.NewResponseController(w)
+
+		sub := h.Subscribe(f)
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			case e, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+
+				if err := writeEvent(w, e); err != nil {
+					return
+				}
+			}
+
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e watch.Event) error {
+	jm, ok := e.JSONMetric()
+	if !ok {
+		return nil
+	}
+
+	b, err := json.Marshal(struct {
+		metrics.JSONMetric
+		Time time.Time `json:"time"`
+	}{jm, e.Time})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: metric\ndata: %s\n\n", b)
+
+	return err
+}
@@ -2,12 +2,38 @@
 package hash
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const Header = "Hashsha256" // Signature header.
 
+// The headers (and gRPC metadata keys) of the stamp of a signature.
+const (
+	TimestampHeader = "X-Signature-Timestamp"
+	NonceHeader     = "X-Signature-Nonce"
+)
+
+// KeyIDHeader is the header (and gRPC metadata key) of the ID of the key an
+// HMAC signature is made with.
+const KeyIDHeader = "X-Signature-Key-Id"
+
+var (
+	// ErrUnknownKey is returned for a signature made with a key the server
+	// does not have.
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrMismatch is returned for a signature that does not match the
+	// message.
+	ErrMismatch = errors.New("signature does not match")
+)
+
 // Signature generates the signature for the given message and key.
 func Signature(msg []byte, key string) string {
 	kb := []byte(key)
@@ -16,3 +42,146 @@ func Signature(msg []byte, key string) string {
 	sig := sha256.Sum256(msg)
 	return hex.EncodeToString(sig[:])
 }
+
+// HMAC generates the HMAC-SHA256 signature for the given message and key.
+func HMAC(msg []byte, key string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(msg)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Stamp is the time and the one-off nonce a signature is made with, so that
+// the signed message can not be replayed.
+type Stamp struct {
+	Time  time.Time
+	Nonce string
+}
+
+// NewStamp returns a stamp with the current time and a random nonce.
+func NewStamp() Stamp {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return Stamp{Time: time.Now(), Nonce: hex.EncodeToString(b)}
+}
+
+// ParseStamp parses the stamp from the values of the headers. The time is in
+// Unix seconds.
+func ParseStamp(timestamp, nonce string) (Stamp, error) {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return Stamp{}, err
+	}
+
+	return Stamp{Time: time.Unix(ts, 0), Nonce: nonce}, nil
+}
+
+// Timestamp returns the value of the timestamp header.
+func (s Stamp) Timestamp() string {
+	return strconv.FormatInt(s.Time.Unix(), 10)
+}
+
+// Signature generates the signature for the given message and key stamped
+// with the time and nonce.
+func (s Stamp) Signature(msg []byte, key string) string {
+	return Signature(s.stamp(msg), key)
+}
+
+func (s Stamp) stamp(msg []byte) []byte {
+	stamped := make([]byte, 0, len(s.Nonce)+len(msg)+22)
+	stamped = append(stamped, s.Timestamp()...)
+	stamped = append(stamped, '\n')
+	stamped = append(stamped, s.Nonce...)
+	stamped = append(stamped, '\n')
+	stamped = append(stamped, msg...)
+
+	return stamped
+}
+
+// Signer signs the messages with a key. If the key has an ID, the signature is
+// the HMAC, otherwise it is the legacy Signature.
+type Signer struct {
+	ID  string
+	Key string
+}
+
+// Sign returns the signature of the message, stamped unless the stamp is
+// zero.
+func (s Signer) Sign(msg []byte, stamp Stamp) string {
+	if stamp != (Stamp{}) {
+		msg = stamp.stamp(msg)
+	}
+
+	if s.ID == "" {
+		return Signature(msg, s.Key)
+	}
+
+	return HMAC(msg, s.Key)
+}
+
+// Keys are the keys the signatures are verified with: the legacy one and the
+// HMAC ones by their IDs. Several HMAC keys can be active at once, so that the
+// clients can be switched to a new key one by one before the old one is
+// retired.
+type Keys struct {
+	Legacy string
+	HMAC   map[string]string
+}
+
+// ParseKeys parses the HMAC keys from a comma-separated list of ID=key pairs,
+// i.e. "2024-01=s3cr3t,2024-07=n3w".
+func ParseKeys(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	keys := make(map[string]string)
+
+	for _, pair := range strings.Split(s, ",") {
+		id, key, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || id == "" || key == "" {
+			return nil, fmt.Errorf("bad key %q, want ID=key", pair)
+		}
+
+		if _, ok := keys[id]; ok {
+			return nil, fmt.Errorf("duplicate key ID %s", id)
+		}
+
+		keys[id] = key
+	}
+
+	return keys, nil
+}
+
+// Enabled reports whether there are any keys at all.
+func (k Keys) Enabled() bool {
+	return k.Legacy != "" || len(k.HMAC) > 0
+}
+
+// Signer returns the signer for the key with the ID, or the legacy one if the
+// ID is empty.
+func (k Keys) Signer(id string) (Signer, bool) {
+	if id == "" {
+		return Signer{Key: k.Legacy}, k.Legacy != ""
+	}
+
+	key, ok := k.HMAC[id]
+
+	return Signer{ID: id, Key: key}, ok
+}
+
+// Verify checks the signature of the message made with the key with the ID,
+// stamped unless the stamp is zero.
+func (k Keys) Verify(msg []byte, sig, id string, stamp Stamp) error {
+	s, ok := k.Signer(id)
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(s.Sign(msg, stamp))) {
+		return ErrMismatch
+	}
+
+	return nil
+}
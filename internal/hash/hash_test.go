@@ -0,0 +1,76 @@
+package hash_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nekr0z/muhame/internal/hash"
+)
+
+func TestParseKeys(t *testing.T) {
+	t.Parallel()
+
+	keys, err := hash.ParseKeys("old=s3cr3t, new=n3w")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"old": "s3cr3t", "new": "n3w"}, keys)
+
+	keys, err = hash.ParseKeys("")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	for _, bad := range []string{"old", "=s3cr3t", "old=", "old=a,old=b"} {
+		_, err := hash.ParseKeys(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestKeys_Verify(t *testing.T) {
+	t.Parallel()
+
+	msg := []byte("message")
+	stamp := hash.Stamp{Time: time.Unix(1700000000, 0), Nonce: "abc"}
+
+	keys := hash.Keys{
+		Legacy: "legacy",
+		HMAC:   map[string]string{"old": "s3cr3t", "new": "n3w"},
+	}
+
+	tests := []struct {
+		name  string
+		sig   string
+		id    string
+		stamp hash.Stamp
+		want  error
+	}{
+		{name: "legacy", sig: hash.Signature(msg, "legacy")},
+		{name: "legacy stamped", sig: stamp.Signature(msg, "legacy"), stamp: stamp},
+		{name: "old", sig: hash.HMAC(msg, "s3cr3t"), id: "old"},
+		{name: "new stamped", sig: hash.Signer{ID: "new", Key: "n3w"}.Sign(msg, stamp), id: "new", stamp: stamp},
+		{name: "wrong key", sig: hash.HMAC(msg, "s3cr3t"), id: "new", want: hash.ErrMismatch},
+		{name: "legacy as HMAC", sig: hash.Signature(msg, "s3cr3t"), id: "old", want: hash.ErrMismatch},
+		{name: "not stamped", sig: hash.HMAC(msg, "n3w"), id: "new", stamp: stamp, want: hash.ErrMismatch},
+		{name: "unknown", sig: hash.HMAC(msg, "s3cr3t"), id: "older", want: hash.ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := keys.Verify(msg, tt.sig, tt.id, tt.stamp)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+
+	t.Run("no legacy", func(t *testing.T) {
+		t.Parallel()
+
+		keys := hash.Keys{HMAC: map[string]string{"new": "n3w"}}
+		assert.True(t, keys.Enabled())
+
+		err := keys.Verify(msg, hash.Signature(msg, ""), "", hash.Stamp{})
+		assert.ErrorIs(t, err, hash.ErrUnknownKey)
+	})
+}
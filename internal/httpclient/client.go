@@ -3,7 +3,9 @@ package httpclient
 
 import (
 	"bytes"
+	"context"
 	"crypto/rsa"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/go-resty/resty/v2"
 
+	"github.com/nekr0z/muhame/internal/auth"
 	"github.com/nekr0z/muhame/internal/crypt"
 	"github.com/nekr0z/muhame/internal/hash"
 )
@@ -24,9 +27,10 @@ const (
 // Client is a client for HTTP requests.
 type Client struct {
 	c      *http.Client
-	key    string
+	signer hash.Signer
 	pubKey *rsa.PublicKey
 	ip     string
+	token  string
 }
 
 // New returns a new Client.
@@ -41,7 +45,14 @@ func New() Client {
 
 // WithKey sets the signing key for the client.
 func (c Client) WithKey(key string) Client {
-	c.key = key
+	c.signer.Key = key
+	return c
+}
+
+// WithKeyID makes the client sign the requests with the HMAC of the key and
+// send the ID of the key along.
+func (c Client) WithKeyID(id string) Client {
+	c.signer.ID = id
 	return c
 }
 
@@ -51,6 +62,28 @@ func (c Client) WithCrypto(key *rsa.PublicKey) Client {
 	return c
 }
 
+// WithToken sets the API key token for the client.
+func (c Client) WithToken(token string) Client {
+	c.token = token
+	return c
+}
+
+// WithTLS sets the TLS configuration for the client.
+func (c Client) WithTLS(cfg *tls.Config) Client {
+	if cfg == nil {
+		return c
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = cfg
+
+	hc := *c.c
+	hc.Transport = tr
+	c.c = &hc
+
+	return c
+}
+
 // getLocalIP returns the first non-loopback address of the machine.
 func getLocalIP() string {
 	addrs, err := net.InterfaceAddrs()
@@ -69,6 +102,11 @@ func getLocalIP() string {
 
 // Send sends a request to the given endpoint.
 func (c Client) Send(msg []byte, endpoint string) (int, error) {
+	return c.SendContext(context.Background(), msg, endpoint)
+}
+
+// SendContext is Send with the context of the request.
+func (c Client) SendContext(ctx context.Context, msg []byte, endpoint string) (int, error) {
 	if c.pubKey != nil {
 		ciphertext, err := crypt.Encrypt(msg, c.pubKey)
 		if err != nil {
@@ -78,7 +116,7 @@ func (c Client) Send(msg []byte, endpoint string) (int, error) {
 	}
 
 	b := bytes.NewBuffer(msg)
-	req, err := http.NewRequest(http.MethodPost, endpoint, b)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, b)
 	if err != nil {
 		return 0, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -86,15 +124,25 @@ func (c Client) Send(msg []byte, endpoint string) (int, error) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Content-Encoding", "gzip")
 
-	if c.key != "" {
-		sig := hash.Signature(msg, c.key)
-		req.Header.Set(hash.Header, sig)
+	if c.signer.Key != "" {
+		stamp := hash.NewStamp()
+		req.Header.Set(hash.Header, c.signer.Sign(msg, stamp))
+		req.Header.Set(hash.TimestampHeader, stamp.Timestamp())
+		req.Header.Set(hash.NonceHeader, stamp.Nonce)
+
+		if c.signer.ID != "" {
+			req.Header.Set(hash.KeyIDHeader, c.signer.ID)
+		}
 	}
 
 	if c.ip != "" {
 		req.Header.Set(HeaderRealIP, c.ip)
 	}
 
+	if c.token != "" {
+		req.Header.Set(auth.Header, auth.Bearer(c.token))
+	}
+
 	resp, err := c.c.Do(req)
 	if err != nil {
 		return 0, err
@@ -1,7 +1,11 @@
 package httpclient_test
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/hex"
 	"io"
 	"net/http"
@@ -18,10 +22,15 @@ func TestSend_Signed(t *testing.T) {
 	msg := "test message"
 	key := "testkey"
 
-	sig := sha256.Sum256([]byte(msg + key))
-	want := hex.EncodeToString(sig[:])
-
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts := r.Header.Get(hash.TimestampHeader)
+		nonce := r.Header.Get(hash.NonceHeader)
+		assert.NotEmpty(t, ts)
+		assert.NotEmpty(t, nonce)
+
+		sig := sha256.Sum256([]byte(ts + "\n" + nonce + "\n" + msg + key))
+		want := hex.EncodeToString(sig[:])
+
 		got := r.Header.Get(hash.Header)
 		assert.Equal(t, want, got)
 		m, _ := io.ReadAll(r.Body)
@@ -33,6 +42,28 @@ func TestSend_Signed(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestSend_KeyID(t *testing.T) {
+	msg := "test message"
+	key := "testkey"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "2024-07", r.Header.Get(hash.KeyIDHeader))
+
+		ts := r.Header.Get(hash.TimestampHeader)
+		nonce := r.Header.Get(hash.NonceHeader)
+
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(ts + "\n" + nonce + "\n" + msg))
+		want := hex.EncodeToString(mac.Sum(nil))
+
+		assert.Equal(t, want, r.Header.Get(hash.Header))
+	}))
+
+	c := httpclient.New().WithKey(key).WithKeyID("2024-07")
+	_, err := c.Send([]byte(msg), srv.URL)
+	assert.NoError(t, err)
+}
+
 func TestSend_RealIP(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ip := r.Header.Get("X-Real-IP")
@@ -48,3 +79,45 @@ func TestSend_RealIP(t *testing.T) {
 	_, err := c.Send([]byte("test message"), srv.URL)
 	assert.NoError(t, err)
 }
+
+func TestSendContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("canceled request should not be sent")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := httpclient.New().SendContext(ctx, []byte("test message"), srv.URL)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSend_TLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "test message", string(m))
+	}))
+	defer srv.Close()
+
+	_, err := httpclient.New().Send([]byte("test message"), srv.URL)
+	assert.Error(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	c := httpclient.New().WithTLS(&tls.Config{RootCAs: pool})
+	code, err := c.Send([]byte("test message"), srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestSend_Token(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer s3cr3t", r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	_, err := httpclient.New().WithToken("s3cr3t").Send([]byte("test message"), srv.URL)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,183 @@
+// Package influx implements parsing of the InfluxDB line protocol.
+package influx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// Parse parses the lines of the InfluxDB line protocol. Each field becomes a
+// metric named "<measurement>_<field>" (or just "<measurement>" for a field
+// named "value") with the tags as labels. Float, integer, unsigned and boolean
+// fields become gauges, since the line protocol carries the current values
+// rather than the increments, and string fields are skipped. Timestamps are
+// validated, but not used.
+func Parse(r io.Reader) ([]metrics.Named, error) {
+	var mm []metrics.Named
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	n := 0
+	for scanner.Scan() {
+		n++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parsed, err := ParseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		mm = append(mm, parsed...)
+	}
+
+	return mm, scanner.Err()
+}
+
+// ParseLine parses a single line of the InfluxDB line protocol.
+func ParseLine(line string) ([]metrics.Named, error) {
+	sections := split(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, fmt.Errorf("malformed line")
+	}
+
+	if len(sections) == 3 {
+		if _, err := strconv.ParseInt(sections[2], 10, 64); err != nil {
+			return nil, fmt.Errorf("bad timestamp: %w", err)
+		}
+	}
+
+	key := split(sections[0], ',', false)
+
+	measurement := unescape(key[0])
+	if measurement == "" {
+		return nil, fmt.Errorf("no measurement")
+	}
+
+	var labels metrics.Labels
+	for _, tag := range key[1:] {
+		k, v, ok := cutUnescaped(tag, '=')
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("bad tag %q", tag)
+		}
+
+		if labels == nil {
+			labels = make(metrics.Labels)
+		}
+		labels[unescape(k)] = unescape(v)
+	}
+
+	if err := labels.Validate(); err != nil {
+		return nil, err
+	}
+
+	var mm []metrics.Named
+	for _, field := range split(sections[1], ',', true) {
+		k, v, ok := cutUnescaped(field, '=')
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("bad field %q", field)
+		}
+
+		m, err := parseValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("bad field %q: %w", field, err)
+		}
+
+		if m == nil {
+			continue
+		}
+
+		name := measurement
+		if k = unescape(k); k != "value" {
+			name += "_" + k
+		}
+
+		mm = append(mm, metrics.Named{
+			Name:   name,
+			Labels: labels.Clone(),
+			Metric: m,
+		})
+	}
+
+	return mm, nil
+}
+
+// parseValue parses a field value. It returns nil for strings.
+func parseValue(v string) (metrics.Metric, error) {
+	switch v {
+	case "t", "T", "true", "True", "TRUE":
+		return metrics.Gauge(1), nil
+	case "f", "F", "false", "False", "FALSE":
+		return metrics.Gauge(0), nil
+	}
+
+	switch {
+	case strings.HasPrefix(v, `"`):
+		if len(v) < 2 || !strings.HasSuffix(v, `"`) {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		return nil, nil
+	case strings.HasSuffix(v, "i"):
+		i, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+		return metrics.Gauge(i), err
+	case strings.HasSuffix(v, "u"):
+		u, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+		return metrics.Gauge(u), err
+	default:
+		f, err := strconv.ParseFloat(v, 64)
+		return metrics.Gauge(f), err
+	}
+}
+
+// split splits the string by the separator, skipping the escaped separators
+// and, if quotes is true, the ones inside double quotes.
+func split(s string, sep byte, quotes bool) []string {
+	var (
+		res    []string
+		start  int
+		quoted bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			res = append(res, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(res, s[start:])
+}
+
+// cutUnescaped slices the string around the first unescaped separator.
+func cutUnescaped(s string, sep byte) (string, string, bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+
+	return s, "", false
+}
+
+var unescaper = strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ", `\\`, `\`)
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
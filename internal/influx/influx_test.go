@@ -0,0 +1,93 @@
+package influx_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nekr0z/muhame/internal/influx"
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    []metrics.Named
+		wantErr bool
+	}{
+		{
+			name: "float",
+			line: "cpu,host=a usage=0.5 1556813561098000000",
+			want: []metrics.Named{
+				{Name: "cpu_usage", Labels: metrics.Labels{"host": "a"}, Metric: metrics.Gauge(0.5)},
+			},
+		},
+		{
+			name: "value field",
+			line: "temperature value=21.5",
+			want: []metrics.Named{
+				{Name: "temperature", Metric: metrics.Gauge(21.5)},
+			},
+		},
+		{
+			name: "all field types",
+			line: `disk,host=a,path=/var\ log used=10i,free=20u,ok=true,err=F,model="ssd, fast"`,
+			want: []metrics.Named{
+				{Name: "disk_used", Labels: metrics.Labels{"host": "a", "path": "/var log"}, Metric: metrics.Gauge(10)},
+				{Name: "disk_free", Labels: metrics.Labels{"host": "a", "path": "/var log"}, Metric: metrics.Gauge(20)},
+				{Name: "disk_ok", Labels: metrics.Labels{"host": "a", "path": "/var log"}, Metric: metrics.Gauge(1)},
+				{Name: "disk_err", Labels: metrics.Labels{"host": "a", "path": "/var log"}, Metric: metrics.Gauge(0)},
+			},
+		},
+		{
+			name: "negative integer",
+			line: "temperature value=-5i",
+			want: []metrics.Named{
+				{Name: "temperature", Metric: metrics.Gauge(-5)},
+			},
+		},
+		{
+			name: "escaped measurement",
+			line: `my\,cpu\ load value=1`,
+			want: []metrics.Named{
+				{Name: "my,cpu load", Metric: metrics.Gauge(1)},
+			},
+		},
+		{name: "no fields", line: "cpu,host=a", wantErr: true},
+		{name: "bad value", line: "cpu usage=high", wantErr: true},
+		{name: "bad integer", line: "cpu usage=1.5i", wantErr: true},
+		{name: "bad timestamp", line: "cpu usage=1 now", wantErr: true},
+		{name: "bad tag", line: "cpu,host usage=1", wantErr: true},
+		{name: "bad tag key", line: "cpu,host-name=a usage=1", wantErr: true},
+		{name: "unterminated string", line: `cpu model="ssd`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := influx.ParseLine(tt.line)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	in := `# comment
+cpu,host=a usage=0.5
+
+mem,host=a used=1i
+`
+	got, err := influx.Parse(strings.NewReader(in))
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+
+	_, err = influx.Parse(strings.NewReader("cpu usage=0.5\ncpu usage"))
+	assert.ErrorContains(t, err, "line 2")
+}
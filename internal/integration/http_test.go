@@ -76,7 +76,7 @@ func TestHTTP(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	got, err := st.Get(context.Background(), "counter", "PollCount")
+	got, err := st.Get(context.Background(), "counter", "PollCount", nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, got)
 }
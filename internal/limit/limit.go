@@ -0,0 +1,181 @@
+// Package limit protects the server from the clients that send too much, too
+// often.
+package limit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/auth"
+)
+
+var (
+	// ErrRateLimited is returned when the client has exceeded its rate.
+	ErrRateLimited = errors.New("rate limit exceeded")
+	// ErrTooLarge is returned for a request that exceeds the size limits.
+	ErrTooLarge = errors.New("request too large")
+)
+
+// minSweep is the number of clients to remember before the idle ones are
+// first forgotten.
+const minSweep = 1024
+
+// Config is the limits. The zero values mean no limit.
+type Config struct {
+	// Rate is the requests per second each client is allowed on average.
+	Rate float64
+	// Burst is the requests a client is allowed at once, the rate rounded
+	// up if not set.
+	Burst int
+	// MaxBody is the size of the request body as sent, in bytes.
+	MaxBody int64
+	// MaxDecompressed is the size of the request body after decompression,
+	// in bytes.
+	MaxDecompressed int64
+	// MaxBulk is the number of metrics in a bulk request.
+	MaxBulk int
+}
+
+// Limiter enforces the limits. The rate is limited with a token bucket per
+// client.
+type Limiter struct {
+	Config
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweepAt int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New returns a new limiter.
+func New(cfg Config) *Limiter {
+	if cfg.Rate > 0 && cfg.Burst <= 0 {
+		cfg.Burst = int(math.Ceil(cfg.Rate))
+	}
+
+	return &Limiter{
+		Config:  cfg,
+		buckets: make(map[string]*bucket),
+		sweepAt: minSweep,
+	}
+}
+
+// Allow reports whether the client may make a request now, and takes a token
+// from its bucket if so. Everything is allowed if there is no rate limit.
+func (l *Limiter) Allow(client string) bool {
+	if l == nil || l.Rate <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	b, ok := l.buckets[client]
+	if !ok {
+		if len(l.buckets) >= l.sweepAt {
+			l.sweep(now)
+		}
+
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
+
+// sweep forgets the clients whose buckets are full by now, since they are no
+// different from the new ones.
+func (l *Limiter) sweep(now time.Time) {
+	for c, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.Rate >= float64(l.Burst) {
+			delete(l.buckets, c)
+		}
+	}
+
+	l.sweepAt = max(minSweep, 2*len(l.buckets))
+}
+
+// Client returns the client to limit the request of: the API key, if the
+// value of the Authorization header is one of the keys, or else the address.
+func Client(keys auth.Keys, header string, ip net.IP) string {
+	if header != "" {
+		if k, ok := keys.Authenticate(header); ok {
+			return "key:" + k.Name
+		}
+	}
+
+	return "ip:" + ip.String()
+}
+
+// Read reads the whole of r, failing with ErrTooLarge if there are more than
+// n bytes.
+func Read(r io.Reader, n int64) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, n+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(b)) > n {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, n)
+	}
+
+	return b, nil
+}
+
+type maxBulkCtx struct{}
+
+// WithMaxBulk returns the context of a request limited to n metrics per bulk.
+func WithMaxBulk(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxBulkCtx{}, n)
+}
+
+// CheckBulk returns ErrTooLarge if the bulk of n metrics exceeds the limit of
+// the context, if any.
+func CheckBulk(ctx context.Context, n int) error {
+	limit, ok := ctx.Value(maxBulkCtx{}).(int)
+	if !ok || limit <= 0 || n <= limit {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d metrics in bulk, at most %d allowed", ErrTooLarge, n, limit)
+}
+
+type maxDecompressedCtx struct{}
+
+// WithMaxDecompressed returns the context of a request limited to n bytes of
+// decompressed payload.
+func WithMaxDecompressed(ctx context.Context, n int64) context.Context {
+	return context.WithValue(ctx, maxDecompressedCtx{}, n)
+}
+
+// CheckDecompressed returns ErrTooLarge if the payload of n bytes
+// decompressed exceeds the limit of the context, if any.
+func CheckDecompressed(ctx context.Context, n int64) error {
+	limit, ok := ctx.Value(maxDecompressedCtx{}).(int64)
+	if !ok || limit <= 0 || n <= limit {
+		return nil
+	}
+
+	return fmt.Errorf("%w: more than %d bytes decompressed", ErrTooLarge, limit)
+}
@@ -0,0 +1,97 @@
+package limit_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/limit"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	t.Parallel()
+
+	l := limit.New(limit.Config{Rate: 50, Burst: 2})
+
+	assert.True(t, l.Allow("a"))
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"), "over burst")
+
+	assert.True(t, l.Allow("b"), "other client")
+
+	time.Sleep(50 * time.Millisecond)
+
+	assert.True(t, l.Allow("a"), "refilled")
+}
+
+func TestLimiter_DefaultBurst(t *testing.T) {
+	t.Parallel()
+
+	l := limit.New(limit.Config{Rate: 0.5})
+	assert.Equal(t, 1, l.Burst)
+
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+}
+
+func TestLimiter_NoRate(t *testing.T) {
+	t.Parallel()
+
+	l := limit.New(limit.Config{MaxBulk: 10})
+	for i := 0; i < 100; i++ {
+		require.True(t, l.Allow("a"))
+	}
+
+	var nl *limit.Limiter
+	assert.True(t, nl.Allow("a"))
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	keys := auth.Keys{{Name: "agent", Token: "s3cr3t", Scopes: []auth.Scope{auth.ScopeWrite}}}
+	ip := net.ParseIP("10.0.0.1")
+
+	assert.Equal(t, "key:agent", limit.Client(keys, auth.Bearer("s3cr3t"), ip))
+	assert.Equal(t, "ip:10.0.0.1", limit.Client(keys, auth.Bearer("wrong"), ip))
+	assert.Equal(t, "ip:10.0.0.1", limit.Client(nil, "", ip))
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	b, err := limit.Read(strings.NewReader("12345"), 5)
+	require.NoError(t, err)
+	assert.Equal(t, "12345", string(b))
+
+	_, err = limit.Read(strings.NewReader("123456"), 5)
+	assert.ErrorIs(t, err, limit.ErrTooLarge)
+}
+
+func TestCheckBulk(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	assert.NoError(t, limit.CheckBulk(ctx, 1000))
+
+	ctx = limit.WithMaxBulk(ctx, 10)
+	assert.NoError(t, limit.CheckBulk(ctx, 10))
+	assert.ErrorIs(t, limit.CheckBulk(ctx, 11), limit.ErrTooLarge)
+}
+
+func TestCheckDecompressed(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	assert.NoError(t, limit.CheckDecompressed(ctx, 1<<20))
+
+	ctx = limit.WithMaxDecompressed(ctx, 1024)
+	assert.NoError(t, limit.CheckDecompressed(ctx, 1024))
+	assert.ErrorIs(t, limit.CheckDecompressed(ctx, 1025), limit.ErrTooLarge)
+}
@@ -11,6 +11,9 @@ type JSONMetric struct {
 	MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
 	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
 	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
+
+	Histogram *Histogram `json:"histogram,omitempty"` // значение метрики в случае передачи histogram
+	Labels    Labels     `json:"labels,omitempty"`    // метки серии
 }
 
 // Metric returns the Metric value.
@@ -26,6 +29,14 @@ func (j JSONMetric) Metric() (Metric, error) {
 			return nil, fmt.Errorf("counter metric has no delta")
 		}
 		return Counter(*j.Delta), nil
+	case Histogram{}.Type():
+		if j.Histogram == nil {
+			return nil, fmt.Errorf("histogram metric has no histogram")
+		}
+		if err := j.Histogram.Validate(); err != nil {
+			return nil, err
+		}
+		return *j.Histogram, nil
 	default:
 		return nil, fmt.Errorf("unknown metric type %s", j.MType)
 	}
@@ -33,7 +44,11 @@ func (j JSONMetric) Metric() (Metric, error) {
 
 // Named returns the Named metric.
 func (j JSONMetric) Named() (Named, error) {
-	n := Named{Name: j.ID}
+	n := Named{Name: j.ID, Labels: j.Labels.Clone()}
+
+	if err := n.Labels.Validate(); err != nil {
+		return n, err
+	}
 
 	var err error
 
@@ -44,10 +59,32 @@ func (j JSONMetric) Named() (Named, error) {
 
 // ToJSON converts the metric to JSON format.
 func ToJSON(m Metric, name string) []byte {
+	return Named{Name: name, Metric: m}.ToJSON()
+}
+
+// ToJSON converts the named metric, including its labels, to JSON format.
+func (n Named) ToJSON() []byte {
+	jm, ok := n.JSONMetric()
+	if !ok {
+		return nil
+	}
+
+	b, err := json.Marshal(jm)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+// JSONMetric returns the JSONMetric for the named metric. It returns false if
+// the metric is of an unknown type.
+func (n Named) JSONMetric() (JSONMetric, bool) {
 	var jm JSONMetric
-	jm.ID = name
+	jm.ID = n.Name
+	jm.Labels = n.Labels
 
-	switch m := m.(type) {
+	switch m := n.Metric.(type) {
 	case Gauge:
 		jm.MType = Gauge(0).Type()
 		v := float64(m)
@@ -56,34 +93,24 @@ func ToJSON(m Metric, name string) []byte {
 		jm.MType = Counter(0).Type()
 		d := int64(m)
 		jm.Delta = &d
+	case Histogram:
+		jm.MType = Histogram{}.Type()
+		jm.Histogram = &m
 	default:
-		return nil
-	}
-
-	b, err := json.Marshal(jm)
-	if err != nil {
-		panic(err)
+		return jm, false
 	}
 
-	return b
+	return jm, true
 }
 
 // FromJSON unmarshals the JSON format to Metric.
 func FromJSON(b []byte) (Named, error) {
 	var jm JSONMetric
-	var n Named
 
 	err := json.Unmarshal(b, &jm)
 	if err != nil {
-		return n, err
-	}
-
-	n.Metric, err = jm.Metric()
-	if err != nil {
-		return n, err
+		return Named{}, err
 	}
 
-	n.Name = jm.ID
-
-	return n, nil
+	return jm.Named()
 }
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Labels is a set of labels that, along with the name and type, identify a
+// metric series.
+type Labels map[string]string
+
+// ParseLabels parses the canonical representation of labels, as returned by
+// Labels.String.
+func ParseLabels(s string) (Labels, error) {
+	v, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse labels: %w", err)
+	}
+
+	return FromValues(v)
+}
+
+// FromValues converts URL query values to labels. Only the first value of
+// each key is used.
+func FromValues(v url.Values) (Labels, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+
+	l := make(Labels, len(v))
+	for k := range v {
+		l[k] = v.Get(k)
+	}
+
+	return l, l.Validate()
+}
+
+// String returns the canonical representation of labels: sorted and
+// URL-encoded key=value pairs. Empty labels produce an empty string.
+func (l Labels) String() string {
+	v := make(url.Values, len(l))
+	for k, val := range l {
+		v.Set(k, val)
+	}
+
+	return v.Encode()
+}
+
+// Pretty returns the human-readable representation of labels, e.g.
+// `{env="prod",host="a"}`. Empty labels produce an empty string.
+func (l Labels) Pretty() string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(l))
+	for _, k := range slices.Sorted(maps.Keys(l)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, l[k]))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Matches reports whether l has all the labels of the filter with the same
+// values. Any labels match an empty filter.
+func (l Labels) Matches(filter Labels) bool {
+	for k, v := range filter {
+		if have, ok := l[k]; !ok || have != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Clone returns a copy of labels, or nil if there are none.
+func (l Labels) Clone() Labels {
+	if len(l) == 0 {
+		return nil
+	}
+
+	return maps.Clone(l)
+}
+
+// Validate checks that all label names are valid.
+func (l Labels) Validate() error {
+	for k := range l {
+		if !labelNameRe.MatchString(k) {
+			return fmt.Errorf("invalid label name %q", k)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+func TestLabels(t *testing.T) {
+	l := metrics.Labels{"service": "web server", "host": "a&b"}
+
+	t.Run("string", func(t *testing.T) {
+		s := l.String()
+		assert.Equal(t, "host=a%26b&service=web+server", s)
+
+		got, err := metrics.ParseLabels(s)
+		assert.NoError(t, err)
+		assert.Equal(t, l, got)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		assert.Equal(t, "", metrics.Labels(nil).String())
+		assert.Equal(t, "", metrics.Labels{}.Pretty())
+
+		got, err := metrics.ParseLabels("")
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("pretty", func(t *testing.T) {
+		assert.Equal(t, `{host="a&b",service="web server"}`, l.Pretty())
+	})
+
+	t.Run("matches", func(t *testing.T) {
+		assert.True(t, l.Matches(nil))
+		assert.True(t, l.Matches(metrics.Labels{"host": "a&b"}))
+		assert.False(t, l.Matches(metrics.Labels{"host": "c"}))
+		assert.False(t, l.Matches(metrics.Labels{"env": "prod"}))
+		assert.False(t, metrics.Labels(nil).Matches(metrics.Labels{"env": "prod"}))
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		assert.NoError(t, l.Validate())
+		assert.Error(t, metrics.Labels{"1host": "a"}.Validate())
+		assert.Error(t, metrics.Labels{"host-name": "a"}.Validate())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		n := metrics.Named{Name: "test", Labels: l, Metric: metrics.Gauge(1)}
+
+		got, err := metrics.FromJSON(n.ToJSON())
+		assert.NoError(t, err)
+		assert.Equal(t, n, got)
+	})
+}
@@ -3,7 +3,10 @@ package metrics
 
 import (
 	"fmt"
+	"math"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 // Metric represents a metric.
@@ -13,9 +16,10 @@ type Metric interface {
 	Type() string
 }
 
-// Named represents a named metric.
+// Named represents a named metric. Name, type and labels identify a series.
 type Named struct {
-	Name string
+	Name   string
+	Labels Labels
 	Metric
 }
 
@@ -34,6 +38,12 @@ func Parse(t, v string) (Metric, error) {
 			return nil, fmt.Errorf("cannot parse counter value: %w", err)
 		}
 		return Counter(res), nil
+	case Histogram{}.Type():
+		res, err := parseHistogram(v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse histogram value: %w", err)
+		}
+		return res, nil
 	default:
 		return nil, fmt.Errorf("unknown metric type %s", t)
 	}
@@ -86,3 +96,170 @@ func (c Counter) Update(m Metric) (Metric, error) {
 func (c Counter) Type() string {
 	return "counter"
 }
+
+// Histogram represents a histogram metric: the number of observations that
+// fell into each of the buckets, along with their sum and total count.
+type Histogram struct {
+	Bounds []float64 `json:"bounds"` // upper bounds of buckets, ascending
+	Counts []uint64  `json:"counts"` // per bucket, the last one is for +Inf
+	Sum    float64   `json:"sum"`
+	Count  uint64    `json:"count"`
+}
+
+var _ Metric = Histogram{}
+
+// NewHistogram returns an empty histogram with the given bucket bounds.
+func NewHistogram(bounds []float64) Histogram {
+	return Histogram{
+		Bounds: slices.Clone(bounds),
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Zero returns the zero value of the same type as the metric. The zero
+// histogram keeps the bucket bounds.
+func Zero(m Metric) Metric {
+	switch v := m.(type) {
+	case Gauge:
+		return Gauge(0)
+	case Counter:
+		return Counter(0)
+	case Histogram:
+		return NewHistogram(v.Bounds)
+	default:
+		return m
+	}
+}
+
+// Observe records a single observation.
+func (h *Histogram) Observe(v float64) {
+	i, _ := slices.BinarySearch(h.Bounds, v)
+	h.Counts[i]++
+	h.Sum += v
+	h.Count++
+}
+
+// String implements the Stringer interface. The result can be fed back to
+// Parse.
+func (h Histogram) String() string {
+	bounds := make([]string, len(h.Bounds))
+	for i, b := range h.Bounds {
+		bounds[i] = fmt.Sprintf("%g", b)
+	}
+
+	counts := make([]string, len(h.Counts))
+	for i, c := range h.Counts {
+		counts[i] = fmt.Sprintf("%d", c)
+	}
+
+	return fmt.Sprintf("%s;%s;%g;%d", strings.Join(bounds, ","), strings.Join(counts, ","), h.Sum, h.Count)
+}
+
+// Update updates the metric value by merging the bucket counts, sums and
+// counts. Both histograms must have the same bucket bounds.
+func (h Histogram) Update(m Metric) (Metric, error) {
+	n, ok := m.(Histogram)
+	if !ok {
+		return h, fmt.Errorf("cannot update histogram with non-histogram metric")
+	}
+
+	if !slices.Equal(h.Bounds, n.Bounds) {
+		return h, fmt.Errorf("cannot update histogram with different bucket bounds")
+	}
+
+	res := Histogram{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: make([]uint64, len(h.Counts)),
+		Sum:    h.Sum + n.Sum,
+		Count:  h.Count + n.Count,
+	}
+
+	for i := range res.Counts {
+		res.Counts[i] = h.Counts[i] + n.Counts[i]
+	}
+
+	return res, nil
+}
+
+// Type returns the metric type.
+func (h Histogram) Type() string {
+	return "histogram"
+}
+
+// Validate checks that the histogram is consistent. The counts may not exceed
+// math.MaxInt64, as that is the most that the database can store.
+func (h Histogram) Validate() error {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return fmt.Errorf("histogram with %d bounds must have %d counts, got %d", len(h.Bounds), len(h.Bounds)+1, len(h.Counts))
+	}
+
+	for i, b := range h.Bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) {
+			return fmt.Errorf("histogram bounds must be finite")
+		}
+
+		if i > 0 && b <= h.Bounds[i-1] {
+			return fmt.Errorf("histogram bounds must be ascending")
+		}
+	}
+
+	var total uint64
+	for _, c := range h.Counts {
+		if c > math.MaxInt64-total {
+			return fmt.Errorf("histogram count is too large")
+		}
+		total += c
+	}
+
+	if total != h.Count {
+		return fmt.Errorf("histogram count %d does not match the sum of the counts %d", h.Count, total)
+	}
+
+	return nil
+}
+
+func parseHistogram(v string) (Histogram, error) {
+	var h Histogram
+
+	parts := strings.Split(v, ";")
+	if len(parts) != 4 {
+		return h, fmt.Errorf("histogram must be \"bounds;counts;sum;count\"")
+	}
+
+	for _, s := range splitList(parts[0]) {
+		b, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return h, fmt.Errorf("bad bound: %w", err)
+		}
+		h.Bounds = append(h.Bounds, b)
+	}
+
+	for _, s := range splitList(parts[1]) {
+		c, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return h, fmt.Errorf("bad count: %w", err)
+		}
+		h.Counts = append(h.Counts, c)
+	}
+
+	var err error
+
+	h.Sum, err = strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return h, fmt.Errorf("bad sum: %w", err)
+	}
+
+	h.Count, err = strconv.ParseUint(parts[3], 10, 64)
+	if err != nil {
+		return h, fmt.Errorf("bad count: %w", err)
+	}
+
+	return h, h.Validate()
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
@@ -30,6 +30,62 @@ func TestParse(t *testing.T) {
 			want:    metrics.Counter(12),
 			wantErr: false,
 		},
+		{
+			name:    "histogram",
+			t:       "histogram",
+			v:       "0.5,1;1,2,0;2.1;3",
+			want:    metrics.Histogram{Bounds: []float64{0.5, 1}, Counts: []uint64{1, 2, 0}, Sum: 2.1, Count: 3},
+			wantErr: false,
+		},
+		{
+			name:    "histogram without bounds",
+			t:       "histogram",
+			v:       ";4;10;4",
+			want:    metrics.Histogram{Counts: []uint64{4}, Sum: 10, Count: 4},
+			wantErr: false,
+		},
+		{
+			name:    "histogram with wrong counts",
+			t:       "histogram",
+			v:       "0.5,1;1,2;2.1;3",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "histogram with unsorted bounds",
+			t:       "histogram",
+			v:       "1,0.5;1,2,0;2.1;3",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "histogram with wrong total count",
+			t:       "histogram",
+			v:       "0.5,1;1,2,0;2.1;4",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "histogram with infinite bound",
+			t:       "histogram",
+			v:       "0.5,+Inf;1,2,0;2.1;3",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "histogram with NaN bound",
+			t:       "histogram",
+			v:       "NaN;1,2;2.1;3",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "histogram with count too large",
+			t:       "histogram",
+			v:       ";9223372036854775808;1;9223372036854775808",
+			want:    nil,
+			wantErr: true,
+		},
 		{
 			name:    "parse error",
 			t:       "counter",
@@ -60,3 +116,58 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestHistogram(t *testing.T) {
+	h := metrics.NewHistogram([]float64{1, 5})
+
+	for _, v := range []float64{0.5, 1, 3, 10} {
+		h.Observe(v)
+	}
+
+	assert.Equal(t, []uint64{2, 1, 1}, h.Counts)
+	assert.Equal(t, 14.5, h.Sum)
+	assert.Equal(t, uint64(4), h.Count)
+
+	t.Run("string", func(t *testing.T) {
+		got, err := metrics.Parse(h.Type(), h.String())
+		assert.NoError(t, err)
+		assert.Equal(t, h, got)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		got, err := h.Update(h)
+		assert.NoError(t, err)
+		assert.Equal(t, metrics.Histogram{
+			Bounds: []float64{1, 5},
+			Counts: []uint64{4, 2, 2},
+			Sum:    29,
+			Count:  8,
+		}, got)
+		assert.Equal(t, []uint64{2, 1, 1}, h.Counts)
+	})
+
+	t.Run("different bounds", func(t *testing.T) {
+		_, err := h.Update(metrics.NewHistogram([]float64{1, 2}))
+		assert.Error(t, err)
+	})
+
+	t.Run("not a histogram", func(t *testing.T) {
+		_, err := h.Update(metrics.Gauge(1))
+		assert.Error(t, err)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		got, err := metrics.FromJSON(metrics.ToJSON(h, "test"))
+		assert.NoError(t, err)
+		assert.Equal(t, metrics.Named{Name: "test", Metric: h}, got)
+	})
+}
+
+func TestZero(t *testing.T) {
+	h := metrics.NewHistogram([]float64{1, 2})
+	h.Observe(3)
+
+	assert.Equal(t, metrics.Gauge(0), metrics.Zero(metrics.Gauge(1.5)))
+	assert.Equal(t, metrics.Counter(0), metrics.Zero(metrics.Counter(3)))
+	assert.Equal(t, metrics.NewHistogram([]float64{1, 2}), metrics.Zero(h))
+}
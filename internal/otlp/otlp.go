@@ -0,0 +1,448 @@
+// Package otlp implements the OpenTelemetry (OTLP) metrics receiver.
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/limit"
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// Receiver receives OTLP metrics and saves them into storage.
+//
+// Gauges and non-monotonic cumulative sums become gauges, monotonic sums
+// become counters, and histograms with explicit buckets become histograms.
+// Cumulative sums and histograms are converted to deltas, so the receiver
+// keeps the last seen value of each such series. The first point of a series
+// that started before the receiver knew of it, i.e. before the server was
+// restarted, is only taken as the baseline and adds nothing, since its value
+// may have been counted already. The series not seen for an hour are
+// forgotten. Data point attributes become
+// labels, along with the "service.name" resource attribute as "service_name".
+// Names of attributes are sanitised to be valid label names. Other kinds of
+// metrics and data points are rejected.
+type Receiver struct {
+	st Storage
+
+	mu         sync.Mutex
+	cumulative map[string]cumulative
+	since      time.Time
+	sweepAt    int
+
+	colmetricspb.UnimplementedMetricsServiceServer
+}
+
+// The cumulative series not seen for forgetAfter are forgotten once there are
+// at least minSweep of them.
+const (
+	forgetAfter = time.Hour
+	minSweep    = 1024
+)
+
+// cumulative is the last seen value of a cumulative series.
+type cumulative struct {
+	start uint64
+	value float64
+	h     metrics.Histogram
+	seen  time.Time
+}
+
+// New returns a new Receiver.
+func New(st Storage) *Receiver {
+	return &Receiver{
+		st:         st,
+		cumulative: make(map[string]cumulative),
+		since:      time.Now(),
+		sweepAt:    minSweep,
+	}
+}
+
+// Export implements the OTLP MetricsService.
+//
+// The last seen values of the cumulative series are only updated once the
+// metrics are saved, so that a retried export is not taken for no change.
+// The exports are handled one at a time for the same reason.
+func (r *Receiver) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	staged := make(map[string]cumulative)
+	mm, rejected, msg := r.convert(req, staged)
+
+	if err := limit.CheckBulk(ctx, len(mm)+int(rejected)); err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	if err := save(ctx, r.st, mm); err != nil {
+		if errors.Is(err, auth.ErrForbidden) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	r.commit(staged, time.Now())
+
+	res := &colmetricspb.ExportMetricsServiceResponse{}
+	if rejected > 0 {
+		res.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{
+			RejectedDataPoints: rejected,
+			ErrorMessage:       msg,
+		}
+	}
+
+	return res, nil
+}
+
+// convert converts the request into metrics, staging the latest values of the
+// cumulative series.
+func (r *Receiver) convert(req *colmetricspb.ExportMetricsServiceRequest, staged map[string]cumulative) ([]metrics.Named, int64, string) {
+	var (
+		mm       []metrics.Named
+		rejected int64
+		msgs     []string
+	)
+
+	reject := func(n int, msg string) {
+		rejected += int64(n)
+		if !slices.Contains(msgs, msg) {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	for _, rm := range req.GetResourceMetrics() {
+		var resLabels metrics.Labels
+		for _, kv := range rm.GetResource().GetAttributes() {
+			if kv.GetKey() == "service.name" {
+				resLabels = metrics.Labels{"service_name": attrValue(kv.GetValue())}
+			}
+		}
+
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				if m.GetName() == "" {
+					reject(countPoints(m), "metric has no name")
+					continue
+				}
+
+				switch data := m.GetData().(type) {
+				case *metricspb.Metric_Gauge:
+					for _, dp := range data.Gauge.GetDataPoints() {
+						if noValue(dp.GetFlags()) {
+							continue
+						}
+						mm = append(mm, metrics.Named{
+							Name:   m.GetName(),
+							Labels: labels(resLabels, dp.GetAttributes()),
+							Metric: metrics.Gauge(numberValue(dp)),
+						})
+					}
+				case *metricspb.Metric_Sum:
+					for _, dp := range data.Sum.GetDataPoints() {
+						if noValue(dp.GetFlags()) {
+							continue
+						}
+						nm, ok := r.sum(m.GetName(), resLabels, data.Sum, dp, staged)
+						if !ok {
+							reject(1, "non-monotonic delta sums are not supported")
+							continue
+						}
+						mm = append(mm, nm)
+					}
+				case *metricspb.Metric_Histogram:
+					for _, dp := range data.Histogram.GetDataPoints() {
+						if noValue(dp.GetFlags()) {
+							continue
+						}
+						nm, err := r.histogram(m.GetName(), resLabels, data.Histogram.GetAggregationTemporality(), dp, staged)
+						if err != nil {
+							reject(1, err.Error())
+							continue
+						}
+						mm = append(mm, nm)
+					}
+				default:
+					reject(countPoints(m), fmt.Sprintf("metric type of %s is not supported", m.GetName()))
+				}
+			}
+		}
+	}
+
+	return mm, rejected, strings.Join(msgs, "; ")
+}
+
+func (r *Receiver) sum(name string, resLabels metrics.Labels, sum *metricspb.Sum, dp *metricspb.NumberDataPoint, staged map[string]cumulative) (metrics.Named, bool) {
+	nm := metrics.Named{
+		Name:   name,
+		Labels: labels(resLabels, dp.GetAttributes()),
+	}
+
+	v := numberValue(dp)
+	delta := sum.GetAggregationTemporality() == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+
+	switch {
+	case !sum.GetIsMonotonic() && delta:
+		return nm, false
+	case !sum.GetIsMonotonic():
+		nm.Metric = metrics.Gauge(v)
+	case delta:
+		nm.Metric = metrics.Counter(math.Round(v))
+	default:
+		k := "sum\x00" + name + "\x00" + nm.Labels.String()
+
+		d := math.Round(v)
+		prev, ok := r.last(k, staged)
+		staged[k] = cumulative{start: dp.GetStartTimeUnixNano(), value: v}
+
+		switch {
+		case !ok && !r.isNew(dp.GetStartTimeUnixNano()):
+			d = 0
+		case ok && prev.start == dp.GetStartTimeUnixNano() && prev.value <= v:
+			d -= math.Round(prev.value)
+		}
+
+		nm.Metric = metrics.Counter(d)
+	}
+
+	return nm, true
+}
+
+func (r *Receiver) histogram(name string, resLabels metrics.Labels, temporality metricspb.AggregationTemporality, dp *metricspb.HistogramDataPoint, staged map[string]cumulative) (metrics.Named, error) {
+	nm := metrics.Named{
+		Name:   name,
+		Labels: labels(resLabels, dp.GetAttributes()),
+	}
+
+	h := metrics.Histogram{
+		Bounds: slices.Clone(dp.GetExplicitBounds()),
+		Counts: slices.Clone(dp.GetBucketCounts()),
+		Sum:    dp.GetSum(),
+		Count:  dp.GetCount(),
+	}
+
+	if len(h.Bounds) == 0 && len(h.Counts) == 0 {
+		h.Counts = []uint64{h.Count}
+	}
+
+	if err := h.Validate(); err != nil {
+		return nm, err
+	}
+
+	if temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		k := "histogram\x00" + name + "\x00" + nm.Labels.String()
+		cur := h
+		prev, ok := r.last(k, staged)
+		staged[k] = cumulative{start: dp.GetStartTimeUnixNano(), h: cur}
+
+		switch {
+		case !ok && !r.isNew(dp.GetStartTimeUnixNano()):
+			h = metrics.Histogram{
+				Bounds: cur.Bounds,
+				Counts: make([]uint64, len(cur.Counts)),
+			}
+		case ok && prev.start == dp.GetStartTimeUnixNano():
+			if d, ok := histogramDelta(prev.h, cur); ok {
+				h = d
+			}
+		}
+	}
+
+	nm.Metric = h
+
+	return nm, nil
+}
+
+// last returns the previous value of the cumulative series, if known, the
+// staged one taking precedence.
+func (r *Receiver) last(k string, staged map[string]cumulative) (cumulative, bool) {
+	if c, ok := staged[k]; ok {
+		return c, true
+	}
+
+	c, ok := r.cumulative[k]
+
+	return c, ok
+}
+
+// commit stores the staged values of the cumulative series.
+func (r *Receiver) commit(staged map[string]cumulative, now time.Time) {
+	for k, c := range staged {
+		if _, ok := r.cumulative[k]; !ok && len(r.cumulative) >= r.sweepAt {
+			r.sweep(now)
+		}
+
+		c.seen = now
+		r.cumulative[k] = c
+	}
+}
+
+// isNew reports whether the series with the start time started after the
+// receiver could have last known of it, so its whole value is new.
+func (r *Receiver) isNew(start uint64) bool {
+	return start > uint64(r.since.UnixNano())
+}
+
+// sweep forgets the cumulative series not seen for forgetAfter. Should any of
+// them come back, they are taken as started before the receiver knew of them.
+func (r *Receiver) sweep(now time.Time) {
+	for k, c := range r.cumulative {
+		if now.Sub(c.seen) > forgetAfter {
+			delete(r.cumulative, k)
+			r.since = now
+		}
+	}
+
+	r.sweepAt = max(minSweep, 2*len(r.cumulative))
+}
+
+// histogramDelta returns the difference between the cumulative histograms. It
+// returns false if the histograms have different bounds or the counts went
+// down, i.e. the histogram was reset.
+func histogramDelta(prev, cur metrics.Histogram) (metrics.Histogram, bool) {
+	if !slices.Equal(prev.Bounds, cur.Bounds) || cur.Count < prev.Count {
+		return cur, false
+	}
+
+	d := metrics.Histogram{
+		Bounds: cur.Bounds,
+		Counts: make([]uint64, len(cur.Counts)),
+		Sum:    cur.Sum - prev.Sum,
+		Count:  cur.Count - prev.Count,
+	}
+
+	for i := range cur.Counts {
+		if cur.Counts[i] < prev.Counts[i] {
+			return cur, false
+		}
+		d.Counts[i] = cur.Counts[i] - prev.Counts[i]
+	}
+
+	return d, true
+}
+
+func numberValue(dp *metricspb.NumberDataPoint) float64 {
+	if v, ok := dp.GetValue().(*metricspb.NumberDataPoint_AsInt); ok {
+		return float64(v.AsInt)
+	}
+
+	return dp.GetAsDouble()
+}
+
+func noValue(flags uint32) bool {
+	return flags&uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0
+}
+
+func countPoints(m *metricspb.Metric) int {
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		return len(data.Gauge.GetDataPoints())
+	case *metricspb.Metric_Sum:
+		return len(data.Sum.GetDataPoints())
+	case *metricspb.Metric_Histogram:
+		return len(data.Histogram.GetDataPoints())
+	case *metricspb.Metric_ExponentialHistogram:
+		return len(data.ExponentialHistogram.GetDataPoints())
+	case *metricspb.Metric_Summary:
+		return len(data.Summary.GetDataPoints())
+	default:
+		return 0
+	}
+}
+
+// labels returns the labels for the attributes.
+func labels(resLabels metrics.Labels, attrs []*commonpb.KeyValue) metrics.Labels {
+	l := resLabels.Clone()
+
+	for _, kv := range attrs {
+		if l == nil {
+			l = make(metrics.Labels)
+		}
+		l[labelName(kv.GetKey())] = attrValue(kv.GetValue())
+	}
+
+	return l
+}
+
+// labelName sanitises the attribute name to be a valid label name.
+func labelName(s string) string {
+	var sb strings.Builder
+
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "_"
+	}
+
+	return sb.String()
+}
+
+func attrValue(v *commonpb.AnyValue) string {
+	switch val := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return val.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(val.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(val.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(val.DoubleValue, 'g', -1, 64)
+	case *commonpb.AnyValue_BytesValue:
+		return string(val.BytesValue)
+	default:
+		return ""
+	}
+}
+
+// save writes the metrics into the storage, in bulk if the storage supports
+// that.
+func save(ctx context.Context, st Storage, mm []metrics.Named) error {
+	if len(mm) == 0 {
+		return nil
+	}
+
+	if bu, ok := st.(bulkUpdater); ok {
+		return bu.BulkUpdate(ctx, mm)
+	}
+
+	var errs []error
+	for _, m := range mm {
+		errs = append(errs, st.Update(ctx, m))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Storage is where the received metrics are written to.
+type Storage interface {
+	Update(context.Context, metrics.Named) error
+}
+
+type bulkUpdater interface {
+	BulkUpdate(context.Context, []metrics.Named) error
+}
@@ -0,0 +1,251 @@
+package otlp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/otlp"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+const (
+	delta      = metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	cumulative = metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+)
+
+func TestReceiver_Export(t *testing.T) {
+	ctx := context.Background()
+
+	st, err := storage.New(zap.NewNop().Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	rcv := otlp.New(st)
+	start := uint64(time.Now().UnixNano())
+
+	res, err := rcv.Export(ctx, request(start, 5, 10, []uint64{1, 2, 0}))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res.GetPartialSuccess().GetRejectedDataPoints())
+
+	res, err = rcv.Export(ctx, request(start, 7, 15, []uint64{2, 4, 1}))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res.GetPartialSuccess().GetRejectedDataPoints())
+
+	labels := metrics.Labels{"service_name": "shop", "http_method": "GET"}
+
+	m, err := st.Get(ctx, "gauge", "memory.used", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(1.5), m)
+
+	m, err = st.Get(ctx, "counter", "requests.delta", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Counter(12), m)
+
+	m, err = st.Get(ctx, "counter", "requests.total", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Counter(15), m)
+
+	m, err = st.Get(ctx, "gauge", "queue.size", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(15), m)
+
+	m, err = st.Get(ctx, "histogram", "duration", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Histogram{
+		Bounds: []float64{0.1, 1},
+		Counts: []uint64{2, 4, 1},
+		Sum:    3.5,
+		Count:  7,
+	}, m)
+}
+
+func TestReceiver_Export_Restart(t *testing.T) {
+	ctx := context.Background()
+
+	st, err := storage.New(zap.NewNop().Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	labels := metrics.Labels{"service_name": "shop", "http_method": "GET"}
+
+	rcv := otlp.New(st)
+
+	_, err = rcv.Export(ctx, request(1, 5, 10, []uint64{1, 2, 0}))
+	require.NoError(t, err)
+
+	m, err := st.Get(ctx, "counter", "requests.total", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Counter(0), m, "baseline only")
+
+	_, err = rcv.Export(ctx, request(1, 7, 15, []uint64{2, 4, 1}))
+	require.NoError(t, err)
+
+	rcv = otlp.New(st)
+
+	_, err = rcv.Export(ctx, request(1, 7, 15, []uint64{2, 4, 1}))
+	require.NoError(t, err)
+
+	m, err = st.Get(ctx, "counter", "requests.total", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Counter(5), m, "not counted again after restart")
+
+	_, err = rcv.Export(ctx, request(1, 9, 20, []uint64{3, 5, 1}))
+	require.NoError(t, err)
+
+	m, err = st.Get(ctx, "counter", "requests.total", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Counter(10), m)
+
+	m, err = st.Get(ctx, "histogram", "duration", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Histogram{
+		Bounds: []float64{0.1, 1},
+		Counts: []uint64{2, 3, 1},
+		Sum:    3,
+		Count:  6,
+	}, m)
+}
+
+func TestReceiver_Export_Retry(t *testing.T) {
+	ctx := context.Background()
+
+	st, err := storage.New(zap.NewNop().Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	fs := &failingStorage{Storage: st}
+	rcv := otlp.New(fs)
+	start := uint64(time.Now().UnixNano())
+
+	_, err = rcv.Export(ctx, request(start, 5, 10, []uint64{1, 2, 0}))
+	require.NoError(t, err)
+
+	fs.fail = true
+	_, err = rcv.Export(ctx, request(start, 7, 15, []uint64{2, 4, 1}))
+	require.Error(t, err)
+
+	fs.fail = false
+	_, err = rcv.Export(ctx, request(start, 7, 15, []uint64{2, 4, 1}))
+	require.NoError(t, err)
+
+	labels := metrics.Labels{"service_name": "shop", "http_method": "GET"}
+
+	m, err := st.Get(ctx, "counter", "requests.total", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Counter(15), m, "retried increment not lost")
+
+	m, err = st.Get(ctx, "histogram", "duration", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), m.(metrics.Histogram).Count)
+}
+
+type failingStorage struct {
+	storage.Storage
+	fail bool
+}
+
+func (s *failingStorage) Update(ctx context.Context, m metrics.Named) error {
+	if s.fail {
+		return assert.AnError
+	}
+
+	return s.Storage.Update(ctx, m)
+}
+
+func request(start uint64, n int64, total int64, counts []uint64) *colmetricspb.ExportMetricsServiceRequest {
+	attrs := []*commonpb.KeyValue{
+		{Key: "http.method", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "GET"}}},
+	}
+
+	var count uint64
+	for _, c := range counts {
+		count += c
+	}
+
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{
+				Attributes: []*commonpb.KeyValue{
+					{Key: "service.name", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "shop"}}},
+				},
+			},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Metrics: []*metricspb.Metric{
+					{
+						Name: "memory.used",
+						Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{
+							DataPoints: []*metricspb.NumberDataPoint{{
+								Attributes: attrs,
+								Value:      &metricspb.NumberDataPoint_AsDouble{AsDouble: 1.5},
+							}},
+						}},
+					},
+					{
+						Name: "requests.delta",
+						Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+							AggregationTemporality: delta,
+							IsMonotonic:            true,
+							DataPoints: []*metricspb.NumberDataPoint{{
+								Attributes: attrs,
+								Value:      &metricspb.NumberDataPoint_AsInt{AsInt: n},
+							}},
+						}},
+					},
+					{
+						Name: "requests.total",
+						Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+							AggregationTemporality: cumulative,
+							IsMonotonic:            true,
+							DataPoints: []*metricspb.NumberDataPoint{{
+								Attributes:        attrs,
+								StartTimeUnixNano: start,
+								Value:             &metricspb.NumberDataPoint_AsInt{AsInt: total},
+							}},
+						}},
+					},
+					{
+						Name: "queue.size",
+						Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+							AggregationTemporality: cumulative,
+							DataPoints: []*metricspb.NumberDataPoint{{
+								Attributes: attrs,
+								Value:      &metricspb.NumberDataPoint_AsInt{AsInt: total},
+							}},
+						}},
+					},
+					{
+						Name: "duration",
+						Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+							AggregationTemporality: cumulative,
+							DataPoints: []*metricspb.HistogramDataPoint{{
+								Attributes:        attrs,
+								StartTimeUnixNano: start,
+								ExplicitBounds:    []float64{0.1, 1},
+								BucketCounts:      counts,
+								Count:             count,
+								Sum:               proto64(float64(count) / 2),
+							}},
+						}},
+					},
+					{
+						Name: "summary",
+						Data: &metricspb.Metric_Summary{Summary: &metricspb.Summary{
+							DataPoints: []*metricspb.SummaryDataPoint{{}},
+						}},
+					},
+				},
+			}},
+		}},
+	}
+}
+
+func proto64(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,24 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiver_sweep(t *testing.T) {
+	r := New(nil)
+	now := time.Now().Add(2 * forgetAfter)
+
+	r.cumulative["old"] = cumulative{seen: time.Now()}
+	r.cumulative["fresh"] = cumulative{seen: now}
+
+	r.sweep(now)
+
+	assert.NotContains(t, r.cumulative, "old")
+	assert.Contains(t, r.cumulative, "fresh")
+	assert.Equal(t, now, r.since)
+	assert.False(t, r.isNew(uint64(now.Add(-time.Second).UnixNano())))
+	assert.True(t, r.isNew(uint64(now.Add(time.Second).UnixNano())))
+}
@@ -0,0 +1,153 @@
+// Package rate implements per-second rates of the counters.
+package rate
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// The windows of the exponentially smoothed rates.
+const (
+	window1  = time.Minute
+	window5  = 5 * time.Minute
+	window15 = 15 * time.Minute
+)
+
+// The counters not updated for forgetAfter are forgotten once there are at
+// least minSweep of them.
+const (
+	forgetAfter = time.Hour
+	minSweep    = 1024
+)
+
+// Rates are the per-second rates of a counter. Last is the rate over the
+// interval between the two latest updates, M1, M5 and M15 are the rates
+// exponentially smoothed over 1, 5 and 15 minutes.
+type Rates struct {
+	Last float64 `json:"last"`
+	M1   float64 `json:"m1"`
+	M5   float64 `json:"m5"`
+	M15  float64 `json:"m15"`
+}
+
+// Tracker keeps the previous value and time of update of each counter and
+// derives the rates from them. The rates are only known after the counter has
+// been updated at least twice. The counters not updated for an hour, such as
+// the ones expired from the storage, are forgotten. A nil Tracker knows no
+// rates.
+type Tracker struct {
+	mu      sync.RWMutex
+	series  map[string]*state
+	sweepAt int
+}
+
+type state struct {
+	total   metrics.Counter
+	prev    metrics.Counter
+	updated time.Time
+	rates   Rates
+	known   bool
+}
+
+// NewTracker returns a new Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		series:  make(map[string]*state),
+		sweepAt: minSweep,
+	}
+}
+
+// Observe records the update of the counter by delta at the given time.
+func (t *Tracker) Observe(name string, labels metrics.Labels, delta metrics.Counter, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := key(name, labels)
+
+	s, ok := t.series[k]
+	if !ok {
+		if len(t.series) >= t.sweepAt {
+			t.sweep(now)
+		}
+
+		t.series[k] = &state{total: delta, prev: delta, updated: now}
+		return
+	}
+
+	s.total += delta
+
+	dt := now.Sub(s.updated)
+	if dt <= 0 {
+		// several updates at once, e.g. in a batch
+		return
+	}
+
+	r := float64(s.total-s.prev) / dt.Seconds()
+	s.prev = s.total
+	s.updated = now
+
+	if !s.known {
+		s.rates = Rates{Last: r, M1: r, M5: r, M15: r}
+		s.known = true
+		return
+	}
+
+	s.rates.Last = r
+	s.rates.M1 = smooth(s.rates.M1, r, dt, window1)
+	s.rates.M5 = smooth(s.rates.M5, r, dt, window5)
+	s.rates.M15 = smooth(s.rates.M15, r, dt, window15)
+}
+
+// Get returns the rates of the counter. It returns false if the rates are not
+// known.
+func (t *Tracker) Get(name string, labels metrics.Labels) (Rates, bool) {
+	if t == nil {
+		return Rates{}, false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	s, ok := t.series[key(name, labels)]
+	if !ok || !s.known {
+		return Rates{}, false
+	}
+
+	return s.rates, true
+}
+
+// Forget drops what is known of the counter.
+func (t *Tracker) Forget(name string, labels metrics.Labels) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.series, key(name, labels))
+}
+
+// sweep forgets the counters not updated for forgetAfter.
+func (t *Tracker) sweep(now time.Time) {
+	for k, s := range t.series {
+		if now.Sub(s.updated) > forgetAfter {
+			delete(t.series, k)
+		}
+	}
+
+	t.sweepAt = max(minSweep, 2*len(t.series))
+}
+
+// smooth returns the average updated with the rate observed over dt.
+func smooth(avg, r float64, dt, window time.Duration) float64 {
+	alpha := 1 - math.Exp(-dt.Seconds()/window.Seconds())
+	return avg + alpha*(r-avg)
+}
+
+func key(name string, labels metrics.Labels) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	return name + "\x00" + labels.String()
+}
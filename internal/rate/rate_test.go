@@ -0,0 +1,146 @@
+package rate_test
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/rate"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+func TestTracker(t *testing.T) {
+	tr := rate.NewTracker()
+	labels := metrics.Labels{"host": "a"}
+	start := time.Now()
+
+	tr.Observe("requests", labels, 5, start)
+
+	_, ok := tr.Get("requests", labels)
+	assert.False(t, ok)
+
+	tr.Observe("requests", labels, 20, start.Add(10*time.Second))
+
+	rr, ok := tr.Get("requests", labels)
+	require.True(t, ok)
+	assert.Equal(t, rate.Rates{Last: 2, M1: 2, M5: 2, M15: 2}, rr)
+
+	// a batch: the updates at the same time are counted at the next interval
+	tr.Observe("requests", labels, 30, start.Add(20*time.Second))
+	tr.Observe("requests", labels, 30, start.Add(20*time.Second))
+
+	rr, ok = tr.Get("requests", labels)
+	require.True(t, ok)
+	assert.Equal(t, 3.0, rr.Last)
+
+	tr.Observe("requests", labels, 60, start.Add(30*time.Second))
+
+	rr, ok = tr.Get("requests", labels)
+	require.True(t, ok)
+	assert.Equal(t, 9.0, rr.Last)
+	assert.Greater(t, rr.M1, rr.M5)
+	assert.Greater(t, rr.M5, rr.M15)
+	assert.Greater(t, rr.M15, 2.0)
+	assert.Less(t, rr.M1, 9.0)
+
+	_, ok = tr.Get("requests", nil)
+	assert.False(t, ok)
+
+	tr.Forget("requests", labels)
+
+	_, ok = tr.Get("requests", labels)
+	assert.False(t, ok)
+
+	var nilTracker *rate.Tracker
+	_, ok = nilTracker.Get("requests", labels)
+	assert.False(t, ok)
+}
+
+func TestTracker_Smoothing(t *testing.T) {
+	tr := rate.NewTracker()
+	start := time.Now()
+
+	tr.Observe("requests", nil, 0, start)
+	tr.Observe("requests", nil, 10, start.Add(10*time.Second))
+
+	// steady 100/s for a minute
+	for i := 1; i <= 6; i++ {
+		tr.Observe("requests", nil, 1000, start.Add(time.Duration(10+10*i)*time.Second))
+	}
+
+	rr, ok := tr.Get("requests", nil)
+	require.True(t, ok)
+	assert.Equal(t, 100.0, rr.Last)
+	assert.InDelta(t, 1+99*(1-math.Exp(-1)), rr.M1, 1e-9)
+	assert.InDelta(t, 1+99*(1-math.Exp(-0.2)), rr.M5, 1e-9)
+}
+
+func TestTracker_Forget(t *testing.T) {
+	tr := rate.NewTracker()
+	start := time.Now()
+
+	tr.Observe("idle", nil, 1, start)
+	tr.Observe("idle", nil, 1, start.Add(time.Second))
+
+	_, ok := tr.Get("idle", nil)
+	require.True(t, ok)
+
+	later := start.Add(2 * time.Hour)
+	for i := range 1024 {
+		tr.Observe("busy", metrics.Labels{"n": strconv.Itoa(i)}, 1, later)
+	}
+
+	_, ok = tr.Get("idle", nil)
+	assert.False(t, ok, "idle counter forgotten")
+
+	tr.Observe("busy", metrics.Labels{"n": "0"}, 1, later.Add(time.Second))
+
+	_, ok = tr.Get("busy", metrics.Labels{"n": "0"})
+	assert.True(t, ok)
+}
+
+func TestWrap(t *testing.T) {
+	ctx := context.Background()
+
+	ms, err := storage.New(zap.NewNop().Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	tr := rate.NewTracker()
+	st := rate.Wrap(ms, tr)
+
+	_, ok := st.(interface {
+		BulkUpdate(context.Context, []metrics.Named) error
+	})
+	assert.False(t, ok)
+
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "requests", Metric: metrics.Counter(1)}))
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "load", Metric: metrics.Gauge(1)}))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "requests", Metric: metrics.Counter(1)}))
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "load", Metric: metrics.Gauge(2)}))
+
+	rr, ok := tr.Get("requests", nil)
+	require.True(t, ok)
+	assert.Greater(t, rr.Last, 0.0)
+
+	_, ok = tr.Get("load", nil)
+	assert.False(t, ok)
+
+	m, err := st.Get(ctx, "counter", "requests", nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Counter(2), m)
+
+	require.NoError(t, st.Reset(ctx, "counter", "requests", nil))
+
+	_, ok = tr.Get("requests", nil)
+	assert.False(t, ok)
+
+	st.Close()
+}
@@ -0,0 +1,137 @@
+package rate
+
+import (
+	"context"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+// Wrap returns the storage that feeds every accepted counter update to the
+// tracker. Bulk updates and pings are supported if the storage supports them.
+func Wrap(st storage.Storage, t *Tracker) storage.Storage {
+	ts := &tracking{Storage: st, t: t}
+
+	bu, isBulk := st.(bulkUpdater)
+	p, isPing := st.(pingable)
+
+	switch {
+	case isBulk && isPing:
+		return struct {
+			*tracking
+			bulkTracking
+			pinger
+		}{ts, bulkTracking{bu: bu, t: t}, pinger{p}}
+	case isBulk:
+		return struct {
+			*tracking
+			bulkTracking
+		}{ts, bulkTracking{bu: bu, t: t}}
+	case isPing:
+		return struct {
+			*tracking
+			pinger
+		}{ts, pinger{p}}
+	default:
+		return ts
+	}
+}
+
+type tracking struct {
+	storage.Storage
+	t *Tracker
+}
+
+// Update implements storage.Storage.
+func (s *tracking) Update(ctx context.Context, m metrics.Named) error {
+	if err := s.Storage.Update(ctx, m); err != nil {
+		return err
+	}
+
+	s.t.observe(time.Now(), m)
+
+	return nil
+}
+
+// Delete implements storage.Storage.
+func (s *tracking) Delete(ctx context.Context, t, name string, labels metrics.Labels) error {
+	if err := s.Storage.Delete(ctx, t, name, labels); err != nil {
+		return err
+	}
+
+	if t == metrics.Counter(0).Type() {
+		s.t.Forget(name, labels)
+	}
+
+	return nil
+}
+
+// Reset implements storage.Storage.
+func (s *tracking) Reset(ctx context.Context, t, name string, labels metrics.Labels) error {
+	if err := s.Storage.Reset(ctx, t, name, labels); err != nil {
+		return err
+	}
+
+	if t == metrics.Counter(0).Type() {
+		s.t.Forget(name, labels)
+	}
+
+	return nil
+}
+
+// Range queries the history of the underlying storage.
+func (s *tracking) Range(ctx context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error) {
+	rq, ok := s.Storage.(rangeQuerier)
+	if !ok {
+		return nil, storage.ErrHistoryDisabled
+	}
+
+	return rq.Range(ctx, t, name, labels, from, to)
+}
+
+type bulkTracking struct {
+	bu bulkUpdater
+	t  *Tracker
+}
+
+// BulkUpdate updates the metrics in the underlying storage.
+func (s bulkTracking) BulkUpdate(ctx context.Context, mm []metrics.Named) error {
+	if err := s.bu.BulkUpdate(ctx, mm); err != nil {
+		return err
+	}
+
+	s.t.observe(time.Now(), mm...)
+
+	return nil
+}
+
+type pinger struct {
+	p pingable
+}
+
+// Ping pings the underlying storage.
+func (p pinger) Ping(ctx context.Context) error {
+	return p.p.Ping(ctx)
+}
+
+// observe feeds the counters among the metrics to the tracker.
+func (t *Tracker) observe(now time.Time, mm ...metrics.Named) {
+	for _, m := range mm {
+		if c, ok := m.Metric.(metrics.Counter); ok {
+			t.Observe(m.Name, m.Labels, c, now)
+		}
+	}
+}
+
+type bulkUpdater interface {
+	BulkUpdate(context.Context, []metrics.Named) error
+}
+
+type pingable interface {
+	Ping(context.Context) error
+}
+
+type rangeQuerier interface {
+	Range(ctx context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error)
+}
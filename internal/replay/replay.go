@@ -0,0 +1,128 @@
+// Package replay protects the server from the signed requests being replayed.
+package replay
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/hash"
+)
+
+// Defaults for the zero values of the window and the cache size.
+const (
+	DefaultWindow    = 5 * time.Minute
+	DefaultCacheSize = 100000
+)
+
+var (
+	// ErrStale is returned for a stamp too far from the current time.
+	ErrStale = errors.New("signature is stale")
+	// ErrReplayed is returned for a nonce that has already been seen.
+	ErrReplayed = errors.New("signature has already been used")
+	// ErrNotStamped is returned when a stamp is required, but there is none.
+	ErrNotStamped = errors.New("signature is not stamped")
+)
+
+// Guard remembers the nonces of the stamps it has seen within the window
+// around the current time, and rejects them the second time, as well as the
+// stamps outside the window.
+//
+// At most the cache size nonces are remembered. When there is no room, the
+// oldest nonce is forgotten and the stamps not newer than it are rejected
+// from then on, so that no nonce can be used twice anyway.
+type Guard struct {
+	window time.Duration
+	size   int
+	strict bool
+
+	mu    sync.Mutex
+	seen  map[string]struct{}
+	queue []hash.Stamp
+	floor time.Time
+}
+
+// New returns a new guard. If strict is set, the unstamped signatures are
+// rejected, otherwise they are accepted for compatibility with the older
+// clients.
+func New(window time.Duration, size int, strict bool) *Guard {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+
+	return &Guard{
+		window: window,
+		size:   size,
+		strict: strict,
+		seen:   make(map[string]struct{}),
+	}
+}
+
+// Check checks the stamp and remembers its nonce. The nil guard accepts
+// everything.
+func (g *Guard) Check(s hash.Stamp) error {
+	if g == nil {
+		return nil
+	}
+
+	if s.Nonce == "" {
+		return ErrNotStamped
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+
+	if s.Time.Before(now.Add(-g.window)) || s.Time.After(now.Add(g.window)) || !s.Time.After(g.floor) {
+		return ErrStale
+	}
+
+	if _, ok := g.seen[s.Nonce]; ok {
+		return ErrReplayed
+	}
+
+	g.expire(now)
+
+	for len(g.queue) >= g.size {
+		g.pop()
+	}
+
+	g.seen[s.Nonce] = struct{}{}
+	g.queue = append(g.queue, s)
+
+	return nil
+}
+
+// Unstamped returns the error for a signature without a stamp, if the guard
+// requires the stamps.
+func (g *Guard) Unstamped() error {
+	if g == nil || !g.strict {
+		return nil
+	}
+
+	return ErrNotStamped
+}
+
+// expire forgets the oldest nonces for as long as they are out of the window
+// anyway.
+func (g *Guard) expire(now time.Time) {
+	for len(g.queue) > 0 && g.queue[0].Time.Before(now.Add(-g.window)) {
+		g.pop()
+	}
+}
+
+func (g *Guard) pop() {
+	s := g.queue[0]
+	g.queue = g.queue[1:]
+
+	delete(g.seen, s.Nonce)
+
+	if s.Time.After(g.floor) {
+		g.floor = s.Time
+	}
+}
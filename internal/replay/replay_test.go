@@ -0,0 +1,59 @@
+package replay_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nekr0z/muhame/internal/hash"
+	"github.com/nekr0z/muhame/internal/replay"
+)
+
+func TestGuard(t *testing.T) {
+	t.Parallel()
+
+	g := replay.New(time.Minute, 0, false)
+	now := time.Now()
+
+	s := hash.Stamp{Time: now, Nonce: "a"}
+	assert.NoError(t, g.Check(s))
+	assert.ErrorIs(t, g.Check(s), replay.ErrReplayed)
+
+	assert.NoError(t, g.Check(hash.Stamp{Time: now, Nonce: "b"}))
+	assert.NoError(t, g.Check(hash.Stamp{Time: now.Add(-30 * time.Second), Nonce: "c"}))
+
+	assert.ErrorIs(t, g.Check(hash.Stamp{Time: now.Add(-2 * time.Minute), Nonce: "d"}), replay.ErrStale)
+	assert.ErrorIs(t, g.Check(hash.Stamp{Time: now.Add(2 * time.Minute), Nonce: "e"}), replay.ErrStale)
+	assert.ErrorIs(t, g.Check(hash.Stamp{Time: now}), replay.ErrNotStamped)
+
+	assert.NoError(t, g.Unstamped())
+	assert.ErrorIs(t, replay.New(0, 0, true).Unstamped(), replay.ErrNotStamped)
+}
+
+func TestGuard_Bounded(t *testing.T) {
+	t.Parallel()
+
+	g := replay.New(time.Minute, 2, false)
+	now := time.Now()
+
+	assert.NoError(t, g.Check(hash.Stamp{Time: now.Add(-3 * time.Second), Nonce: "a"}))
+	assert.NoError(t, g.Check(hash.Stamp{Time: now.Add(-2 * time.Second), Nonce: "b"}))
+	assert.NoError(t, g.Check(hash.Stamp{Time: now.Add(-time.Second), Nonce: "c"}))
+
+	// "a" is forgotten, but can not be used again
+	assert.ErrorIs(t, g.Check(hash.Stamp{Time: now.Add(-3 * time.Second), Nonce: "a"}), replay.ErrStale)
+	assert.ErrorIs(t, g.Check(hash.Stamp{Time: now.Add(-2 * time.Second), Nonce: "b"}), replay.ErrReplayed)
+	assert.NoError(t, g.Check(hash.Stamp{Time: now, Nonce: "d"}))
+}
+
+func TestGuard_Nil(t *testing.T) {
+	t.Parallel()
+
+	var g *replay.Guard
+
+	s := hash.Stamp{Time: time.Now(), Nonce: "a"}
+	assert.NoError(t, g.Check(s))
+	assert.NoError(t, g.Check(s))
+	assert.NoError(t, g.Unstamped())
+}
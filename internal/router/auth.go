@@ -0,0 +1,44 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/nekr0z/muhame/internal/auth"
+)
+
+// authorize only lets through the requests with an API key that has the
+// scope and, if the route names the metric, allows it. The key is added to
+// the request context for the storage wrapped with auth.Wrap to check the
+// metrics in the request body. If there are no keys, every request is let
+// through.
+func authorize(keys auth.Keys, scope auth.Scope) middleware {
+	return func(next http.Handler) http.Handler {
+		if len(keys) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k, ok := keys.Authenticate(r.Header.Get(auth.Header))
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if !k.Has(scope) {
+				http.Error(w, fmt.Sprintf("Forbidden: key %s lacks %s scope", k.Name, scope), http.StatusForbidden)
+				return
+			}
+
+			if name := chi.URLParam(r, "name"); name != "" && !k.Allows(scope, name) {
+				http.Error(w, fmt.Sprintf("Forbidden: key %s may not access %s", k.Name, name), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithKey(r.Context(), k)))
+		})
+	}
+}
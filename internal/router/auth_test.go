@@ -0,0 +1,95 @@
+package router_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/router"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+func TestAuthorize(t *testing.T) {
+	t.Parallel()
+
+	keys := auth.Keys{
+		{Name: "agent", Token: "agent-token", Scopes: []auth.Scope{auth.ScopeWrite}, Prefixes: []string{"host_"}},
+		{Name: "dashboard", Token: "dashboard-token", Scopes: []auth.Scope{auth.ScopeRead}},
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		uri    string
+		body   string
+		want   int
+	}{
+		{name: "no key", method: http.MethodGet, uri: "/value/gauge/cpu", want: http.StatusUnauthorized},
+		{name: "unknown key", token: "other", method: http.MethodGet, uri: "/value/gauge/cpu", want: http.StatusUnauthorized},
+		{name: "read", token: "dashboard-token", method: http.MethodGet, uri: "/value/gauge/cpu", want: http.StatusOK},
+		{name: "read without scope", token: "agent-token", method: http.MethodGet, uri: "/value/gauge/cpu", want: http.StatusForbidden},
+		{name: "write without scope", token: "dashboard-token", method: http.MethodPost, uri: "/update/gauge/cpu/2", want: http.StatusForbidden},
+		{name: "write", token: "agent-token", method: http.MethodPost, uri: "/update/gauge/host_cpu/2", want: http.StatusOK},
+		{name: "write outside prefix", token: "agent-token", method: http.MethodPost, uri: "/update/gauge/cpu/2", want: http.StatusForbidden},
+		{
+			name:   "write JSON",
+			token:  "agent-token",
+			method: http.MethodPost,
+			uri:    "/update/",
+			body:   `{"id":"host_load","type":"gauge","value":1}`,
+			want:   http.StatusOK,
+		},
+		{
+			name:   "write JSON outside prefix",
+			token:  "agent-token",
+			method: http.MethodPost,
+			uri:    "/update/",
+			body:   `{"id":"load","type":"gauge","value":1}`,
+			want:   http.StatusForbidden,
+		},
+		{name: "watch outside prefix", token: "agent-token", method: http.MethodGet, uri: "/watch", want: http.StatusForbidden},
+		{name: "admin", token: "dashboard-token", method: http.MethodDelete, uri: "/value/gauge/cpu", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			log := zap.NewNop()
+			ms, err := storage.New(log.Sugar(), storage.Config{InMemory: true})
+			require.NoError(t, err)
+			require.NoError(t, ms.Update(context.Background(), metrics.Named{Name: "cpu", Metric: metrics.Gauge(1)}))
+
+			r := router.New(log, auth.Wrap(ms), "", nil, "", router.WithKeys(keys))
+
+			req := httptest.NewRequest(tt.method, tt.uri, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set(auth.Header, auth.Bearer(tt.token))
+			}
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			assert.Equal(t, tt.want, res.Code, res.Body.String())
+
+			if tt.want == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", res.Header().Get("WWW-Authenticate"))
+			}
+
+			if tt.want != http.StatusOK || tt.method != http.MethodPost {
+				m, err := ms.Get(context.Background(), "gauge", "cpu", nil)
+				require.NoError(t, err)
+				assert.Equal(t, metrics.Gauge(1), m)
+			}
+		})
+	}
+}
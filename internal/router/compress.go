@@ -1,23 +1,40 @@
 package router
 
 import (
+	"bytes"
 	"compress/gzip"
+	"io"
 	"net/http"
 	"slices"
+
+	"github.com/nekr0z/muhame/internal/limit"
 )
 
-func acceptGzip(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if slices.Contains(r.Header.Values("Content-Encoding"), "gzip") {
-			body, err := gzip.NewReader(r.Body)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
+// acceptGzip decompresses the gzipped requests. If maxSize is set, the
+// requests that decompress to more than maxSize bytes are rejected with 413.
+func acceptGzip(maxSize int64) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(r.Header.Values("Content-Encoding"), "gzip") {
+				body, err := gzip.NewReader(r.Body)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				r.Body = body
+
+				if maxSize > 0 {
+					b, err := limit.Read(body, maxSize)
+					if err != nil {
+						tooLarge(w, err)
+						return
+					}
+					r.Body = io.NopCloser(bytes.NewReader(b))
+				}
 			}
-			r.Body = body
-		}
-		next.ServeHTTP(w, r)
-	})
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 func respondGzip(next http.Handler) http.Handler {
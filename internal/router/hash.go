@@ -2,13 +2,15 @@ package router
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/nekr0z/muhame/internal/hash"
+	"github.com/nekr0z/muhame/internal/replay"
 )
 
-func checkSig(key string) middleware {
+func checkSig(keys hash.Keys, g *replay.Guard) middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodPost {
@@ -19,7 +21,13 @@ func checkSig(key string) middleware {
 			sig := r.Header.Get(hash.Header)
 			if sig == "" {
 				// Yes, this is absolutely stupid, but this is the behavior the
-				// acceptance tests expect.
+				// acceptance tests expect. Unless the guard requires the
+				// stamps, that is, since an unsigned request has none.
+				if err := g.Unstamped(); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -38,9 +46,8 @@ func checkSig(key string) middleware {
 				return
 			}
 
-			calculated := hash.Signature(bb, key)
-			if calculated != sig {
-				http.Error(w, "signature does not match", http.StatusBadRequest)
+			if err := verify(r, bb, keys, g); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
 
@@ -50,9 +57,47 @@ func checkSig(key string) middleware {
 	}
 }
 
-func addSig(key string) middleware {
+// verify checks the signature of the message made with the key of the ID in
+// the request, or with the legacy key if there is no ID. If the request has a
+// stamp, the signature is made with it, and the guard checks the stamp has not
+// been seen before.
+func verify(r *http.Request, msg []byte, keys hash.Keys, g *replay.Guard) error {
+	sig := r.Header.Get(hash.Header)
+	id := r.Header.Get(hash.KeyIDHeader)
+
+	ts := r.Header.Get(hash.TimestampHeader)
+	if ts == "" {
+		if err := g.Unstamped(); err != nil {
+			return err
+		}
+
+		return keys.Verify(msg, sig, id, hash.Stamp{})
+	}
+
+	stamp, err := hash.ParseStamp(ts, r.Header.Get(hash.NonceHeader))
+	if err != nil {
+		return fmt.Errorf("bad signature timestamp: %w", err)
+	}
+
+	if err := keys.Verify(msg, sig, id, stamp); err != nil {
+		return err
+	}
+
+	return g.Check(stamp)
+}
+
+// addSig signs the response with the key the request was signed with, or with
+// the legacy key if the request has no key ID. The response is not signed if
+// there is no such key.
+func addSig(keys hash.Keys) middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			signer, ok := keys.Signer(r.Header.Get(hash.KeyIDHeader))
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			var wb bytes.Buffer
 			rw := &responseWriter{
 				w:      &wb,
@@ -73,7 +118,10 @@ func addSig(key string) middleware {
 				}
 			}
 
-			w.Header().Set(hash.Header, hash.Signature(bb, key))
+			w.Header().Set(hash.Header, signer.Sign(bb, hash.Stamp{}))
+			if signer.ID != "" {
+				w.Header().Set(hash.KeyIDHeader, signer.ID)
+			}
 
 			if rw.code != 0 {
 				w.WriteHeader(rw.code)
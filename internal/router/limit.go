@@ -0,0 +1,64 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/limit"
+)
+
+// limits responds with 429 to the clients over their rate, and with 413 to
+// the requests with the body over the size limit. The clients are told apart
+// by their API keys, or by their addresses if they have none. The address is
+// that of the peer unless the peer is one of the trusted proxies of the
+// resolver, so that the clients can not dodge the limit by making up the
+// X-Real-IP or X-Forwarded-For headers. The limits of
+// metrics per bulk and of the decompressed size are passed on in the context
+// for the handlers that decompress the payloads themselves to check.
+func limits(l *limit.Limiter, keys auth.Keys, res clientip.Resolver) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if l.Rate > 0 && !l.Allow(limit.Client(keys, r.Header.Get(auth.Header), res.FromRequest(r))) {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, fmt.Sprintf("Too many requests: %s", limit.ErrRateLimited), http.StatusTooManyRequests)
+				return
+			}
+
+			if l.MaxBody > 0 {
+				b, err := limit.Read(r.Body, l.MaxBody)
+				if err != nil {
+					tooLarge(w, err)
+					return
+				}
+
+				r.Body = io.NopCloser(bytes.NewReader(b))
+			}
+
+			if l.MaxBulk > 0 {
+				r = r.WithContext(limit.WithMaxBulk(r.Context(), l.MaxBulk))
+			}
+
+			if l.MaxDecompressed > 0 {
+				r = r.WithContext(limit.WithMaxDecompressed(r.Context(), l.MaxDecompressed))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// tooLarge responds with 413 if the error is due to a size limit, or with 400
+// otherwise.
+func tooLarge(w http.ResponseWriter, err error) {
+	if errors.Is(err, limit.ErrTooLarge) {
+		http.Error(w, fmt.Sprintf("Request entity too large: %s", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	http.Error(w, fmt.Sprintf("Bad request: %s", err), http.StatusBadRequest)
+}
@@ -0,0 +1,179 @@
+package router_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/limit"
+	"github.com/nekr0z/muhame/internal/router"
+)
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+
+	do := func(r http.Handler, ip string, body []byte, gzipped bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		if gzipped {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, req)
+
+		return res
+	}
+
+	bulk := func(n int) []byte {
+		mm := make([]string, n)
+		for i := range mm {
+			mm[i] = `{"id":"test","type":"counter","delta":1}`
+		}
+
+		return []byte("[" + strings.Join(mm, ",") + "]")
+	}
+
+	t.Run("rate", func(t *testing.T) {
+		t.Parallel()
+
+		r := router.New(zap.NewNop(), &bulkStorage{}, "", nil, "", router.WithLimits(limit.New(limit.Config{Rate: 0.001, Burst: 2})))
+
+		assert.Equal(t, http.StatusOK, do(r, trustedIP, bulk(1), false).Code)
+		assert.Equal(t, http.StatusOK, do(r, trustedIP, bulk(1), false).Code)
+
+		res := do(r, trustedIP, bulk(1), false)
+		assert.Equal(t, http.StatusTooManyRequests, res.Code)
+		assert.NotEmpty(t, res.Header().Get("Retry-After"))
+
+		assert.Equal(t, http.StatusOK, do(r, untrustedIP, bulk(1), false).Code, "other client")
+	})
+
+	t.Run("spoofed real IP", func(t *testing.T) {
+		t.Parallel()
+
+		r := router.New(zap.NewNop(), &bulkStorage{}, "", nil, "", router.WithLimits(limit.New(limit.Config{Rate: 0.001, Burst: 1})))
+
+		spoofed := func(realIP string) int {
+			req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(bulk(1)))
+			req.RemoteAddr = untrustedIP + ":1234"
+			req.Header.Set("X-Real-IP", realIP)
+
+			res := httptest.NewRecorder()
+			r.ServeHTTP(res, req)
+
+			return res.Code
+		}
+
+		assert.Equal(t, http.StatusOK, spoofed("10.0.0.1"))
+		assert.Equal(t, http.StatusTooManyRequests, spoofed("10.0.0.2"))
+	})
+
+	t.Run("proxied", func(t *testing.T) {
+		t.Parallel()
+
+		proxies, err := clientip.ParseSubnets(untrustedIP)
+		require.NoError(t, err)
+
+		r := router.New(zap.NewNop(), &bulkStorage{}, "", nil, "",
+			router.WithLimits(limit.New(limit.Config{Rate: 0.001, Burst: 1})),
+			router.WithTrustedProxies(proxies),
+		)
+
+		proxied := func(forwardedFor string) int {
+			req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(bulk(1)))
+			req.RemoteAddr = untrustedIP + ":1234"
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+
+			res := httptest.NewRecorder()
+			r.ServeHTTP(res, req)
+
+			return res.Code
+		}
+
+		assert.Equal(t, http.StatusOK, proxied("10.0.0.1"))
+		assert.Equal(t, http.StatusTooManyRequests, proxied("10.0.0.1"))
+		assert.Equal(t, http.StatusOK, proxied("10.0.0.2"), "other client")
+	})
+
+	t.Run("body", func(t *testing.T) {
+		t.Parallel()
+
+		r := router.New(zap.NewNop(), &bulkStorage{}, "", nil, "", router.WithLimits(limit.New(limit.Config{MaxBody: 100})))
+
+		assert.Equal(t, http.StatusOK, do(r, trustedIP, bulk(2), false).Code)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, do(r, trustedIP, bulk(3), false).Code)
+	})
+
+	t.Run("decompressed", func(t *testing.T) {
+		t.Parallel()
+
+		r := router.New(zap.NewNop(), &bulkStorage{}, "", nil, "", router.WithLimits(limit.New(limit.Config{
+			MaxBody:         4096,
+			MaxDecompressed: 1024,
+		})))
+
+		var small bytes.Buffer
+		zw := gzip.NewWriter(&small)
+		_, err := zw.Write(bulk(2))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+
+		assert.Equal(t, http.StatusOK, do(r, trustedIP, small.Bytes(), true).Code)
+
+		var bomb bytes.Buffer
+		zw = gzip.NewWriter(&bomb)
+		_, err = zw.Write(make([]byte, 1<<20))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		require.Less(t, bomb.Len(), 4096)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, do(r, trustedIP, bomb.Bytes(), true).Code)
+	})
+
+	t.Run("remote write", func(t *testing.T) {
+		t.Parallel()
+
+		r := router.New(zap.NewNop(), &bulkStorage{}, "", nil, "", router.WithLimits(limit.New(limit.Config{
+			MaxBody:         4096,
+			MaxDecompressed: 1024,
+		})))
+
+		write := func(b []byte) int {
+			b = snappy.Encode(nil, b)
+			require.Less(t, len(b), 4096)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(b))
+			req.Header.Set("Content-Encoding", "snappy")
+
+			res := httptest.NewRecorder()
+			r.ServeHTTP(res, req)
+
+			return res.Code
+		}
+
+		assert.Equal(t, http.StatusNoContent, write(nil))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, write(make([]byte, 64<<10)))
+	})
+
+	t.Run("bulk", func(t *testing.T) {
+		t.Parallel()
+
+		st := &bulkStorage{}
+		r := router.New(zap.NewNop(), st, "", nil, "", router.WithLimits(limit.New(limit.Config{MaxBulk: 2})))
+
+		assert.Equal(t, http.StatusOK, do(r, trustedIP, bulk(2), false).Code)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, do(r, trustedIP, bulk(3), false).Code)
+		assert.Len(t, st.got, 2)
+	})
+}
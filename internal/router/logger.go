@@ -62,3 +62,9 @@ type responseData struct {
 	status int
 	size   int
 }
+
+// Unwrap returns the underlying http.ResponseWriter, so that
+// http.ResponseController can reach it.
+func (r *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,100 @@
+package router
+
+import (
+	"time"
+
+	"github.com/nekr0z/muhame/internal/alert"
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/limit"
+	"github.com/nekr0z/muhame/internal/rate"
+	"github.com/nekr0z/muhame/internal/replay"
+	"github.com/nekr0z/muhame/internal/watch"
+)
+
+// Option configures the router.
+type Option func(*options)
+
+type options struct {
+	alerts     *alert.Engine
+	staleAfter time.Duration
+	rates      *rate.Tracker
+	hub        *watch.Hub
+	keys       auth.Keys
+	replay     *replay.Guard
+	signKeys   map[string]string
+	proxies    clientip.Subnets
+	limits     *limit.Limiter
+}
+
+// WithAlerts makes the router serve the alerts of the engine.
+func WithAlerts(e *alert.Engine) Option {
+	return func(o *options) {
+		o.alerts = e
+	}
+}
+
+// WithStaleAfter makes the router mark the series that have not been updated
+// for longer than d as stale.
+func WithStaleAfter(d time.Duration) Option {
+	return func(o *options) {
+		o.staleAfter = d
+	}
+}
+
+// WithRates makes the router serve the rates of the counters known to the
+// tracker.
+func WithRates(t *rate.Tracker) Option {
+	return func(o *options) {
+		o.rates = t
+	}
+}
+
+// WithHub makes the router stream the events of the hub to the watchers.
+func WithHub(h *watch.Hub) Option {
+	return func(o *options) {
+		o.hub = h
+	}
+}
+
+// WithKeys makes the router require one of the API keys on every request but
+// /ping. The storage should be wrapped with auth.Wrap for the keys limited to
+// metric name prefixes to work.
+func WithKeys(kk auth.Keys) Option {
+	return func(o *options) {
+		o.keys = kk
+	}
+}
+
+// WithReplayGuard makes the router reject the signed requests the guard has
+// seen before.
+func WithReplayGuard(g *replay.Guard) Option {
+	return func(o *options) {
+		o.replay = g
+	}
+}
+
+// WithSignKeys makes the router accept the HMAC signatures made with the keys
+// by their IDs, and sign the responses with the key the request was signed
+// with.
+func WithSignKeys(keys map[string]string) Option {
+	return func(o *options) {
+		o.signKeys = keys
+	}
+}
+
+// WithTrustedProxies makes the router only take the client address from the
+// X-Forwarded-For and X-Real-IP headers of the requests that come from the
+// proxies, see clientip.Resolver.
+func WithTrustedProxies(proxies clientip.Subnets) Option {
+	return func(o *options) {
+		o.proxies = proxies
+	}
+}
+
+// WithLimits makes the router enforce the limits on the requests.
+func WithLimits(l *limit.Limiter) Option {
+	return func(o *options) {
+		o.limits = l
+	}
+}
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/hash"
+	"github.com/nekr0z/muhame/internal/replay"
+)
+
+// privileged only lets through the signed requests from the trusted subnets,
+// and nothing at all unless both a signing key and the subnets are set. The
+// signature of a POST request is verified against its body by checkSig, the
+// signature of any other request is calculated over its URI (path and query).
+func privileged(keys hash.Keys, subnet string, res clientip.Resolver, g *replay.Guard) middleware {
+	subnets, err := clientip.ParseSubnets(subnet)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !keys.Enabled() || subnet == "" {
+				http.Error(w, "Forbidden: requires signing key and trusted subnet to be set", http.StatusForbidden)
+				return
+			}
+
+			if err != nil || !subnets.Contains(res.FromRequest(r)) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			sig := r.Header.Get(hash.Header)
+			if sig == "" {
+				http.Error(w, "Forbidden: request is not signed", http.StatusForbidden)
+				return
+			}
+
+			if r.Method != http.MethodPost {
+				if err := verify(r, []byte(r.URL.RequestURI()), keys, g); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/hash"
+	"github.com/nekr0z/muhame/internal/router"
+)
+
+func TestPrivileged(t *testing.T) {
+	t.Parallel()
+
+	const key = "secret"
+
+	st := &mockStorage{t, name, metric}
+	log := zap.NewNop()
+
+	deleteURI := "/value/counter/" + name + "?host=a"
+	bulk := `[{"id":"test","type":"counter"},{"id":"other","type":"gauge"}]`
+
+	tests := []struct {
+		name   string
+		key    string
+		subnet string
+		method string
+		uri    string
+		body   string
+		ip     string
+		sig    string
+		want   int
+	}{
+		{
+			name:   "delete",
+			key:    key,
+			subnet: trustedSubnet,
+			method: http.MethodDelete,
+			uri:    deleteURI,
+			ip:     trustedIP,
+			sig:    hash.Signature([]byte(deleteURI), key),
+			want:   http.StatusOK,
+		},
+		{
+			name:   "delete not found",
+			key:    key,
+			subnet: trustedSubnet,
+			method: http.MethodDelete,
+			uri:    "/value/counter/other",
+			ip:     trustedIP,
+			sig:    hash.Signature([]byte("/value/counter/other"), key),
+			want:   http.StatusNotFound,
+		},
+		{
+			name:   "bad signature",
+			key:    key,
+			subnet: trustedSubnet,
+			method: http.MethodDelete,
+			uri:    deleteURI,
+			ip:     trustedIP,
+			sig:    hash.Signature([]byte("/value/counter/other"), key),
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "unsigned",
+			key:    key,
+			subnet: trustedSubnet,
+			method: http.MethodDelete,
+			uri:    deleteURI,
+			ip:     trustedIP,
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "untrusted",
+			key:    key,
+			subnet: trustedSubnet,
+			method: http.MethodDelete,
+			uri:    deleteURI,
+			ip:     untrustedIP,
+			sig:    hash.Signature([]byte(deleteURI), key),
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "no subnet",
+			key:    key,
+			method: http.MethodDelete,
+			uri:    deleteURI,
+			ip:     trustedIP,
+			sig:    hash.Signature([]byte(deleteURI), key),
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "no key",
+			subnet: trustedSubnet,
+			method: http.MethodDelete,
+			uri:    deleteURI,
+			ip:     trustedIP,
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "bulk delete",
+			key:    key,
+			subnet: trustedSubnet,
+			method: http.MethodPost,
+			uri:    "/delete/",
+			body:   bulk,
+			ip:     trustedIP,
+			sig:    hash.Signature([]byte(bulk), key),
+			want:   http.StatusOK,
+		},
+		{
+			name:   "bulk reset bad signature",
+			key:    key,
+			subnet: trustedSubnet,
+			method: http.MethodPost,
+			uri:    "/reset/",
+			body:   bulk,
+			ip:     trustedIP,
+			sig:    hash.Signature([]byte("[]"), key),
+			want:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := router.New(log, st, tt.key, nil, tt.subnet)
+
+			req := httptest.NewRequest(tt.method, tt.uri, strings.NewReader(tt.body))
+			req.RemoteAddr = tt.ip + ":1234"
+			if tt.sig != "" {
+				req.Header.Set(hash.Header, tt.sig)
+			}
+
+			res := httptest.NewRecorder()
+			r.ServeHTTP(res, req)
+
+			assert.Equal(t, tt.want, res.Code, res.Body.String())
+		})
+	}
+}
+
+func TestBulkReset(t *testing.T) {
+	t.Parallel()
+
+	const key = "secret"
+
+	st := &mockStorage{t, name, metric}
+	r := router.New(zap.NewNop(), st, key, nil, trustedSubnet)
+
+	body := `[{"id":"test","type":"counter"},{"id":"other","type":"gauge"}]`
+
+	req := httptest.NewRequest(http.MethodPost, "/reset/", strings.NewReader(body))
+	req.RemoteAddr = trustedIP + ":1234"
+	req.Header.Set(hash.Header, hash.Signature([]byte(body), key))
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"affected":1}`, res.Body.String())
+}
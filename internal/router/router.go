@@ -9,44 +9,106 @@ import (
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/clientip"
 	"github.com/nekr0z/muhame/internal/handlers"
+	"github.com/nekr0z/muhame/internal/hash"
+	"github.com/nekr0z/muhame/internal/otlp"
 	"github.com/nekr0z/muhame/internal/storage"
 )
 
 // New returns new router.
-func New(log *zap.Logger, st storage.Storage, key string, privateKey *rsa.PrivateKey, trustedSubnet string) http.Handler {
+func New(log *zap.Logger, st storage.Storage, key string, privateKey *rsa.PrivateKey, trustedSubnet string, opts ...Option) http.Handler {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	keys := hash.Keys{Legacy: key, HMAC: o.signKeys}
+	res := clientip.Resolver{Proxies: o.proxies}
+
+	var maxDecompressed int64
+	if o.limits != nil {
+		maxDecompressed = o.limits.MaxDecompressed
+	}
+
 	r := chi.NewRouter()
 
 	r.Use(logger(log))
 
-	if key != "" {
-		log.Info("using key to verify messages", zap.String("key", key))
-		r.Use(checkSig(key))
-		r.Use(addSig(key))
-	}
+	r.Group(func(r chi.Router) {
+		if o.limits != nil {
+			r.Use(limits(o.limits, o.keys, res))
+		}
 
-	if privateKey != nil {
-		log.Info("using private key to decrypt messages")
-		r.Use(decrypt(privateKey))
-	}
+		if keys.Enabled() {
+			log.Info("using keys to verify messages", zap.Bool("legacy", key != ""), zap.Int("hmac", len(o.signKeys)))
+			r.Use(checkSig(keys, o.replay))
+			r.Use(addSig(keys))
+		}
 
-	if trustedSubnet != "" {
-		log.Info("using trusted subnet", zap.String("subnet", trustedSubnet))
-		r.Use(trusted(trustedSubnet))
-	}
+		if privateKey != nil {
+			log.Info("using private key to decrypt messages")
+			r.Use(decrypt(privateKey))
+		}
+
+		if trustedSubnet != "" {
+			log.Info("using trusted subnet", zap.String("subnet", trustedSubnet))
+			r.Use(trusted(trustedSubnet, res))
+		}
+
+		r.Use(source(res))
+		r.Use(acceptGzip(maxDecompressed))
+		r.Use(respondGzip)
+
+		r.Group(func(r chi.Router) {
+			r.Use(authorize(o.keys, auth.ScopeWrite))
+			r.Post("/update/{type}/{name}/{value}", handlers.UpdateHandleFunc(st))
+			r.Post("/update/", handlers.UpdateJSONHandleFunc(st))
+			r.Post("/updates/", handlers.BulkUpdateHandleFunc(st))
+			r.Post("/api/v1/write", handlers.RemoteWriteHandleFunc(st))
+			r.Post("/write", handlers.InfluxWriteHandleFunc(st))
+			r.Post("/v1/metrics", handlers.OTLPHandleFunc(otlp.New(st)))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(authorize(o.keys, auth.ScopeRead))
+			r.Post("/value/", handlers.ValueJSONHandleFunc(st, o.staleAfter, o.rates))
+			r.Get("/value/{type}/{name}", handlers.ValueHandleFunc(st))
+			r.Get("/rate/{name}", handlers.RateHandleFunc(o.rates))
+			r.Get("/query_range", handlers.QueryRangeHandleFunc(st))
+			r.Get("/metrics", handlers.MetricsHandleFunc(st))
+			r.Get("/alerts", handlers.AlertsHandleFunc(o.alerts))
+			r.Get("/", handlers.RootHandleFunc(st, o.alerts, o.staleAfter, o.rates))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(authorize(o.keys, auth.ScopeAdmin))
+			r.Use(privileged(keys, trustedSubnet, res, o.replay))
+			r.Delete("/value/{type}/{name}", handlers.DeleteHandleFunc(st))
+			r.Post("/delete/", handlers.BulkDeleteHandleFunc(st))
+			r.Post("/reset/", handlers.BulkResetHandleFunc(st))
+		})
+
+		r.Get("/ping", handlers.PingHandleFunc(st))
+
+		r.With(authorize(o.keys, auth.ScopeAdmin)).Handle("/debug/pprof/*", http.DefaultServeMux)
+	})
 
-	r.Use(acceptGzip)
-	r.Use(respondGzip)
+	// The event stream is neither signed nor compressed, since both would
+	// need the whole response.
+	r.Group(func(r chi.Router) {
+		if o.limits != nil {
+			r.Use(limits(o.limits, o.keys, res))
+		}
 
-	r.Post("/update/{type}/{name}/{value}", handlers.UpdateHandleFunc(st))
-	r.Post("/update/", handlers.UpdateJSONHandleFunc(st))
-	r.Post("/updates/", handlers.BulkUpdateHandleFunc(st))
-	r.Post("/value/", handlers.ValueJSONHandleFunc(st))
-	r.Get("/value/{type}/{name}", handlers.ValueHandleFunc(st))
-	r.Get("/ping", handlers.PingHandleFunc(st))
-	r.Get("/", handlers.RootHandleFunc(st))
+		if trustedSubnet != "" {
+			r.Use(trusted(trustedSubnet, res))
+		}
 
-	r.Handle("/debug/pprof/*", http.DefaultServeMux)
+		r.Use(authorize(o.keys, auth.ScopeRead))
+		r.Get("/watch", handlers.WatchHandleFunc(o.hub))
+	})
 
 	return r
 }
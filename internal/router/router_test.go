@@ -1,9 +1,12 @@
 package router_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/rsa"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,17 +15,25 @@ import (
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/snappy"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/nekr0z/muhame/internal/alert"
+	"github.com/nekr0z/muhame/internal/crypt"
 	"github.com/nekr0z/muhame/internal/hash"
 	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/rate"
+	"github.com/nekr0z/muhame/internal/replay"
 	"github.com/nekr0z/muhame/internal/router"
 	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/internal/watch"
+	"github.com/nekr0z/muhame/pkg/prompb"
 )
 
 var testDSN string
@@ -288,6 +299,505 @@ func TestNew_Root(t *testing.T) {
 	assert.Contains(t, res.Header().Values("Content-Type"), "text/html")
 }
 
+func TestNew_Alerts(t *testing.T) {
+	t.Parallel()
+
+	log := zap.NewNop()
+	st, err := storage.New(log.Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	require.NoError(t, st.Update(context.Background(), metrics.Named{Name: "load", Metric: metrics.Gauge(3)}))
+
+	al := alert.New(log.Sugar(), st, []alert.Rule{{Name: "high_load", Type: "gauge", Metric: "load", Op: ">", Threshold: 2}})
+	require.NoError(t, al.Evaluate(context.Background(), time.Now()))
+
+	r := router.New(log, st, "", nil, "", router.WithAlerts(al))
+
+	req := httptest.NewRequest("GET", "/alerts", nil)
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var got []alert.Alert
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &got))
+	require.Len(t, got, 1)
+	assert.Equal(t, alert.StateFiring, got[0].State)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "high_load: load (gauge) is firing")
+
+	r = router.New(log, st, "", nil, "")
+
+	req = httptest.NewRequest("GET", "/alerts", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "[]", res.Body.String())
+}
+
+func TestNew_Stale(t *testing.T) {
+	t.Parallel()
+
+	log := zap.NewNop()
+	st, err := storage.New(log.Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	r := router.New(log, st, "", nil, "", router.WithStaleAfter(time.Hour))
+
+	req := httptest.NewRequest("POST", "/update/gauge/test/1.5", nil)
+	req.RemoteAddr = "10.0.0.7:1234"
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	req = httptest.NewRequest("POST", "/value/", strings.NewReader(`{"id":"test","type":"gauge"}`))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var got struct {
+		Value   float64   `json:"value"`
+		Updated time.Time `json:"updated"`
+		Source  string    `json:"source"`
+		Stale   bool      `json:"stale"`
+	}
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &got))
+	assert.Equal(t, 1.5, got.Value)
+	assert.WithinDuration(t, time.Now(), got.Updated, time.Minute)
+	assert.Equal(t, "10.0.0.7", got.Source)
+	assert.False(t, got.Stale)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "by 10.0.0.7")
+	assert.NotContains(t, res.Body.String(), "(stale)")
+
+	r = router.New(log, st, "", nil, "", router.WithStaleAfter(time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	req = httptest.NewRequest("POST", "/value/", strings.NewReader(`{"id":"test","type":"gauge"}`))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &got))
+	assert.True(t, got.Stale)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "(stale)")
+}
+
+func TestNew_Rate(t *testing.T) {
+	t.Parallel()
+
+	log := zap.NewNop()
+	ms, err := storage.New(log.Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	tr := rate.NewTracker()
+	st := rate.Wrap(ms, tr)
+
+	r := router.New(log, st, "", nil, "", router.WithRates(tr))
+
+	req := httptest.NewRequest("POST", "/update/counter/requests/5?host=a", nil)
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	req = httptest.NewRequest("GET", "/rate/requests?host=a", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	time.Sleep(10 * time.Millisecond)
+
+	req = httptest.NewRequest("POST", "/update/counter/requests/5?host=a", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	req = httptest.NewRequest("GET", "/rate/requests?host=a", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var got struct {
+		ID     string         `json:"id"`
+		Labels metrics.Labels `json:"labels"`
+		rate.Rates
+	}
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &got))
+	assert.Equal(t, "requests", got.ID)
+	assert.Equal(t, metrics.Labels{"host": "a"}, got.Labels)
+	assert.Greater(t, got.Last, 0.0)
+	assert.Equal(t, got.Last, got.M1)
+
+	req = httptest.NewRequest("POST", "/value/", strings.NewReader(`{"id":"requests","type":"counter","labels":{"host":"a"}}`))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var value struct {
+		Delta int64       `json:"delta"`
+		Rate  *rate.Rates `json:"rate"`
+	}
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &value))
+	assert.Equal(t, int64(10), value.Delta)
+	require.NotNil(t, value.Rate)
+	assert.Equal(t, got.Rates, *value.Rate)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "/s, 1m ")
+
+	req = httptest.NewRequest("GET", "/rate/requests", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestNew_Watch(t *testing.T) {
+	t.Parallel()
+
+	log := zap.NewNop()
+	ms, err := storage.New(log.Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	h := watch.NewHub(0)
+	st := watch.Wrap(ms, h)
+
+	srv := httptest.NewServer(router.New(log, st, "testkey", nil, "", router.WithHub(h)))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/watch?prefix=req&type=counter", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	require.NoError(t, st.Update(context.Background(), metrics.Named{Name: "load", Metric: metrics.Gauge(1)}))
+	require.NoError(t, st.Update(context.Background(), metrics.Named{Name: "requests", Metric: metrics.Counter(2)}))
+
+	sc := bufio.NewScanner(resp.Body)
+
+	require.True(t, sc.Scan())
+	assert.Equal(t, "event: metric", sc.Text())
+
+	require.True(t, sc.Scan())
+	data, ok := strings.CutPrefix(sc.Text(), "data: ")
+	require.True(t, ok)
+
+	var got metrics.JSONMetric
+	require.NoError(t, json.Unmarshal([]byte(data), &got))
+	assert.Equal(t, "requests", got.ID)
+	require.NotNil(t, got.Delta)
+	assert.Equal(t, int64(2), *got.Delta)
+
+	h.Close()
+
+	for sc.Scan() {
+		assert.Empty(t, sc.Text())
+	}
+
+	srv = httptest.NewServer(router.New(log, st, "", nil, ""))
+	defer srv.Close()
+
+	resp, err = http.Get(srv.URL + "/watch")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestNew_Labels(t *testing.T) {
+	t.Parallel()
+
+	log := zap.NewNop()
+	st, err := storage.New(log.Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	r := router.New(log, st, "", nil, "")
+
+	req := httptest.NewRequest("POST", "/update/gauge/test/1.5?host=a", nil)
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	req = httptest.NewRequest("POST", "/update/", strings.NewReader(`{"id":"test","type":"gauge","value":2.5,"labels":{"host":"b"}}`))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"id":"test","type":"gauge","value":2.5,"labels":{"host":"b"}}`, res.Body.String())
+
+	req = httptest.NewRequest("GET", "/value/gauge/test?host=a", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "1.5", res.Body.String())
+
+	req = httptest.NewRequest("GET", "/value/gauge/test", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	req = httptest.NewRequest("POST", "/value/", strings.NewReader(`{"id":"test","type":"gauge","labels":{"host":"b"}}`))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	var got metrics.JSONMetric
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &got))
+	want, ok := metrics.Named{Name: "test", Labels: metrics.Labels{"host": "b"}, Metric: metrics.Gauge(2.5)}.JSONMetric()
+	require.True(t, ok)
+	assert.Equal(t, want, got)
+
+	req = httptest.NewRequest("GET", "/?host=b", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "2.5")
+	assert.NotContains(t, res.Body.String(), "1.5")
+
+	req = httptest.NewRequest("POST", "/update/gauge/test/1.5?bad-label=a", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func TestNew_QueryRange(t *testing.T) {
+	t.Parallel()
+
+	log := zap.NewNop()
+	st, err := storage.New(log.Sugar(), storage.Config{InMemory: true, History: time.Hour})
+	require.NoError(t, err)
+
+	r := router.New(log, st, "", nil, "")
+
+	for _, u := range []string{"/update/counter/test/1?host=a", "/update/counter/test/2?host=a"} {
+		req := httptest.NewRequest("POST", u, nil)
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/query_range?name=test&type=counter&host=a", nil)
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	var got struct {
+		ID     string            `json:"id"`
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels"`
+		Points []struct {
+			Value float64 `json:"value"`
+		} `json:"points"`
+	}
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &got))
+	assert.Equal(t, "test", got.ID)
+	assert.Equal(t, "counter", got.Type)
+	assert.Equal(t, map[string]string{"host": "a"}, got.Labels)
+	require.Len(t, got.Points, 2)
+	assert.Equal(t, 3.0, got.Points[1].Value)
+
+	req = httptest.NewRequest("GET", "/query_range?name=test&type=counter&host=a&step=10m", nil)
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &got))
+	assert.Len(t, got.Points, 1)
+	assert.Equal(t, 3.0, got.Points[0].Value)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"name=test&type=counter", http.StatusNotFound},
+		{"name=test", http.StatusBadRequest},
+		{"name=test&type=counter&host=a&from=yesterday", http.StatusBadRequest},
+		{"name=test&type=counter&host=a&from=100&to=10", http.StatusBadRequest},
+		{"name=test&type=counter&host=a&step=-1", http.StatusBadRequest},
+		{"name=test&type=counter&host=a&step=1ms", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/query_range?"+tt.query, nil)
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, req)
+		assert.Equal(t, tt.want, res.Code, tt.query)
+	}
+
+	noHistory, err := storage.New(log.Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	req = httptest.NewRequest("GET", "/query_range?name=test&type=counter", nil)
+	res = httptest.NewRecorder()
+	router.New(log, noHistory, "", nil, "").ServeHTTP(res, req)
+	assert.Equal(t, http.StatusConflict, res.Code)
+}
+
+func TestNew_RemoteWrite(t *testing.T) {
+	t.Parallel()
+
+	log := zap.NewNop()
+	st := &bulkStorage{}
+
+	r := router.New(log, st, "", nil, "")
+
+	wr := &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels: []*prompb.Label{
+					{Name: "__name__", Value: "node_load1"},
+					{Name: "instance", Value: "a"},
+				},
+				Samples: []*prompb.Sample{
+					{Value: 2, Timestamp: 2000},
+					{Value: 1, Timestamp: 1000},
+				},
+			},
+			{
+				Labels: []*prompb.Label{
+					{Name: "instance", Value: "no name"},
+				},
+				Samples: []*prompb.Sample{{Value: 3, Timestamp: 1000}},
+			},
+		},
+	}
+
+	b, err := proto.Marshal(wr)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/api/v1/write", bytes.NewReader(snappy.Encode(nil, b)))
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusNoContent, res.Code, res.Body.String())
+
+	assert.Equal(t, []metrics.Named{{
+		Name:   "node_load1",
+		Labels: metrics.Labels{"instance": "a"},
+		Metric: metrics.Gauge(2),
+	}}, st.got)
+
+	req = httptest.NewRequest("POST", "/api/v1/write", bytes.NewReader(b))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	ms, err := storage.New(log.Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	r = router.New(log, ms, "", nil, "")
+
+	req = httptest.NewRequest("POST", "/api/v1/write", bytes.NewReader(snappy.Encode(nil, b)))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusNoContent, res.Code, res.Body.String())
+
+	m, err := ms.Get(context.Background(), "gauge", "node_load1", metrics.Labels{"instance": "a"})
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(2), m)
+}
+
+func TestNew_InfluxWrite(t *testing.T) {
+	t.Parallel()
+
+	log := zap.NewNop()
+	st := &bulkStorage{}
+
+	r := router.New(log, st, "", nil, "")
+
+	req := httptest.NewRequest("POST", "/write", strings.NewReader("cpu,host=a usage=0.5,procs=3i 1556813561098000000\n"))
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusNoContent, res.Code, res.Body.String())
+
+	assert.Equal(t, []metrics.Named{
+		{Name: "cpu_usage", Labels: metrics.Labels{"host": "a"}, Metric: metrics.Gauge(0.5)},
+		{Name: "cpu_procs", Labels: metrics.Labels{"host": "a"}, Metric: metrics.Gauge(3)},
+	}, st.got)
+
+	req = httptest.NewRequest("POST", "/write", strings.NewReader("cpu usage"))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	ms, err := storage.New(log.Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	r = router.New(log, ms, "", nil, "")
+
+	req = httptest.NewRequest("POST", "/write", strings.NewReader("cpu,host=a usage=0.5\n"))
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusNoContent, res.Code, res.Body.String())
+
+	m, err := ms.Get(context.Background(), "gauge", "cpu_usage", metrics.Labels{"host": "a"})
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(0.5), m)
+}
+
+func TestNew_OTLP(t *testing.T) {
+	t.Parallel()
+
+	log := zap.NewNop()
+	st := &bulkStorage{}
+
+	r := router.New(log, st, "", nil, "")
+
+	body := `{"resourceMetrics":[{"scopeMetrics":[{"metrics":[
+		{"name":"temp","gauge":{"dataPoints":[{"asDouble":21.5,"attributes":[{"key":"room","value":{"stringValue":"hall"}}]}]}},
+		{"name":"summary","summary":{"dataPoints":[{}]}}
+	]}]}]}`
+
+	req := httptest.NewRequest("POST", "/v1/metrics", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+
+	var resp struct {
+		PartialSuccess struct {
+			RejectedDataPoints string `json:"rejectedDataPoints"`
+		} `json:"partialSuccess"`
+	}
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &resp))
+	assert.Equal(t, "1", resp.PartialSuccess.RejectedDataPoints)
+
+	assert.Equal(t, []metrics.Named{
+		{Name: "temp", Labels: metrics.Labels{"room": "hall"}, Metric: metrics.Gauge(21.5)},
+	}, st.got)
+
+	req = httptest.NewRequest("POST", "/v1/metrics", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+
+	req = httptest.NewRequest("POST", "/v1/metrics", strings.NewReader("garbage"))
+	req.Header.Set("Content-Type", "application/json")
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
 func TestNew_Ping(t *testing.T) {
 	t.Parallel()
 
@@ -472,6 +982,143 @@ func TestNew_Signature(t *testing.T) {
 	assert.Equal(t, sig, result.Header.Get(hash.Header))
 }
 
+func TestNew_Replay(t *testing.T) {
+	t.Parallel()
+
+	const key = "testkey"
+
+	log := zap.NewNop()
+	st, err := storage.New(log.Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	r := router.New(log, st, key, nil, trustedSubnet, router.WithReplayGuard(replay.New(time.Minute, 0, true)))
+
+	do := func(method, uri, body, sig string, stamp hash.Stamp) int {
+		req := httptest.NewRequest(method, uri, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = trustedIP + ":1234"
+		req.Header.Set(hash.Header, sig)
+		if stamp.Nonce != "" {
+			req.Header.Set(hash.TimestampHeader, stamp.Timestamp())
+			req.Header.Set(hash.NonceHeader, stamp.Nonce)
+		}
+
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, req)
+
+		return res.Code
+	}
+
+	in := `{"id":"test","type":"counter","delta":1}`
+
+	stamp := hash.NewStamp()
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/update/", in, stamp.Signature([]byte(in), key), stamp))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/update/", in, stamp.Signature([]byte(in), key), stamp), "replayed")
+
+	old := hash.Stamp{Time: time.Now().Add(-time.Hour), Nonce: "old"}
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/update/", in, old.Signature([]byte(in), key), old), "stale")
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/update/", in, hash.Signature([]byte(in), key), hash.Stamp{}), "not stamped")
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/update/", in, "", hash.Stamp{}), "not signed")
+
+	other := hash.NewStamp()
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/update/", in, stamp.Signature([]byte(in), key), other), "wrong stamp")
+
+	m, err := st.Get(context.Background(), "counter", "test", nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Counter(1), m)
+
+	uri := "/value/counter/test"
+	stamp = hash.NewStamp()
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, uri, "", stamp.Signature([]byte(uri), key), stamp))
+	require.NoError(t, st.Update(context.Background(), metrics.Named{Name: "test", Metric: metrics.Counter(1)}))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodDelete, uri, "", stamp.Signature([]byte(uri), key), stamp), "replayed delete")
+
+	_, err = st.Get(context.Background(), "counter", "test", nil)
+	assert.NoError(t, err)
+}
+
+func TestNew_SignKeys(t *testing.T) {
+	t.Parallel()
+
+	log := zap.NewNop()
+	st, err := storage.New(log.Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	r := router.New(log, st, "", nil, trustedSubnet, router.WithSignKeys(map[string]string{
+		"old": "oldkey",
+		"new": "newkey",
+	}))
+
+	in := `{"id":"test","type":"counter","delta":1}`
+
+	do := func(id, sig string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/update/", strings.NewReader(in))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = trustedIP + ":1234"
+		req.Header.Set(hash.Header, sig)
+		if id != "" {
+			req.Header.Set(hash.KeyIDHeader, id)
+		}
+
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, req)
+
+		return res
+	}
+
+	for id, key := range map[string]string{"old": "oldkey", "new": "newkey"} {
+		res := do(id, hash.HMAC([]byte(in), key))
+		require.Equal(t, http.StatusOK, res.Code, id)
+		assert.Equal(t, id, res.Header().Get(hash.KeyIDHeader))
+		assert.Equal(t, hash.HMAC(res.Body.Bytes(), key), res.Header().Get(hash.Header))
+	}
+
+	assert.Equal(t, http.StatusBadRequest, do("new", hash.HMAC([]byte(in), "oldkey")).Code, "wrong key")
+	assert.Equal(t, http.StatusBadRequest, do("older", hash.HMAC([]byte(in), "olderkey")).Code, "retired key")
+	assert.Equal(t, http.StatusBadRequest, do("", hash.Signature([]byte(in), "")).Code, "no legacy key")
+
+	m, err := st.Get(context.Background(), "counter", "test", nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Counter(2), m)
+}
+
+func TestNew_Encrypted(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	labels := metrics.Labels{}
+	for i := range 20 {
+		labels[fmt.Sprintf("label_%d", i)] = strings.Repeat("v", 20)
+	}
+
+	v := 1.5
+	in, err := json.Marshal(metrics.JSONMetric{ID: "test", MType: "gauge", Value: &v, Labels: labels})
+	require.NoError(t, err)
+
+	msg, err := crypt.Encrypt(in, &key.PublicKey)
+	require.NoError(t, err)
+
+	log := zap.NewNop()
+	st, err := storage.New(log.Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	r := router.New(log, st, "", key, "")
+
+	req := httptest.NewRequest(http.MethodPost, "/update/", bytes.NewReader(msg))
+	req.Header.Add("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+
+	r.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	m, err := st.Get(context.Background(), "gauge", "test", labels)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(1.5), m)
+}
+
 var _ storage.Storage = &mockStorage{}
 
 type mockStorage struct {
@@ -487,7 +1134,7 @@ func (m mockStorage) Update(_ context.Context, named metrics.Named) error {
 	return nil
 }
 
-func (m mockStorage) Get(_ context.Context, metricType, name string) (metrics.Metric, error) {
+func (m mockStorage) Get(_ context.Context, metricType, name string, _ metrics.Labels) (metrics.Metric, error) {
 	m.t.Helper()
 
 	if name != m.name {
@@ -497,11 +1144,54 @@ func (m mockStorage) Get(_ context.Context, metricType, name string) (metrics.Me
 	return m.m, nil
 }
 
-func (m mockStorage) List(_ context.Context) ([]metrics.Named, error) {
+func (m mockStorage) Describe(ctx context.Context, metricType, name string, labels metrics.Labels) (storage.Series, error) {
+	m.t.Helper()
+
+	met, err := m.Get(ctx, metricType, name, labels)
+
+	return storage.Series{Named: metrics.Named{Name: name, Labels: labels, Metric: met}}, err
+}
+
+func (m mockStorage) List(_ context.Context, _ metrics.Labels) ([]metrics.Named, error) {
+	m.t.Helper()
+	return nil, nil
+}
+
+func (m mockStorage) ListSeries(_ context.Context, _ metrics.Labels) ([]storage.Series, error) {
 	m.t.Helper()
 	return nil, nil
 }
 
+func (m mockStorage) Delete(_ context.Context, _, name string, _ metrics.Labels) error {
+	m.t.Helper()
+
+	if name != m.name {
+		return storage.ErrMetricNotFound
+	}
+
+	return nil
+}
+
+func (m mockStorage) Reset(_ context.Context, _, name string, _ metrics.Labels) error {
+	m.t.Helper()
+
+	if name != m.name {
+		return storage.ErrMetricNotFound
+	}
+
+	return nil
+}
+
 func (m mockStorage) Close() {
 	m.t.Helper()
 }
+
+type bulkStorage struct {
+	mockStorage
+	got []metrics.Named
+}
+
+func (b *bulkStorage) BulkUpdate(_ context.Context, mm []metrics.Named) error {
+	b.got = append(b.got, mm...)
+	return nil
+}
@@ -0,0 +1,23 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+// source records the address of the client in the request context, so that
+// the storage knows where the updates come from. The address is resolved by
+// the resolver.
+func source(res clientip.Resolver) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := res.FromRequest(r); ip != nil {
+				r = r.WithContext(storage.WithSource(r.Context(), ip.String()))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
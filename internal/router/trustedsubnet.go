@@ -1,17 +1,20 @@
 package router
 
 import (
-	"net"
 	"net/http"
 
-	"github.com/nekr0z/muhame/internal/httpclient"
+	"github.com/nekr0z/muhame/internal/clientip"
 )
 
-func trusted(subnet string) middleware {
+// trusted only lets through the requests from the clients in the trusted
+// subnets, see clientip.Resolver for how the client address is resolved. If
+// the subnets can not be parsed, nothing is let through.
+func trusted(subnet string, res clientip.Resolver) middleware {
+	subnets, err := clientip.ParseSubnets(subnet)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := r.Header.Get(httpclient.HeaderRealIP)
-			if !isInSubnet(ip, subnet) {
+			if err != nil || !subnets.Contains(res.FromRequest(r)) {
 				http.Error(w, "Forbidden", http.StatusForbidden)
 				return
 			}
@@ -20,15 +23,3 @@ func trusted(subnet string) middleware {
 		})
 	}
 }
-
-func isInSubnet(ipStr, cidrStr string) bool {
-	_, cidrNet, err := net.ParseCIDR(cidrStr)
-	if err != nil {
-		return false
-	}
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		return false
-	}
-	return cidrNet.Contains(ip)
-}
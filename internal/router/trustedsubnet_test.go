@@ -8,8 +8,10 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/nekr0z/muhame/internal/clientip"
 	"github.com/nekr0z/muhame/internal/metrics"
 	"github.com/nekr0z/muhame/internal/router"
 )
@@ -33,50 +35,35 @@ func TestTrusted(t *testing.T) {
 	log := zap.NewNop()
 	r := router.New(log, st, "", nil, trustedSubnet)
 
-	srv := httptest.NewServer(r)
-	defer srv.Close()
-
-	t.Run("trusted", func(t *testing.T) {
-		url := fmt.Sprintf("%s/update/counter/%s/%s", srv.URL, name, metric)
-
-		req, _ := http.NewRequest("POST", url, nil)
-		req.Header.Set("X-Real-IP", trustedIP)
-
-		resp, err := http.DefaultClient.Do(req)
-		require.NoError(t, err)
-
-		defer resp.Body.Close()
-
-		require.Equal(t, http.StatusOK, resp.StatusCode)
-	})
-
-	t.Run("untrusted", func(t *testing.T) {
-		url := fmt.Sprintf("%s/update/counter/%s/%s", srv.URL, name, metric)
-
-		req, _ := http.NewRequest("POST", url, nil)
-		req.Header.Set("X-Real-IP", untrustedIP)
-
-		resp, err := http.DefaultClient.Do(req)
-		require.NoError(t, err)
-
-		defer resp.Body.Close()
-
-		require.Equal(t, http.StatusForbidden, resp.StatusCode)
-	})
-
-	t.Run("bad IP", func(t *testing.T) {
-		url := fmt.Sprintf("%s/update/counter/%s/%s", srv.URL, name, metric)
-
-		req, _ := http.NewRequest("POST", url, nil)
-		req.Header.Set("X-Real-IP", badIP)
-
-		resp, err := http.DefaultClient.Do(req)
-		require.NoError(t, err)
-
-		defer resp.Body.Close()
-
-		require.Equal(t, http.StatusForbidden, resp.StatusCode)
-	})
+	tests := []struct {
+		name   string
+		remote string
+		realIP string
+		want   int
+	}{
+		{name: "trusted", remote: trustedIP + ":1234", want: http.StatusOK},
+		{name: "untrusted", remote: untrustedIP + ":1234", want: http.StatusForbidden},
+		{name: "bad IP", remote: badIP, want: http.StatusForbidden},
+		{name: "spoofed real IP", remote: untrustedIP + ":1234", realIP: trustedIP, want: http.StatusForbidden},
+		{name: "untrusted real IP", remote: trustedIP + ":1234", realIP: untrustedIP, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/update/counter/%s/%s", name, metric), nil)
+			req.RemoteAddr = tt.remote
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			res := httptest.NewRecorder()
+			r.ServeHTTP(res, req)
+
+			assert.Equal(t, tt.want, res.Code)
+		})
+	}
 }
 
 func TestTrusted_BadSubnet(t *testing.T) {
@@ -86,18 +73,59 @@ func TestTrusted_BadSubnet(t *testing.T) {
 	log := zap.NewNop()
 	r := router.New(log, st, "", nil, badSubnet)
 
-	srv := httptest.NewServer(r)
-	defer srv.Close()
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/update/counter/%s/%s", name, metric), nil)
+	req.RemoteAddr = trustedIP + ":1234"
 
-	url := fmt.Sprintf("%s/update/counter/%s/%s", srv.URL, name, metric)
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
 
-	req, _ := http.NewRequest("POST", url, nil)
-	req.Header.Set("X-Real-IP", trustedIP)
+	require.Equal(t, http.StatusForbidden, res.Code)
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	require.NoError(t, err)
+func TestTrusted_Proxies(t *testing.T) {
+	t.Parallel()
 
-	defer resp.Body.Close()
+	proxies, err := clientip.ParseSubnets("10.0.0.1,fd00::1")
+	require.NoError(t, err)
 
-	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	st := &mockStorage{t, name, metric}
+	r := router.New(zap.NewNop(), st, "", nil, trustedSubnet+",2001:db8::/32", router.WithTrustedProxies(proxies))
+
+	tests := []struct {
+		name         string
+		remote       string
+		realIP       string
+		forwardedFor string
+		want         int
+	}{
+		{name: "direct", remote: trustedIP + ":1234", want: http.StatusOK},
+		{name: "direct IPv6", remote: "[2001:db8::7]:1234", want: http.StatusOK},
+		{name: "direct untrusted", remote: untrustedIP + ":1234", want: http.StatusForbidden},
+		{name: "spoofed real IP", remote: untrustedIP + ":1234", realIP: trustedIP, want: http.StatusForbidden},
+		{name: "spoofed forwarded", remote: untrustedIP + ":1234", forwardedFor: trustedIP, want: http.StatusForbidden},
+		{name: "proxied", remote: "10.0.0.1:1234", forwardedFor: trustedIP, want: http.StatusOK},
+		{name: "proxied IPv6", remote: "[fd00::1]:1234", forwardedFor: "2001:db8::7, 10.0.0.1", want: http.StatusOK},
+		{name: "proxied real IP", remote: "10.0.0.1:1234", realIP: trustedIP, want: http.StatusOK},
+		{name: "proxied untrusted", remote: "10.0.0.1:1234", forwardedFor: trustedIP + ", " + untrustedIP, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/update/counter/%s/%s", name, metric), nil)
+			req.RemoteAddr = tt.remote
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+
+			res := httptest.NewRecorder()
+			r.ServeHTTP(res, req)
+
+			assert.Equal(t, tt.want, res.Code)
+		})
+	}
 }
@@ -8,21 +8,56 @@ import (
 	"github.com/caarlos0/env/v11"
 
 	"github.com/nekr0z/muhame/internal/addr"
+	"github.com/nekr0z/muhame/internal/alert"
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/clientip"
 	confighelper "github.com/nekr0z/muhame/internal/config"
 	"github.com/nekr0z/muhame/internal/crypt"
+	"github.com/nekr0z/muhame/internal/graphite"
+	"github.com/nekr0z/muhame/internal/hash"
+	"github.com/nekr0z/muhame/internal/limit"
+	"github.com/nekr0z/muhame/internal/sink"
 	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/internal/tlsconfig"
 )
 
 type envConfig struct {
-	Address       addr.NetAddress `env:"ADDRESS" json:"address"`
-	StoreInterval int             `env:"STORE_INTERVAL" json:"store_interval"`
-	Filename      string          `env:"FILE_STORAGE_PATH" json:"store_file"`
-	Restore       bool            `env:"RESTORE" json:"restore"`
-	DatabaseURL   string          `env:"DATABASE_DSN" json:"database_dsn"`
-	Key           string          `env:"KEY" json:"key"`
-	CryptoKey     string          `env:"CRYPTO_KEY" json:"crypto_key"`
-	TrustedSubnet string          `env:"TRUSTED_SUBNET" json:"trusted_subnet"`
-	GRPC          addr.NetAddress `env:"GRPC_ADDRESS" json:"grpc_address"`
+	Address        addr.NetAddress `env:"ADDRESS" json:"address"`
+	StoreInterval  int             `env:"STORE_INTERVAL" json:"store_interval"`
+	Filename       string          `env:"FILE_STORAGE_PATH" json:"store_file"`
+	Restore        bool            `env:"RESTORE" json:"restore"`
+	DatabaseURL    string          `env:"DATABASE_DSN" json:"database_dsn"`
+	Key            string          `env:"KEY" json:"key"`
+	CryptoKey      string          `env:"CRYPTO_KEY" json:"crypto_key"`
+	TrustedSubnet  string          `env:"TRUSTED_SUBNET" json:"trusted_subnet"`
+	Proxies        string          `env:"TRUSTED_PROXIES" json:"trusted_proxies"`
+	GRPC           addr.NetAddress `env:"GRPC_ADDRESS" json:"grpc_address"`
+	History        int             `env:"HISTORY" json:"history"`
+	HistorySamples int             `env:"HISTORY_SAMPLES" json:"history_samples"`
+	TTL            string          `env:"TTL" json:"ttl"`
+	StatsD         addr.NetAddress `env:"STATSD_ADDRESS" json:"statsd_address"`
+	StatsDFlush    int             `env:"STATSD_FLUSH_INTERVAL" json:"statsd_flush_interval"`
+	Graphite       addr.NetAddress `env:"GRAPHITE_ADDRESS" json:"graphite_address"`
+	GraphiteMap    string          `env:"GRAPHITE_MAPPING" json:"graphite_mapping"`
+	AlertRules     string          `env:"ALERT_RULES" json:"alert_rules"`
+	AlertInterval  int             `env:"ALERT_INTERVAL" json:"alert_interval"`
+	Forward        string          `env:"FORWARD" json:"forward"`
+	ForwardBuffer  int             `env:"FORWARD_BUFFER" json:"forward_buffer"`
+	ForwardBatch   int             `env:"FORWARD_BATCH" json:"forward_batch"`
+	StaleAfter     int             `env:"STALE_AFTER" json:"stale_after"`
+	TLSCert        string          `env:"TLS_CERT" json:"tls_cert"`
+	TLSKey         string          `env:"TLS_KEY" json:"tls_key"`
+	TLSClientCA    string          `env:"TLS_CLIENT_CA" json:"tls_client_ca"`
+	APIKeys        string          `env:"API_KEYS" json:"api_keys"`
+	ReplayWindow   int             `env:"REPLAY_WINDOW" json:"replay_window"`
+	NonceCache     int             `env:"NONCE_CACHE" json:"nonce_cache"`
+	RequireNonce   bool            `env:"REQUIRE_NONCE" json:"require_nonce"`
+	SignKeys       string          `env:"SIGN_KEYS" json:"sign_keys"`
+	ClientRate     float64         `env:"CLIENT_RATE" json:"client_rate"`
+	ClientBurst    int             `env:"CLIENT_BURST" json:"client_burst"`
+	MaxBody        int64           `env:"MAX_BODY" json:"max_body"`
+	MaxUnzipped    int64           `env:"MAX_DECOMPRESSED" json:"max_decompressed"`
+	MaxBulk        int             `env:"MAX_BULK" json:"max_bulk"`
 }
 
 func newConfig() config {
@@ -53,8 +88,35 @@ func newConfig() config {
 	flags.StringVar(&cfg.DatabaseURL, "d", cfg.DatabaseURL, "database URL")
 	flags.StringVar(&cfg.Key, "k", cfg.Key, "signing key")
 	flags.StringVar(&cfg.CryptoKey, "crypto-key", cfg.CryptoKey, "private key for message decryption")
-	flags.StringVar(&cfg.TrustedSubnet, "t", cfg.TrustedSubnet, "trusted subnet")
+	flags.StringVar(&cfg.TrustedSubnet, "t", cfg.TrustedSubnet, "comma-separated trusted subnets, e.g. \"10.0.0.0/8,fd00::/8\"")
+	flags.StringVar(&cfg.Proxies, "trusted-proxies", cfg.Proxies, "comma-separated subnets of the proxies whose X-Forwarded-For and X-Real-IP are honoured, if not set the address of the peer is used")
 	flags.Var(&cfg.GRPC, "g", "host:port to use for gRPC")
+	flags.IntVar(&cfg.History, "history", cfg.History, "seconds to keep the history of metrics for, 0 disables history")
+	flags.IntVar(&cfg.HistorySamples, "history-samples", cfg.HistorySamples, "samples to keep per series when the history is kept in memory, 0 means default (10000)")
+	flags.Var(&cfg.StatsD, "statsd", "host:port to receive StatsD metrics on (both UDP and TCP)")
+	flags.IntVar(&cfg.StatsDFlush, "statsd-flush", cfg.StatsDFlush, "seconds between saving the aggregated StatsD metrics, 0 means default (10)")
+	flags.Var(&cfg.Graphite, "graphite", "host:port to receive Graphite plaintext metrics on")
+	flags.StringVar(&cfg.GraphiteMap, "graphite-mapping", cfg.GraphiteMap, "comma-separated mappings of Graphite paths to names and labels, e.g. \"servers.*.cpu.*:.host.name.name\"")
+	flags.StringVar(&cfg.AlertRules, "alert-rules", cfg.AlertRules, "JSON file with alert rules")
+	flags.IntVar(&cfg.AlertInterval, "alert-interval", cfg.AlertInterval, "seconds between evaluating alert rules, 0 means default (15)")
+	flags.StringVar(&cfg.Forward, "forward", cfg.Forward, "comma-separated sinks to forward the accepted metrics to, e.g. \"grpc=localhost:3201,webhook=http://example.com/hook,file=fwd.jsonl\"")
+	flags.IntVar(&cfg.ForwardBuffer, "forward-buffer", cfg.ForwardBuffer, "metrics to buffer per sink, 0 means default (10000)")
+	flags.IntVar(&cfg.ForwardBatch, "forward-batch", cfg.ForwardBatch, "metrics to send to a sink at once, 0 means default (100)")
+	flags.IntVar(&cfg.StaleAfter, "stale-after", cfg.StaleAfter, "seconds after which a series that is not updated is marked as stale, 0 disables marking")
+	flags.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "certificate file to serve HTTP and gRPC over TLS with")
+	flags.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "private key file for the TLS certificate")
+	flags.StringVar(&cfg.TLSClientCA, "tls-client-ca", cfg.TLSClientCA, "CA file to verify client certificates against, enables mutual TLS")
+	flags.StringVar(&cfg.APIKeys, "api-keys", cfg.APIKeys, "JSON file with the API keys to require from the clients")
+	flags.IntVar(&cfg.ReplayWindow, "replay-window", cfg.ReplayWindow, "seconds a signed request is accepted for either way of its timestamp, 0 means default (300)")
+	flags.IntVar(&cfg.NonceCache, "nonce-cache", cfg.NonceCache, "signature nonces to remember, 0 means default (100000)")
+	flags.BoolVar(&cfg.RequireNonce, "require-nonce", cfg.RequireNonce, "reject signed requests without timestamp and nonce")
+	flags.StringVar(&cfg.SignKeys, "sign-keys", cfg.SignKeys, "comma-separated HMAC signing keys by ID, all accepted at once for rotation, e.g. \"2024-01=s3cr3t,2024-07=n3w\"")
+	flags.Float64Var(&cfg.ClientRate, "client-rate", cfg.ClientRate, "requests per second allowed to each client (API key or address), 0 disables rate limiting")
+	flags.IntVar(&cfg.ClientBurst, "client-burst", cfg.ClientBurst, "requests each client is allowed at once, 0 means the rate rounded up")
+	flags.Int64Var(&cfg.MaxBody, "max-body", cfg.MaxBody, "maximum request body size in bytes as sent, 0 means no limit")
+	flags.Int64Var(&cfg.MaxUnzipped, "max-decompressed", cfg.MaxUnzipped, "maximum request body size in bytes after decompression, 0 means no limit")
+	flags.IntVar(&cfg.MaxBulk, "max-bulk", cfg.MaxBulk, "maximum metrics in a bulk request, 0 means no limit")
+	flags.StringVar(&cfg.TTL, "ttl", cfg.TTL, "comma-separated TTL rules for metrics that stop reporting, e.g. \"name:host_*=10m,type:gauge=1h,*=24h\"")
 
 	flags.Parse(os.Args[1:])
 
@@ -63,17 +125,89 @@ func newConfig() config {
 		panic(err)
 	}
 
+	ttl, err := storage.ParseTTLRules(cfg.TTL)
+	if err != nil {
+		panic(err)
+	}
+
+	graphiteMap, err := graphite.ParseMappings(cfg.GraphiteMap)
+	if err != nil {
+		panic(err)
+	}
+
+	sinks, err := sink.ParseConfigs(cfg.Forward)
+	if err != nil {
+		panic(err)
+	}
+
+	signKeys, err := hash.ParseKeys(cfg.SignKeys)
+	if err != nil {
+		panic(err)
+	}
+
+	proxies, err := clientip.ParseSubnets(cfg.Proxies)
+	if err != nil {
+		panic(err)
+	}
+
+	var apiKeys auth.Keys
+	if cfg.APIKeys != "" {
+		apiKeys, err = auth.Load(cfg.APIKeys)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var alertRules []alert.Rule
+	if cfg.AlertRules != "" {
+		alertRules, err = alert.LoadRules(cfg.AlertRules)
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	c := config{
 		address: cfg.Address,
 		st: storage.Config{
-			Interval:    time.Duration(cfg.StoreInterval) * time.Second,
-			Filename:    cfg.Filename,
-			Restore:     cfg.Restore,
-			DatabaseDSN: cfg.DatabaseURL,
+			Interval:       time.Duration(cfg.StoreInterval) * time.Second,
+			Filename:       cfg.Filename,
+			Restore:        cfg.Restore,
+			DatabaseDSN:    cfg.DatabaseURL,
+			History:        time.Duration(cfg.History) * time.Second,
+			HistorySamples: cfg.HistorySamples,
+			TTL:            ttl,
 		},
 		signKey:       cfg.Key,
+		signKeys:      signKeys,
 		trustedSubnet: cfg.TrustedSubnet,
+		proxies:       proxies,
 		gRPCaddress:   cfg.GRPC,
+		statsdAddress: cfg.StatsD,
+		statsdFlush:   time.Duration(cfg.StatsDFlush) * time.Second,
+		graphite:      cfg.Graphite,
+		graphiteMap:   graphiteMap,
+		alertRules:    alertRules,
+		alertInterval: time.Duration(cfg.AlertInterval) * time.Second,
+		sinks:         sinks,
+		sinkBuffer:    cfg.ForwardBuffer,
+		sinkBatch:     cfg.ForwardBatch,
+		staleAfter:    time.Duration(cfg.StaleAfter) * time.Second,
+		apiKeys:       apiKeys,
+		replayWindow:  time.Duration(cfg.ReplayWindow) * time.Second,
+		nonceCache:    cfg.NonceCache,
+		requireNonce:  cfg.RequireNonce,
+		limits: limit.Config{
+			Rate:            cfg.ClientRate,
+			Burst:           cfg.ClientBurst,
+			MaxBody:         cfg.MaxBody,
+			MaxDecompressed: cfg.MaxUnzipped,
+			MaxBulk:         cfg.MaxBulk,
+		},
+		tls: tlsconfig.Files{
+			Cert: cfg.TLSCert,
+			Key:  cfg.TLSKey,
+			CA:   cfg.TLSClientCA,
+		},
 	}
 
 	c.privateKey, err = crypt.LoadPrivateKey(cfg.CryptoKey)
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,7 +11,15 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/nekr0z/muhame/internal/addr"
+	"github.com/nekr0z/muhame/internal/alert"
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/graphite"
+	"github.com/nekr0z/muhame/internal/limit"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/sink"
 	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/internal/tlsconfig"
 )
 
 var testConfigFilename = filepath.Join("testdata", "config.json")
@@ -86,6 +95,235 @@ func TestNewConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "history and ttl",
+			args: []string{"-history", "3600", "-ttl", "name:host_*=10m"},
+			env:  []string{"TTL=type:gauge=1h,*=24h", "HISTORY_SAMPLES=500"},
+			want: config{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				st: storage.Config{
+					Interval:       time.Second * 300,
+					Filename:       "metrics.sav",
+					Restore:        true,
+					History:        time.Hour,
+					HistorySamples: 500,
+					TTL: storage.TTLRules{
+						{Type: "gauge", TTL: time.Hour},
+						{TTL: 24 * time.Hour},
+					},
+				},
+			},
+		},
+		{
+			name: "listeners",
+			args: []string{"-statsd", ":8125", "-graphite", ":2003"},
+			env:  []string{"STATSD_FLUSH_INTERVAL=5", "GRAPHITE_MAPPING=servers.*.*:.host.name"},
+			want: config{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				st: storage.Config{
+					Interval: time.Second * 300,
+					Filename: "metrics.sav",
+					Restore:  true,
+				},
+				statsdAddress: addr.NetAddress{Host: "localhost", Port: 8125},
+				statsdFlush:   5 * time.Second,
+				graphite:      addr.NetAddress{Host: "localhost", Port: 2003},
+				graphiteMap:   graphite.Mappings{{Pattern: "servers.*.*", Template: ".host.name"}},
+			},
+		},
+		{
+			name: "alerts",
+			args: []string{"-alert-rules", filepath.Join("testdata", "alerts.json")},
+			env:  []string{"ALERT_INTERVAL=30"},
+			want: config{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				st: storage.Config{
+					Interval: time.Second * 300,
+					Filename: "metrics.sav",
+					Restore:  true,
+				},
+				alertRules: []alert.Rule{{
+					Name:      "high_load",
+					Type:      "gauge",
+					Metric:    "load",
+					Labels:    metrics.Labels{"host": "a"},
+					Op:        ">",
+					Threshold: 2.5,
+					For:       alert.Duration(5 * time.Minute),
+				}},
+				alertInterval: 30 * time.Second,
+			},
+		},
+		{
+			name: "forward",
+			args: []string{"-forward", "grpc=localhost:3201,file=fwd.jsonl", "-forward-batch", "50"},
+			env:  []string{"FORWARD_BUFFER=500"},
+			want: config{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				st: storage.Config{
+					Interval: time.Second * 300,
+					Filename: "metrics.sav",
+					Restore:  true,
+				},
+				sinks: []sink.Config{
+					{Kind: "grpc", Target: "localhost:3201"},
+					{Kind: "file", Target: "fwd.jsonl"},
+				},
+				sinkBuffer: 500,
+				sinkBatch:  50,
+			},
+		},
+		{
+			name: "stale",
+			args: []string{"-stale-after", "60"},
+			env:  []string{"STALE_AFTER=120"},
+			want: config{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				st: storage.Config{
+					Interval: time.Second * 300,
+					Filename: "metrics.sav",
+					Restore:  true,
+				},
+				staleAfter: 120 * time.Second,
+			},
+		},
+		{
+			name: "tls",
+			args: []string{"-tls-cert", "flag.pem", "-tls-key", "server.key"},
+			env:  []string{"TLS_CERT=server.pem", "TLS_CLIENT_CA=ca.pem"},
+			want: config{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				st: storage.Config{
+					Interval: time.Second * 300,
+					Filename: "metrics.sav",
+					Restore:  true,
+				},
+				tls: tlsconfig.Files{
+					Cert: "server.pem",
+					Key:  "server.key",
+					CA:   "ca.pem",
+				},
+			},
+		},
+		{
+			name: "api keys",
+			args: []string{"-api-keys", filepath.Join("testdata", "keys.json")},
+			want: config{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				st: storage.Config{
+					Interval: time.Second * 300,
+					Filename: "metrics.sav",
+					Restore:  true,
+				},
+				apiKeys: auth.Keys{
+					{Name: "agent", Token: "agent-token", Scopes: []auth.Scope{auth.ScopeWrite}, Prefixes: []string{"host_"}},
+					{Name: "dashboard", Token: "dashboard-token", Scopes: []auth.Scope{auth.ScopeRead}},
+					{Name: "ops", Token: "ops-token", Scopes: []auth.Scope{auth.ScopeAdmin}},
+				},
+			},
+		},
+		{
+			name: "replay",
+			args: []string{"-replay-window", "60", "-require-nonce"},
+			env:  []string{"NONCE_CACHE=1000"},
+			want: config{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				st: storage.Config{
+					Interval: time.Second * 300,
+					Filename: "metrics.sav",
+					Restore:  true,
+				},
+				replayWindow: time.Minute,
+				nonceCache:   1000,
+				requireNonce: true,
+			},
+		},
+		{
+			name: "sign keys",
+			args: []string{"-k", "legacy", "-sign-keys", "flag=key"},
+			env:  []string{"SIGN_KEYS=old=oldkey,new=newkey"},
+			want: config{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				st: storage.Config{
+					Interval: time.Second * 300,
+					Filename: "metrics.sav",
+					Restore:  true,
+				},
+				signKey:  "legacy",
+				signKeys: map[string]string{"old": "oldkey", "new": "newkey"},
+			},
+		},
+		{
+			name: "trusted proxies",
+			args: []string{"-t", "10.0.0.0/8,fd00::/8", "-trusted-proxies", "192.0.2.1"},
+			env:  []string{"TRUSTED_PROXIES=192.0.2.0/24,2001:db8::1"},
+			want: config{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				st: storage.Config{
+					Interval: time.Second * 300,
+					Filename: "metrics.sav",
+					Restore:  true,
+				},
+				trustedSubnet: "10.0.0.0/8,fd00::/8",
+				proxies: clientip.Subnets{
+					{IP: net.IP{192, 0, 2, 0}, Mask: net.CIDRMask(24, 32)},
+					{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(128, 128)},
+				},
+			},
+		},
+		{
+			name: "limits",
+			args: []string{"-client-rate", "2.5", "-client-burst", "10", "-max-body", "1024", "-max-bulk", "500"},
+			env:  []string{"MAX_DECOMPRESSED=4096", "MAX_BULK=100"},
+			want: config{
+				address: addr.NetAddress{
+					Host: "localhost",
+					Port: 8080,
+				},
+				st: storage.Config{
+					Interval: time.Second * 300,
+					Filename: "metrics.sav",
+					Restore:  true,
+				},
+				limits: limit.Config{
+					Rate:            2.5,
+					Burst:           10,
+					MaxBody:         1024,
+					MaxDecompressed: 4096,
+					MaxBulk:         100,
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
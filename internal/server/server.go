@@ -13,13 +13,28 @@ import (
 	"syscall"
 	"time"
 
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/nekr0z/muhame/internal/addr"
+	"github.com/nekr0z/muhame/internal/alert"
+	"github.com/nekr0z/muhame/internal/auth"
+	"github.com/nekr0z/muhame/internal/clientip"
+	"github.com/nekr0z/muhame/internal/graphite"
 	"github.com/nekr0z/muhame/internal/grpcserver"
+	"github.com/nekr0z/muhame/internal/hash"
+	"github.com/nekr0z/muhame/internal/limit"
+	"github.com/nekr0z/muhame/internal/otlp"
+	"github.com/nekr0z/muhame/internal/rate"
+	"github.com/nekr0z/muhame/internal/replay"
 	"github.com/nekr0z/muhame/internal/router"
+	"github.com/nekr0z/muhame/internal/sink"
+	"github.com/nekr0z/muhame/internal/statsd"
 	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/internal/tlsconfig"
+	"github.com/nekr0z/muhame/internal/watch"
 	"github.com/nekr0z/muhame/pkg/proto"
 )
 
@@ -42,25 +57,86 @@ func Run(ctx context.Context) error {
 func run(ctx context.Context, cfg config) error {
 	sugar := *cfg.log.Sugar()
 
+	tlsConfig, err := cfg.tls.Server()
+	if err != nil {
+		return fmt.Errorf("failed to set up TLS: %w", err)
+	}
+
 	st, err := storage.New(&sugar, cfg.st)
 	if err != nil {
 		return fmt.Errorf("failed to set up storage: %w", err)
 	}
 
+	if len(cfg.sinks) > 0 {
+		fw := sink.NewForwarder(&sugar, cfg.sinkBuffer, cfg.sinkBatch)
+		for _, c := range cfg.sinks {
+			s, err := sink.New(c, cfg.signKey)
+			if err != nil {
+				fw.Close()
+				st.Close()
+				return fmt.Errorf("failed to set up sink %s: %w", c, err)
+			}
+
+			sugar.Infof("forwarding metrics to %s", c)
+			fw.Add(c.String(), s)
+		}
+
+		st = sink.Wrap(st, fw)
+	}
+
+	rates := rate.NewTracker()
+	st = rate.Wrap(st, rates)
+
+	hub := watch.NewHub(0)
+	st = watch.Wrap(st, hub)
+
+	// the storage used by the servers checks the metrics against the API key
+	// of the request, the storage used by alerts and the rest does not
+	servedSt := st
+	if len(cfg.apiKeys) > 0 {
+		sugar.Infof("requiring %d API keys", len(cfg.apiKeys))
+		servedSt = auth.Wrap(st)
+	}
+
+	guard := replay.New(cfg.replayWindow, cfg.nonceCache, cfg.requireNonce)
+	signKeys := hash.Keys{Legacy: cfg.signKey, HMAC: cfg.signKeys}
+	res := clientip.Resolver{Proxies: cfg.proxies}
+	limiter := limit.New(cfg.limits)
+
+	var alerts *alert.Engine
+	if len(cfg.alertRules) > 0 {
+		sugar.Infof("evaluating %d alert rules", len(cfg.alertRules))
+		alerts = alert.New(&sugar, st, cfg.alertRules)
+		alerts.Start(cfg.alertInterval)
+	}
+
 	serverChan := make(chan struct{}, 1)
 
 	var grpcServer *grpc.Server
 
 	useGRPC := cfg.gRPCaddress.Port != 0
 
+	handler := router.New(cfg.log, servedSt, cfg.signKey, cfg.privateKey, cfg.trustedSubnet,
+		router.WithAlerts(alerts),
+		router.WithStaleAfter(cfg.staleAfter),
+		router.WithRates(rates),
+		router.WithHub(hub),
+		router.WithKeys(cfg.apiKeys),
+		router.WithReplayGuard(guard),
+		router.WithSignKeys(cfg.signKeys),
+		router.WithTrustedProxies(cfg.proxies),
+		router.WithLimits(limiter),
+	)
+
 	httpServer := &http.Server{
-		Addr:    cfg.address.String(),
-		Handler: router.New(cfg.log, st, cfg.signKey, cfg.privateKey, cfg.trustedSubnet),
+		Addr:      cfg.address.String(),
+		Handler:   handler,
+		TLSConfig: tlsConfig,
 	}
 
 	go func() {
 		sugar.Infof("running server on %s", cfg.address.String())
-		if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		if err := listenAndServe(httpServer); !errors.Is(err, http.ErrServerClosed) {
 			sugar.Errorf("HTTP service error: %s", err)
 		}
 
@@ -77,12 +153,38 @@ func run(ctx context.Context, cfg config) error {
 				sugar.Errorf("failed to listen on %s: %w", cfg.gRPCaddress.String(), err)
 			}
 
-			grpcServer = grpc.NewServer(grpc.ChainUnaryInterceptor(
-				grpcserver.SignatureInterceptor(cfg.signKey),
+			opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(
+				grpcserver.LimitInterceptor(limiter, cfg.apiKeys, res),
+				grpcserver.TrustedInterceptor(cfg.trustedSubnet, res),
+				grpcserver.PrivilegedInterceptor(signKeys, cfg.trustedSubnet, res,
+					proto.MetricsService_Delete_FullMethodName,
+					proto.MetricsService_Reset_FullMethodName,
+				),
+				grpcserver.SignatureInterceptor(signKeys, guard),
 				grpcserver.DecryptInterceptor(cfg.privateKey),
-			))
+				grpcserver.SourceInterceptor(res),
+				grpcserver.AuthInterceptor(cfg.apiKeys, methodScopes),
+			), grpc.ChainStreamInterceptor(
+				grpcserver.LimitStreamInterceptor(limiter, cfg.apiKeys, res),
+				grpcserver.TrustedStreamInterceptor(cfg.trustedSubnet, res),
+				grpcserver.SignatureStreamInterceptor(signKeys, guard),
+				grpcserver.DecryptStreamInterceptor(cfg.privateKey),
+				grpcserver.SourceStreamInterceptor(res),
+				grpcserver.AuthStreamInterceptor(cfg.apiKeys, methodScopes),
+			)}
+
+			if size := max(cfg.limits.MaxBody, cfg.limits.MaxDecompressed); size > 0 {
+				opts = append(opts, grpc.MaxRecvMsgSize(int(size)))
+			}
+
+			if tlsConfig != nil {
+				opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+			}
 
-			proto.RegisterMetricsServiceServer(grpcServer, grpcserver.New(st))
+			grpcServer = grpc.NewServer(opts...)
+
+			proto.RegisterMetricsServiceServer(grpcServer, grpcserver.New(servedSt, grpcserver.WithHub(hub)))
+			colmetricspb.RegisterMetricsServiceServer(grpcServer, otlp.New(servedSt))
 
 			sugar.Infof("running gRPC server on %s", cfg.gRPCaddress.String())
 			if err := grpcServer.Serve(listen); err != nil {
@@ -99,6 +201,42 @@ func run(ctx context.Context, cfg config) error {
 		defer close(grpcChan)
 	}
 
+	statsdChan := make(chan struct{}, 1)
+
+	var statsdServer *statsd.Server
+
+	if cfg.statsdAddress.Port != 0 {
+		statsdServer = statsd.New(&sugar, st, cfg.statsdFlush)
+
+		go func() {
+			sugar.Infof("running StatsD server on %s", cfg.statsdAddress.String())
+			if err := statsdServer.ListenAndServe(cfg.statsdAddress.String()); !errors.Is(err, statsd.ErrServerClosed) {
+				sugar.Errorf("StatsD service error: %s", err)
+			}
+
+			sugar.Info("StatsD service stopped")
+			close(statsdChan)
+		}()
+	}
+
+	graphiteChan := make(chan struct{}, 1)
+
+	var graphiteServer *graphite.Server
+
+	if cfg.graphite.Port != 0 {
+		graphiteServer = graphite.New(&sugar, st, cfg.graphiteMap)
+
+		go func() {
+			sugar.Infof("running Graphite server on %s", cfg.graphite.String())
+			if err := graphiteServer.ListenAndServe(cfg.graphite.String()); !errors.Is(err, graphite.ErrServerClosed) {
+				sugar.Errorf("Graphite service error: %s", err)
+			}
+
+			sugar.Info("Graphite service stopped")
+			close(graphiteChan)
+		}()
+	}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
@@ -109,10 +247,17 @@ func run(ctx context.Context, cfg config) error {
 		sugar.Info("Server stopped, will exit")
 	case <-grpcChan:
 		sugar.Info("gRPC server stopped, will exit")
+	case <-statsdChan:
+		sugar.Info("StatsD server stopped, will exit")
+	case <-graphiteChan:
+		sugar.Info("Graphite server stopped, will exit")
 	case <-ctx.Done():
 		sugar.Info("Context cancelled, will exit")
 	}
 
+	// end the watches, or else the servers would wait for them
+	hub.Close()
+
 	if useGRPC {
 		grpcServer.GracefulStop()
 	}
@@ -124,6 +269,20 @@ func run(ctx context.Context, cfg config) error {
 		sugar.Errorf("HTTP shutdown error: %s", err)
 	}
 
+	if graphiteServer != nil {
+		if err := graphiteServer.Shutdown(shutdownCtx); err != nil {
+			sugar.Errorf("Graphite shutdown error: %s", err)
+		}
+	}
+
+	if statsdServer != nil {
+		if err := statsdServer.Shutdown(shutdownCtx); err != nil {
+			sugar.Errorf("StatsD shutdown error: %s", err)
+		}
+	}
+
+	alerts.Stop()
+
 	st.Close()
 
 	sugar.Info("Shutdown complete.")
@@ -131,12 +290,53 @@ func run(ctx context.Context, cfg config) error {
 	return nil
 }
 
+// methodScopes are the scopes of the API keys the gRPC methods need.
+var methodScopes = map[string]auth.Scope{
+	proto.MetricsService_Update_FullMethodName:                        auth.ScopeWrite,
+	proto.MetricsService_BulkUpdate_FullMethodName:                    auth.ScopeWrite,
+	proto.MetricsService_Stream_FullMethodName:                        auth.ScopeWrite,
+	"/opentelemetry.proto.collector.metrics.v1.MetricsService/Export": auth.ScopeWrite,
+	proto.MetricsService_Get_FullMethodName:                           auth.ScopeRead,
+	proto.MetricsService_List_FullMethodName:                          auth.ScopeRead,
+	proto.MetricsService_Query_FullMethodName:                         auth.ScopeRead,
+	proto.MetricsService_Watch_FullMethodName:                         auth.ScopeRead,
+	proto.MetricsService_Delete_FullMethodName:                        auth.ScopeAdmin,
+	proto.MetricsService_Reset_FullMethodName:                         auth.ScopeAdmin,
+}
+
+// listenAndServe serves over TLS if the server has a TLS configuration.
+func listenAndServe(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		return srv.ListenAndServeTLS("", "")
+	}
+
+	return srv.ListenAndServe()
+}
+
 type config struct {
 	log           *zap.Logger
 	address       addr.NetAddress
 	st            storage.Config
 	signKey       string
+	signKeys      map[string]string
 	privateKey    *rsa.PrivateKey
 	trustedSubnet string
+	proxies       clientip.Subnets
 	gRPCaddress   addr.NetAddress
+	statsdAddress addr.NetAddress
+	statsdFlush   time.Duration
+	graphite      addr.NetAddress
+	graphiteMap   graphite.Mappings
+	alertRules    []alert.Rule
+	alertInterval time.Duration
+	sinks         []sink.Config
+	sinkBuffer    int
+	sinkBatch     int
+	staleAfter    time.Duration
+	apiKeys       auth.Keys
+	replayWindow  time.Duration
+	nonceCache    int
+	requireNonce  bool
+	limits        limit.Config
+	tls           tlsconfig.Files
 }
@@ -0,0 +1,51 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// fileSink appends the metrics to a file, one JSON object per line.
+type fileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func newFileSink(fileName string) (*fileSink, error) {
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileSink{f: f}, nil
+}
+
+// Send implements Sink.
+func (s *fileSink) Send(_ context.Context, mm []metrics.Named) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := bufio.NewWriter(s.f)
+	enc := json.NewEncoder(w)
+
+	for _, jm := range toJSON(mm) {
+		if err := enc.Encode(jm); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// Close implements Sink.
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.f.Close()
+}
@@ -0,0 +1,206 @@
+package sink
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/retry"
+)
+
+const (
+	// DefaultBufferSize is used when no buffer size is set.
+	DefaultBufferSize = 10000
+	// DefaultBatchSize is used when no batch size is set.
+	DefaultBatchSize = 100
+
+	flushInterval = time.Second
+	sendTimeout   = 10 * time.Second
+)
+
+// Stats are the counters of a sink.
+type Stats struct {
+	Sink    string
+	Sent    uint64
+	Dropped uint64
+}
+
+// Forwarder forwards the metrics to the sinks. Each sink has its own buffer
+// and is fed in batches by its own goroutine, so a slow sink never blocks the
+// caller; the metrics that do not fit into the buffer, as well as the batches
+// that could not be sent even after retries, are dropped and counted.
+type Forwarder struct {
+	log       *zap.SugaredLogger
+	buffer    int
+	batchSize int
+
+	mu      sync.RWMutex
+	closed  bool
+	workers []*worker
+	wg      sync.WaitGroup
+}
+
+type worker struct {
+	name string
+	s    Sink
+	ch   chan metrics.Named
+
+	sent, dropped atomic.Uint64
+	overflow      atomic.Uint64
+}
+
+// NewForwarder returns a new Forwarder with no sinks.
+func NewForwarder(log *zap.SugaredLogger, bufferSize, batchSize int) *Forwarder {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	return &Forwarder{
+		log:       log,
+		buffer:    bufferSize,
+		batchSize: batchSize,
+	}
+}
+
+// Add starts forwarding to the sink.
+func (f *Forwarder) Add(name string, s Sink) {
+	w := &worker{
+		name: name,
+		s:    s,
+		ch:   make(chan metrics.Named, f.buffer),
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.workers = append(f.workers, w)
+
+	f.wg.Add(1)
+	go f.run(w)
+}
+
+// Forward queues the metrics for sending to all the sinks. It never blocks.
+func (f *Forwarder) Forward(mm ...metrics.Named) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.closed {
+		return
+	}
+
+	for _, w := range f.workers {
+		for _, m := range mm {
+			select {
+			case w.ch <- m:
+			default:
+				w.dropped.Add(1)
+				w.overflow.Add(1)
+			}
+		}
+	}
+}
+
+// Stats returns the counters of all the sinks.
+func (f *Forwarder) Stats() []Stats {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	ss := make([]Stats, 0, len(f.workers))
+	for _, w := range f.workers {
+		ss = append(ss, Stats{
+			Sink:    w.name,
+			Sent:    w.sent.Load(),
+			Dropped: w.dropped.Load(),
+		})
+	}
+
+	return ss
+}
+
+// Close sends out what is buffered and closes the sinks.
+func (f *Forwarder) Close() {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.closed = true
+
+	for _, w := range f.workers {
+		close(w.ch)
+	}
+	f.mu.Unlock()
+
+	f.wg.Wait()
+
+	for _, s := range f.Stats() {
+		f.log.Infof("sink %s: sent %d metrics, dropped %d", s.Sink, s.Sent, s.Dropped)
+	}
+}
+
+func (f *Forwarder) run(w *worker) {
+	defer f.wg.Done()
+	defer func() {
+		if err := w.s.Close(); err != nil {
+			f.log.Errorf("failed to close sink %s: %s", w.name, err)
+		}
+	}()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]metrics.Named, 0, f.batchSize)
+
+	for {
+		select {
+		case m, ok := <-w.ch:
+			if !ok {
+				f.send(w, batch)
+				return
+			}
+
+			batch = append(batch, m)
+			if len(batch) >= f.batchSize {
+				f.send(w, batch)
+				batch = make([]metrics.Named, 0, f.batchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				f.send(w, batch)
+				batch = make([]metrics.Named, 0, f.batchSize)
+			}
+
+			if n := w.overflow.Swap(0); n > 0 {
+				f.log.Warnf("sink %s: buffer full, dropped %d metrics", w.name, n)
+			}
+		}
+	}
+}
+
+func (f *Forwarder) send(w *worker, batch []metrics.Named) {
+	if len(batch) == 0 {
+		return
+	}
+
+	err := retry.Error(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+
+		return w.s.Send(ctx, batch)
+	}, isTemporary)
+	if err != nil {
+		w.dropped.Add(uint64(len(batch)))
+		f.log.Errorf("sink %s: failed to send %d metrics: %s", w.name, len(batch), err)
+		return
+	}
+
+	w.sent.Add(uint64(len(batch)))
+}
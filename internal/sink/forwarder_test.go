@@ -0,0 +1,125 @@
+package sink_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/sink"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+type mockSink struct {
+	mu      sync.Mutex
+	batches [][]metrics.Named
+	err     error
+	block   chan struct{}
+	closed  bool
+}
+
+func (s *mockSink) Send(_ context.Context, mm []metrics.Named) error {
+	if s.block != nil {
+		<-s.block
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.batches = append(s.batches, mm)
+
+	return nil
+}
+
+func (s *mockSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestForwarder(t *testing.T) {
+	good := &mockSink{}
+	bad := &mockSink{err: errors.New("rejected")}
+
+	f := sink.NewForwarder(zap.NewNop().Sugar(), 10, 2)
+	f.Add("good", good)
+	f.Add("bad", bad)
+
+	f.Forward(testMetrics...)
+	f.Forward(testMetrics[0])
+
+	f.Close()
+
+	assert.Equal(t, [][]metrics.Named{testMetrics, testMetrics[:1]}, good.batches)
+	assert.True(t, good.closed)
+	assert.True(t, bad.closed)
+
+	assert.Equal(t, []sink.Stats{
+		{Sink: "good", Sent: 3},
+		{Sink: "bad", Dropped: 3},
+	}, f.Stats())
+
+	f.Forward(testMetrics...)
+	assert.Equal(t, uint64(3), f.Stats()[0].Sent)
+}
+
+func TestForwarder_Overflow(t *testing.T) {
+	slow := &mockSink{block: make(chan struct{})}
+
+	f := sink.NewForwarder(zap.NewNop().Sugar(), 1, 1)
+	f.Add("slow", slow)
+
+	for range 10 {
+		f.Forward(testMetrics[0])
+	}
+
+	close(slow.block)
+	f.Close()
+
+	s := f.Stats()[0]
+	assert.Equal(t, uint64(10), s.Sent+s.Dropped)
+	assert.NotZero(t, s.Dropped)
+}
+
+func TestWrap(t *testing.T) {
+	ctx := context.Background()
+	log := zap.NewNop().Sugar()
+
+	ms, err := storage.New(log, storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	s := &mockSink{}
+	f := sink.NewForwarder(log, 0, 0)
+	f.Add("mock", s)
+
+	st := sink.Wrap(ms, f)
+
+	h := metrics.NewHistogram([]float64{1})
+	h.Observe(0.5)
+	hist := metrics.Named{Name: "latency", Metric: h}
+
+	require.NoError(t, st.Update(ctx, testMetrics[0]))
+	require.NoError(t, st.Update(ctx, hist))
+	assert.Error(t, st.Update(ctx, metrics.Named{Name: "latency", Metric: metrics.NewHistogram([]float64{2})}))
+
+	_, ok := st.(interface {
+		BulkUpdate(context.Context, []metrics.Named) error
+	})
+	assert.False(t, ok)
+
+	m, err := st.Get(ctx, "gauge", "load", metrics.Labels{"host": "a"})
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(1.5), m)
+
+	st.Close()
+
+	assert.Equal(t, [][]metrics.Named{{testMetrics[0], hist}}, s.batches)
+}
@@ -0,0 +1,104 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/nekr0z/muhame/internal/addr"
+	"github.com/nekr0z/muhame/internal/grpcclient"
+	"github.com/nekr0z/muhame/internal/hash"
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/pkg/proto"
+)
+
+// grpcSink forwards the metrics to the BulkUpdate RPC of another muhame
+// server.
+type grpcSink struct {
+	conn *grpc.ClientConn
+	c    proto.MetricsServiceClient
+}
+
+func newGRPCSink(target, key string) (*grpcSink, error) {
+	var a addr.NetAddress
+	if err := a.Set(target); err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.NewClient(
+		a.String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(
+			grpcclient.SignatureInterceptor(hash.Signer{Key: key}),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcSink{
+		conn: conn,
+		c:    proto.NewMetricsServiceClient(conn),
+	}, nil
+}
+
+// Send implements Sink.
+func (s *grpcSink) Send(ctx context.Context, mm []metrics.Named) error {
+	pm := make([]*proto.Metric, 0, len(mm))
+	for _, m := range mm {
+		if p := toProto(m); p != nil {
+			pm = append(pm, p)
+		}
+	}
+
+	_, err := s.c.BulkUpdate(ctx, &proto.BulkRequest{
+		Payload: &proto.BulkRequest_Metrics{
+			Metrics: &proto.Metrics{
+				Metrics: pm,
+			},
+		},
+	})
+
+	switch status.Code(err) {
+	case codes.OK:
+		return nil
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal:
+		return fmt.Errorf("%w: %w", errTemporary, err)
+	default:
+		return err
+	}
+}
+
+// Close implements Sink.
+func (s *grpcSink) Close() error {
+	return s.conn.Close()
+}
+
+func toProto(m metrics.Named) *proto.Metric {
+	p := &proto.Metric{
+		Name:   m.Name,
+		Labels: m.Labels,
+	}
+
+	switch v := m.Metric.(type) {
+	case metrics.Gauge:
+		p.Value = &proto.Metric_Gauge{Gauge: &proto.Gauge{Value: float64(v)}}
+	case metrics.Counter:
+		p.Value = &proto.Metric_Counter{Counter: &proto.Counter{Delta: int64(v)}}
+	case metrics.Histogram:
+		p.Value = &proto.Metric_Histogram{Histogram: &proto.Histogram{
+			Bounds: v.Bounds,
+			Counts: v.Counts,
+			Sum:    v.Sum,
+			Count:  v.Count,
+		}}
+	default:
+		return nil
+	}
+
+	return p
+}
@@ -0,0 +1,89 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/nekr0z/muhame/internal/addr"
+	"github.com/nekr0z/muhame/internal/httpclient"
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// httpSink forwards the metrics to the /updates/ endpoint of another muhame
+// server.
+type httpSink struct {
+	c        httpclient.Client
+	endpoint string
+}
+
+func newHTTPSink(target, key string) (*httpSink, error) {
+	var a addr.NetAddress
+	if err := a.Set(target); err != nil {
+		return nil, err
+	}
+
+	return &httpSink{
+		c:        httpclient.New().WithKey(key),
+		endpoint: a.StringWithProto() + "/updates/",
+	}, nil
+}
+
+// Send implements Sink.
+func (s *httpSink) Send(ctx context.Context, mm []metrics.Named) error {
+	b, err := json.Marshal(toJSON(mm))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	code, err := s.c.SendContext(ctx, buf.Bytes(), s.endpoint)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errTemporary, err)
+	}
+
+	return checkStatus(code)
+}
+
+// Close implements Sink.
+func (s *httpSink) Close() error {
+	return nil
+}
+
+// checkStatus returns an error for a non-successful HTTP status code, the
+// server errors being temporary.
+func checkStatus(code int) error {
+	switch {
+	case code >= 200 && code < 300:
+		return nil
+	case code >= 500 || code == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: status %d", errTemporary, code)
+	default:
+		return fmt.Errorf("status %d", code)
+	}
+}
+
+func toJSON(mm []metrics.Named) []metrics.JSONMetric {
+	jms := make([]metrics.JSONMetric, 0, len(mm))
+
+	for _, m := range mm {
+		jm, ok := m.JSONMetric()
+		if !ok {
+			continue
+		}
+		jms = append(jms, jm)
+	}
+
+	return jms
+}
@@ -0,0 +1,93 @@
+// Package sink implements forwarding of the accepted metrics to downstream
+// systems.
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// Sink kinds.
+const (
+	KindHTTP    = "http"
+	KindGRPC    = "grpc"
+	KindWebhook = "webhook"
+	KindFile    = "file"
+)
+
+// errTemporary marks the errors after which sending is worth retrying.
+var errTemporary = errors.New("temporary failure")
+
+// Sink is a downstream system the metrics are forwarded to.
+type Sink interface {
+	// Send sends a batch of metrics.
+	Send(context.Context, []metrics.Named) error
+	// Close releases the resources held by the sink.
+	Close() error
+}
+
+// Config describes a sink. Target is the host:port of another muhame server
+// for the "http" and "grpc" kinds, a URL for the "webhook" kind and a file
+// name for the "file" kind.
+type Config struct {
+	Kind   string
+	Target string
+}
+
+// String returns the sink config in the form it is parsed from.
+func (c Config) String() string {
+	return c.Kind + "=" + c.Target
+}
+
+// ParseConfigs parses a comma-separated list of sinks, each in the form of
+// "kind=target", e.g. "grpc=localhost:3201,file=/var/lib/muhame/fwd.jsonl".
+func ParseConfigs(s string) ([]Config, error) {
+	var cc []Config
+
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+
+		kind, target, ok := strings.Cut(c, "=")
+		if !ok || target == "" {
+			return nil, fmt.Errorf("bad sink %q: no target", c)
+		}
+
+		switch kind {
+		case KindHTTP, KindGRPC, KindWebhook, KindFile:
+		default:
+			return nil, fmt.Errorf("bad sink %q: unknown kind %q", c, kind)
+		}
+
+		cc = append(cc, Config{Kind: kind, Target: target})
+	}
+
+	return cc, nil
+}
+
+// New returns a new sink. The key, if not empty, is used to sign the requests
+// to other muhame servers.
+func New(c Config, key string) (Sink, error) {
+	switch c.Kind {
+	case KindHTTP:
+		return newHTTPSink(c.Target, key)
+	case KindGRPC:
+		return newGRPCSink(c.Target, key)
+	case KindWebhook:
+		return newWebhookSink(c.Target), nil
+	case KindFile:
+		return newFileSink(c.Target)
+	default:
+		return nil, fmt.Errorf("unknown sink kind %q", c.Kind)
+	}
+}
+
+func isTemporary(err error) bool {
+	return errors.Is(err, errTemporary)
+}
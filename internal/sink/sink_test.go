@@ -0,0 +1,97 @@
+package sink_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/sink"
+)
+
+func TestParseConfigs(t *testing.T) {
+	cc, err := sink.ParseConfigs("grpc=localhost:3201, webhook=http://example.com/hook?a=b,file=fwd.jsonl")
+	require.NoError(t, err)
+	assert.Equal(t, []sink.Config{
+		{Kind: sink.KindGRPC, Target: "localhost:3201"},
+		{Kind: sink.KindWebhook, Target: "http://example.com/hook?a=b"},
+		{Kind: sink.KindFile, Target: "fwd.jsonl"},
+	}, cc)
+
+	cc, err = sink.ParseConfigs("")
+	assert.NoError(t, err)
+	assert.Empty(t, cc)
+
+	for _, s := range []string{"kafka=localhost:9092", "http", "file="} {
+		_, err = sink.ParseConfigs(s)
+		assert.Error(t, err, s)
+	}
+}
+
+var testMetrics = []metrics.Named{
+	{Name: "load", Labels: metrics.Labels{"host": "a"}, Metric: metrics.Gauge(1.5)},
+	{Name: "requests", Metric: metrics.Counter(3)},
+}
+
+func TestWebhook(t *testing.T) {
+	var got struct {
+		Metrics []metrics.JSONMetric `json:"metrics"`
+	}
+
+	status := http.StatusOK
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	s, err := sink.New(sink.Config{Kind: sink.KindWebhook, Target: srv.URL}, "")
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Send(context.Background(), testMetrics))
+	require.Len(t, got.Metrics, 2)
+
+	nm, err := got.Metrics[0].Named()
+	require.NoError(t, err)
+	assert.Equal(t, testMetrics[0], nm)
+
+	status = http.StatusBadRequest
+	assert.Error(t, s.Send(context.Background(), testMetrics))
+}
+
+func TestFile(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "fwd.jsonl")
+
+	s, err := sink.New(sink.Config{Kind: sink.KindFile, Target: fn}, "")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Send(context.Background(), testMetrics[:1]))
+	require.NoError(t, s.Send(context.Background(), testMetrics[1:]))
+	require.NoError(t, s.Close())
+
+	f, err := os.Open(fn)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var got []metrics.Named
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		nm, err := metrics.FromJSON(scanner.Bytes())
+		require.NoError(t, err)
+		got = append(got, nm)
+	}
+
+	assert.Equal(t, testMetrics, got)
+}
@@ -0,0 +1,109 @@
+package sink
+
+import (
+	"context"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+// Wrap returns the storage that forwards every accepted update to the
+// forwarder. Bulk updates and pings are supported if the storage supports
+// them. Closing the returned storage closes the forwarder, too.
+func Wrap(st storage.Storage, f *Forwarder) storage.Storage {
+	fw := &forwarding{Storage: st, f: f}
+
+	bu, isBulk := st.(bulkUpdater)
+	p, isPing := st.(pingable)
+
+	switch {
+	case isBulk && isPing:
+		return struct {
+			*forwarding
+			bulkForwarding
+			pinger
+		}{fw, bulkForwarding{bu: bu, f: f}, pinger{p}}
+	case isBulk:
+		return struct {
+			*forwarding
+			bulkForwarding
+		}{fw, bulkForwarding{bu: bu, f: f}}
+	case isPing:
+		return struct {
+			*forwarding
+			pinger
+		}{fw, pinger{p}}
+	default:
+		return fw
+	}
+}
+
+type forwarding struct {
+	storage.Storage
+	f *Forwarder
+}
+
+// Update implements storage.Storage.
+func (s *forwarding) Update(ctx context.Context, m metrics.Named) error {
+	if err := s.Storage.Update(ctx, m); err != nil {
+		return err
+	}
+
+	s.f.Forward(m)
+
+	return nil
+}
+
+// Range queries the history of the underlying storage.
+func (s *forwarding) Range(ctx context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error) {
+	rq, ok := s.Storage.(rangeQuerier)
+	if !ok {
+		return nil, storage.ErrHistoryDisabled
+	}
+
+	return rq.Range(ctx, t, name, labels, from, to)
+}
+
+// Close implements storage.Storage.
+func (s *forwarding) Close() {
+	s.f.Close()
+	s.Storage.Close()
+}
+
+type bulkForwarding struct {
+	bu bulkUpdater
+	f  *Forwarder
+}
+
+// BulkUpdate updates the metrics in the underlying storage.
+func (s bulkForwarding) BulkUpdate(ctx context.Context, mm []metrics.Named) error {
+	if err := s.bu.BulkUpdate(ctx, mm); err != nil {
+		return err
+	}
+
+	s.f.Forward(mm...)
+
+	return nil
+}
+
+type pinger struct {
+	p pingable
+}
+
+// Ping pings the underlying storage.
+func (p pinger) Ping(ctx context.Context) error {
+	return p.p.Ping(ctx)
+}
+
+type bulkUpdater interface {
+	BulkUpdate(context.Context, []metrics.Named) error
+}
+
+type pingable interface {
+	Ping(context.Context) error
+}
+
+type rangeQuerier interface {
+	Range(ctx context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error)
+}
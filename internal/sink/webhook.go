@@ -0,0 +1,64 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// webhookTimeout is the timeout of a single webhook request.
+const webhookTimeout = 10 * time.Second
+
+// webhookSink posts the metrics as {"metrics": [...]} JSON to a URL, the
+// metrics being in the same format as accepted by the /update/ endpoint.
+type webhookSink struct {
+	c   *http.Client
+	url string
+}
+
+func newWebhookSink(url string) *webhookSink {
+	return &webhookSink{
+		c:   &http.Client{Timeout: webhookTimeout},
+		url: url,
+	}
+}
+
+// Send implements Sink.
+func (s *webhookSink) Send(ctx context.Context, mm []metrics.Named) error {
+	b, err := json.Marshal(struct {
+		Metrics []metrics.JSONMetric `json:"metrics"`
+	}{
+		Metrics: toJSON(mm),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.c.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errTemporary, err)
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	return checkStatus(resp.StatusCode)
+}
+
+// Close implements Sink.
+func (s *webhookSink) Close() error {
+	s.c.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,218 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+// DefaultTimerBounds are the histogram bucket bounds, in milliseconds, for
+// timers.
+var DefaultTimerBounds = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// aggregated is the state of a series between flushes.
+type aggregated struct {
+	name   string
+	labels metrics.Labels
+
+	sum      float64
+	value    float64
+	relative bool
+	h        metrics.Histogram
+}
+
+// aggregator accumulates samples between flushes. Counters are summed, the
+// last value of a gauge is kept (relative changes are added up), and timers
+// are collected into histograms.
+type aggregator struct {
+	mu     sync.Mutex
+	bounds []float64
+	series map[string]map[string]*aggregated
+}
+
+func newAggregator(bounds []float64) *aggregator {
+	return &aggregator{
+		bounds: bounds,
+		series: make(map[string]map[string]*aggregated),
+	}
+}
+
+func (a *aggregator) add(s sample) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	t := s.t
+	if t == typeHisto {
+		t = typeTimer
+	}
+
+	if _, ok := a.series[t]; !ok {
+		a.series[t] = make(map[string]*aggregated)
+	}
+
+	k := s.name + "\x00" + s.labels.String()
+
+	agg, ok := a.series[t][k]
+	if !ok {
+		agg = &aggregated{
+			name:     s.name,
+			labels:   s.labels,
+			relative: true,
+		}
+		if t == typeTimer {
+			agg.h = metrics.NewHistogram(a.bounds)
+		}
+		a.series[t][k] = agg
+	}
+
+	switch t {
+	case typeCounter:
+		agg.sum += s.value / s.rate
+	case typeGauge:
+		if s.relative {
+			agg.value += s.value
+		} else {
+			agg.value = s.value
+			agg.relative = false
+		}
+	case typeTimer:
+		agg.h.Observe(s.value)
+	}
+}
+
+// take returns the aggregated series and starts over.
+func (a *aggregator) take() map[string]map[string]*aggregated {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	series := a.series
+	a.series = make(map[string]map[string]*aggregated)
+
+	return series
+}
+
+// putBack merges the series that failed to be written back into the ones
+// accumulated since, to be written with the next flush.
+func (a *aggregator) putBack(series map[string]map[string]*aggregated) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for t, ss := range series {
+		if _, ok := a.series[t]; !ok {
+			a.series[t] = make(map[string]*aggregated)
+		}
+
+		for k, old := range ss {
+			agg, ok := a.series[t][k]
+			if !ok {
+				a.series[t][k] = old
+				continue
+			}
+
+			switch t {
+			case typeCounter:
+				agg.sum += old.sum
+			case typeGauge:
+				if agg.relative {
+					agg.value += old.value
+					agg.relative = old.relative
+				}
+			case typeTimer:
+				if h, err := agg.h.Update(old.h); err == nil {
+					agg.h = h.(metrics.Histogram)
+				}
+			}
+		}
+	}
+}
+
+// flush writes the aggregated series into the storage. The series that could
+// not be written are kept for the next flush.
+func (a *aggregator) flush(ctx context.Context, st Storage) error {
+	series := a.take()
+
+	var (
+		mm   []metrics.Named
+		keys [][2]string
+	)
+
+	for t, ss := range series {
+		for k, agg := range ss {
+			m := metrics.Named{
+				Name:   agg.name,
+				Labels: agg.labels,
+			}
+
+			switch t {
+			case typeCounter:
+				m.Metric = metrics.Counter(math.Round(agg.sum))
+			case typeGauge:
+				v := agg.value
+				if agg.relative {
+					have, err := st.Get(ctx, metrics.Gauge(0).Type(), agg.name, agg.labels)
+					if err != nil && !errors.Is(err, storage.ErrMetricNotFound) {
+						a.putBack(series)
+						return err
+					}
+					if g, ok := have.(metrics.Gauge); ok {
+						v += float64(g)
+					}
+				}
+				m.Metric = metrics.Gauge(v)
+			case typeTimer:
+				m.Metric = agg.h
+			}
+
+			mm = append(mm, m)
+			keys = append(keys, [2]string{t, k})
+		}
+	}
+
+	if len(mm) == 0 {
+		return nil
+	}
+
+	if bu, ok := st.(bulkUpdater); ok {
+		err := bu.BulkUpdate(ctx, mm)
+		if err != nil {
+			a.putBack(series)
+		}
+		return err
+	}
+
+	var errs []error
+	failed := make(map[string]map[string]*aggregated)
+
+	for i, m := range mm {
+		err := st.Update(ctx, m)
+		if err == nil {
+			continue
+		}
+
+		errs = append(errs, err)
+
+		t, k := keys[i][0], keys[i][1]
+		if _, ok := failed[t]; !ok {
+			failed[t] = make(map[string]*aggregated)
+		}
+		failed[t][k] = series[t][k]
+	}
+
+	a.putBack(failed)
+
+	return errors.Join(errs...)
+}
+
+// Storage is where the aggregated metrics are written to.
+type Storage interface {
+	Get(ctx context.Context, t, name string, labels metrics.Labels) (metrics.Metric, error)
+	Update(context.Context, metrics.Named) error
+}
+
+type bulkUpdater interface {
+	BulkUpdate(context.Context, []metrics.Named) error
+}
@@ -0,0 +1,82 @@
+package statsd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+func TestAggregator_FlushFailed(t *testing.T) {
+	ctx := context.Background()
+
+	ms, err := storage.New(zap.NewNop().Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+	require.NoError(t, ms.Update(ctx, metrics.Named{Name: "temp", Metric: metrics.Gauge(10)}))
+
+	st := &failingStorage{Storage: ms, failGet: true}
+	a := newAggregator(DefaultTimerBounds)
+
+	a.add(sample{name: "requests", t: typeCounter, value: 1, rate: 1})
+	a.add(sample{name: "temp", t: typeGauge, value: -2, relative: true})
+	a.add(sample{name: "latency", t: typeTimer, value: 7})
+
+	assert.ErrorIs(t, a.flush(ctx, st), assert.AnError)
+
+	a.add(sample{name: "requests", t: typeCounter, value: 2, rate: 1})
+	a.add(sample{name: "temp", t: typeGauge, value: 1, relative: true})
+
+	st.failGet, st.failUpdate = false, true
+	assert.ErrorIs(t, a.flush(ctx, st), assert.AnError)
+
+	a.add(sample{name: "latency", t: typeTimer, value: 300})
+
+	st.failUpdate = false
+	require.NoError(t, a.flush(ctx, st))
+
+	m, err := ms.Get(ctx, "counter", "requests", nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Counter(3), m)
+
+	m, err = ms.Get(ctx, "gauge", "temp", nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(9), m)
+
+	m, err = ms.Get(ctx, "histogram", "latency", nil)
+	require.NoError(t, err)
+	if assert.IsType(t, metrics.Histogram{}, m) {
+		assert.Equal(t, uint64(2), m.(metrics.Histogram).Count)
+	}
+
+	require.NoError(t, a.flush(ctx, st))
+
+	m, err = ms.Get(ctx, "counter", "requests", nil)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Counter(3), m)
+}
+
+type failingStorage struct {
+	storage.Storage
+	failGet, failUpdate bool
+}
+
+func (s *failingStorage) Get(ctx context.Context, t, name string, labels metrics.Labels) (metrics.Metric, error) {
+	if s.failGet {
+		return nil, assert.AnError
+	}
+
+	return s.Storage.Get(ctx, t, name, labels)
+}
+
+func (s *failingStorage) Update(ctx context.Context, m metrics.Named) error {
+	if s.failUpdate {
+		return assert.AnError
+	}
+
+	return s.Storage.Update(ctx, m)
+}
@@ -0,0 +1,102 @@
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// StatsD metric types.
+const (
+	typeCounter = "c"
+	typeGauge   = "g"
+	typeTimer   = "ms"
+	typeHisto   = "h"
+)
+
+// sample is a single parsed StatsD line.
+type sample struct {
+	name     string
+	labels   metrics.Labels
+	t        string
+	value    float64
+	rate     float64
+	relative bool
+}
+
+// parseLine parses a line in the StatsD format, i.e.
+// "name:value|type[|@rate][|#tag:value,...]". DogStatsD-style tags become
+// labels.
+func parseLine(line string) (sample, error) {
+	s := sample{rate: 1}
+
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return s, fmt.Errorf("no metric name in %q", line)
+	}
+	s.name = name
+
+	parts := strings.Split(rest, "|")
+	if len(parts) < 2 {
+		return s, fmt.Errorf("no metric type in %q", line)
+	}
+
+	s.t = parts[1]
+
+	switch s.t {
+	case typeCounter, typeGauge, typeTimer, typeHisto:
+	default:
+		return s, fmt.Errorf("unsupported metric type %q", s.t)
+	}
+
+	v := parts[0]
+	if s.t == typeGauge && (strings.HasPrefix(v, "+") || strings.HasPrefix(v, "-")) {
+		s.relative = true
+	}
+
+	var err error
+
+	s.value, err = strconv.ParseFloat(v, 64)
+	if err != nil {
+		return s, fmt.Errorf("bad value in %q: %w", line, err)
+	}
+
+	for _, p := range parts[2:] {
+		switch {
+		case strings.HasPrefix(p, "@"):
+			s.rate, err = strconv.ParseFloat(p[1:], 64)
+			if err != nil || s.rate <= 0 || s.rate > 1 {
+				return s, fmt.Errorf("bad sample rate in %q", line)
+			}
+		case strings.HasPrefix(p, "#"):
+			s.labels, err = parseTags(p[1:])
+			if err != nil {
+				return s, fmt.Errorf("bad tags in %q: %w", line, err)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// parseTags parses a comma-separated list of "key:value" tags.
+func parseTags(s string) (metrics.Labels, error) {
+	labels := make(metrics.Labels)
+
+	for _, tag := range strings.Split(s, ",") {
+		if tag == "" {
+			continue
+		}
+
+		k, v, _ := strings.Cut(tag, ":")
+		labels[k] = v
+	}
+
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	return labels, labels.Validate()
+}
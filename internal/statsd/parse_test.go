@@ -0,0 +1,58 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    sample
+		wantErr bool
+	}{
+		{
+			line: "requests:1|c",
+			want: sample{name: "requests", t: "c", value: 1, rate: 1},
+		},
+		{
+			line: "requests:1|c|@0.1",
+			want: sample{name: "requests", t: "c", value: 1, rate: 0.1},
+		},
+		{
+			line: "temp:3.2|g",
+			want: sample{name: "temp", t: "g", value: 3.2, rate: 1},
+		},
+		{
+			line: "temp:-1|g",
+			want: sample{name: "temp", t: "g", value: -1, rate: 1, relative: true},
+		},
+		{
+			line: "latency:12|ms|#host:a,env:prod",
+			want: sample{name: "latency", t: "ms", value: 12, rate: 1, labels: metrics.Labels{"host": "a", "env": "prod"}},
+		},
+		{line: "requests", wantErr: true},
+		{line: ":1|c", wantErr: true},
+		{line: "requests:1", wantErr: true},
+		{line: "requests:one|c", wantErr: true},
+		{line: "users:1|s", wantErr: true},
+		{line: "requests:1|c|@2", wantErr: true},
+		{line: "requests:1|c|#bad-tag:a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := parseLine(tt.line)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Package statsd implements a StatsD server that aggregates the received
+// metrics and periodically writes them into storage.
+package statsd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultFlushInterval is used when no flush interval is set.
+const DefaultFlushInterval = 10 * time.Second
+
+// maxPacketSize is the maximum size of a UDP packet.
+const maxPacketSize = 65535
+
+// ErrServerClosed is returned by ListenAndServe after Shutdown.
+var ErrServerClosed = errors.New("statsd: server closed")
+
+// Server receives metrics in the StatsD format over both UDP and TCP.
+type Server struct {
+	log      *zap.SugaredLogger
+	st       Storage
+	interval time.Duration
+	agg      *aggregator
+
+	mu     sync.Mutex
+	closed bool
+	pc     net.PacketConn
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+
+	wg       sync.WaitGroup
+	stopChan chan struct{}
+}
+
+// New returns a new StatsD server that writes into the storage every flush
+// interval.
+func New(log *zap.SugaredLogger, st Storage, interval time.Duration) *Server {
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+
+	return &Server{
+		log:      log,
+		st:       st,
+		interval: interval,
+		agg:      newAggregator(DefaultTimerBounds),
+		conns:    make(map[net.Conn]struct{}),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// ListenAndServe listens on the address for both UDP and TCP and serves until
+// Shutdown is called, then returns ErrServerClosed.
+func (s *Server) ListenAndServe(address string) error {
+	pc, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		_ = pc.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = pc.Close()
+		_ = ln.Close()
+		return ErrServerClosed
+	}
+	s.pc, s.ln = pc, ln
+	s.wg.Add(3)
+	s.mu.Unlock()
+
+	go s.serveUDP()
+	go s.serveTCP()
+	go s.flushLoop()
+
+	<-s.stopChan
+
+	return ErrServerClosed
+}
+
+// Shutdown stops accepting metrics and writes the aggregated ones into the
+// storage.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+
+	close(s.stopChan)
+
+	var errs []error
+	if s.pc != nil {
+		errs = append(errs, s.pc.Close())
+	}
+	if s.ln != nil {
+		errs = append(errs, s.ln.Close())
+	}
+	for c := range s.conns {
+		errs = append(errs, c.Close())
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	errs = append(errs, s.agg.flush(ctx, s.st))
+
+	return errors.Join(errs...)
+}
+
+func (s *Server) serveUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, maxPacketSize)
+
+	for {
+		n, _, err := s.pc.ReadFrom(buf)
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+			s.log.Errorf("failed to read StatsD packet: %s", err)
+			continue
+		}
+
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			s.handleLine(line)
+		}
+	}
+}
+
+func (s *Server) serveTCP() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+			s.log.Errorf("failed to accept StatsD connection: %s", err)
+			continue
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		s.handleLine(scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil && !s.isClosed() {
+		s.log.Errorf("failed to read from StatsD connection: %s", err)
+	}
+}
+
+func (s *Server) flushLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			if err := s.agg.flush(context.Background(), s.st); err != nil {
+				s.log.Errorf("failed to save StatsD metrics: %s", err)
+			}
+		}
+	}
+}
+
+func (s *Server) handleLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	smp, err := parseLine(line)
+	if err != nil {
+		s.log.Debugf("skipping StatsD line: %s", err)
+		return
+	}
+
+	s.agg.add(smp)
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
@@ -0,0 +1,87 @@
+package statsd_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/statsd"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	log := zap.NewNop().Sugar()
+
+	st, err := storage.New(log, storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "temp", Metric: metrics.Gauge(10)}))
+
+	address := freeAddress(t)
+
+	srv := statsd.New(log, st, time.Hour)
+
+	served := make(chan error)
+	go func() {
+		served <- srv.ListenAndServe(address)
+	}()
+
+	require.Eventually(t, func() bool {
+		c, err := net.Dial("tcp", address)
+		if err != nil {
+			return false
+		}
+		defer c.Close()
+
+		_, err = fmt.Fprint(c, "requests:1|c\nrequests:2|c|@0.5\n")
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	udp, err := net.Dial("udp", address)
+	require.NoError(t, err)
+	defer udp.Close()
+
+	_, err = fmt.Fprint(udp, "temp:-2.5|g\nlatency:7|ms|#host:a\nlatency:300|ms|#host:a\nbad line")
+	require.NoError(t, err)
+
+	// UDP is not acknowledged, wait for the packet to be processed.
+	time.Sleep(200 * time.Millisecond)
+
+	require.NoError(t, srv.Shutdown(ctx))
+	assert.True(t, errors.Is(<-served, statsd.ErrServerClosed))
+
+	m, err := st.Get(ctx, "counter", "requests", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Counter(5), m)
+
+	m, err = st.Get(ctx, "gauge", "temp", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(7.5), m)
+
+	m, err = st.Get(ctx, "histogram", "latency", metrics.Labels{"host": "a"})
+	assert.NoError(t, err)
+	if assert.IsType(t, metrics.Histogram{}, m) {
+		h := m.(metrics.Histogram)
+		assert.Equal(t, uint64(2), h.Count)
+		assert.Equal(t, 307.0, h.Sum)
+	}
+}
+
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	return ln.Addr().String()
+}
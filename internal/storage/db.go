@@ -6,12 +6,14 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	_ "github.com/jackc/pgx/v5/stdlib"
 
 	"github.com/nekr0z/muhame/internal/metrics"
@@ -19,23 +21,53 @@ import (
 )
 
 const (
-	countersTable = "counters"
-	gaugesTable   = "gauges"
+	countersTable   = "counters"
+	gaugesTable     = "gauges"
+	histogramsTable = "histograms"
+	samplesTable    = "samples"
 )
 
+// pruneInterval is how often the samples that are older than the history
+// retention period are deleted.
+const pruneInterval = time.Minute
+
 var (
-	gaugeInsert   = fmt.Sprintf("INSERT INTO %s(name, value) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value", gaugesTable)
-	counterInsert = fmt.Sprintf("INSERT INTO %s(name, value) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET value = %s.value + EXCLUDED.value", countersTable, countersTable)
+	gaugeInsert     = fmt.Sprintf("INSERT INTO %s(name, labels, value, source) VALUES ($1, $2, $3, $4) ON CONFLICT (name, labels) DO UPDATE SET value = EXCLUDED.value, updated_at = now(), source = EXCLUDED.source", gaugesTable)
+	counterInsert   = fmt.Sprintf("INSERT INTO %s(name, labels, value, source) VALUES ($1, $2, $3, $4) ON CONFLICT (name, labels) DO UPDATE SET value = %s.value + EXCLUDED.value, updated_at = now(), source = EXCLUDED.source", countersTable, countersTable)
+	histogramInsert = fmt.Sprintf(`INSERT INTO %[1]s(name, labels, bounds, counts, sum, count, source) VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (name, labels) DO UPDATE SET
+	counts = (SELECT array_agg(a + b ORDER BY i) FROM unnest(%[1]s.counts, EXCLUDED.counts) WITH ORDINALITY AS t(a, b, i)),
+	sum = %[1]s.sum + EXCLUDED.sum,
+	count = %[1]s.count + EXCLUDED.count,
+	updated_at = now(),
+	source = EXCLUDED.source
+WHERE %[1]s.bounds = EXCLUDED.bounds`, histogramsTable)
+
+	gaugeSample   = sampleInsert(metrics.Gauge(0).Type(), gaugesTable)
+	counterSample = sampleInsert(metrics.Counter(0).Type(), countersTable)
 )
 
+// sampleInsert returns the query that records the current value of a series
+// from the table into history.
+func sampleInsert(t, table string) string {
+	return fmt.Sprintf("INSERT INTO %s(type, name, labels, ts, value) SELECT '%s', name, labels, now(), value FROM %s WHERE name = $1 AND labels = $2", samplesTable, t, table)
+}
+
+// errHistogramBounds is returned when a histogram update does not match the
+// bucket bounds of the stored histogram.
+var errHistogramBounds = fmt.Errorf("cannot update histogram with different bucket bounds")
+
 //go:embed migrations/*.sql
 var fs embed.FS
 
 type db struct {
 	*sql.DB
+	history            time.Duration
+	stopChan, doneChan chan struct{}
+	r                  *reaper
 }
 
-func newDB(dsn string) (*db, error) {
+func newDB(dsn string, history time.Duration) (*db, error) {
 	src, err := iofs.New(fs, "migrations")
 	if err != nil {
 		return nil, err
@@ -56,11 +88,29 @@ func newDB(dsn string) (*db, error) {
 		return nil, err
 	}
 
-	return &db{database}, nil
+	d := &db{
+		DB:      database,
+		history: history,
+	}
+
+	if history > 0 {
+		d.stopChan = make(chan struct{})
+		d.doneChan = make(chan struct{})
+		go d.pruneLoop()
+	}
+
+	return d, nil
 }
 
 // Close implements the Storage interface.
 func (db *db) Close() {
+	db.r.stop()
+
+	if db.stopChan != nil {
+		close(db.stopChan)
+		<-db.doneChan
+	}
+
 	_ = db.DB.Close()
 }
 
@@ -70,12 +120,14 @@ func (db *db) Ping(ctx context.Context) error {
 }
 
 // Get implements the Storage interface.
-func (db *db) Get(ctx context.Context, t, name string) (metrics.Metric, error) {
+func (db *db) Get(ctx context.Context, t, name string, labels metrics.Labels) (metrics.Metric, error) {
 	switch t {
 	case metrics.Counter(0).Type():
-		return db.getCounter(ctx, name)
+		return db.getCounter(ctx, name, labels)
 	case metrics.Gauge(0).Type():
-		return db.getGauge(ctx, name)
+		return db.getGauge(ctx, name, labels)
+	case metrics.Histogram{}.Type():
+		return db.getHistogram(ctx, name, labels)
 	default:
 		return nil, fmt.Errorf("unknown type %s", t)
 	}
@@ -85,22 +137,148 @@ func (db *db) Get(ctx context.Context, t, name string) (metrics.Metric, error) {
 func (db *db) Update(ctx context.Context, metric metrics.Named) error {
 	switch v := metric.Metric.(type) {
 	case metrics.Gauge:
-		return db.saveGauge(ctx, metric.Name, v)
+		return db.saveGauge(ctx, metric.Name, metric.Labels, v, Source(ctx))
 	case metrics.Counter:
-		return db.updateCounter(ctx, metric.Name, v)
+		return db.updateCounter(ctx, metric.Name, metric.Labels, v, Source(ctx))
+	case metrics.Histogram:
+		return db.updateHistogram(ctx, metric.Name, metric.Labels, v, Source(ctx))
 	default:
 		return fmt.Errorf("unknown metric type")
 	}
 }
 
-// List returns all metrics.
-func (db *db) List(ctx context.Context) ([]metrics.Named, error) {
-	values := make([]metrics.Named, 0)
+// List returns all metrics that match the filter.
+func (db *db) List(ctx context.Context, filter metrics.Labels) ([]metrics.Named, error) {
+	ss, err := db.ListSeries(ctx, filter)
+
+	values := make([]metrics.Named, 0, len(ss))
+	for _, s := range ss {
+		values = append(values, s.Named)
+	}
+
+	return values, err
+}
+
+// ListSeries implements the Storage interface.
+func (db *db) ListSeries(ctx context.Context, filter metrics.Labels) ([]Series, error) {
+	values := make([]Series, 0)
 
-	values, err1 := db.appendCounters(ctx, values)
-	values, err2 := db.appendGauges(ctx, values)
+	values, err1 := db.appendCounters(ctx, values, filter)
+	values, err2 := db.appendGauges(ctx, values, filter)
+	values, err3 := db.appendHistograms(ctx, values, filter)
 
-	return values, errors.Join(err1, err2)
+	return values, errors.Join(err1, err2, err3)
+}
+
+// Describe implements the Storage interface.
+func (db *db) Describe(ctx context.Context, t, name string, labels metrics.Labels) (Series, error) {
+	s := Series{Named: metrics.Named{Name: name, Labels: labels}}
+
+	table, err := tableFor(t)
+	if err != nil {
+		return s, err
+	}
+
+	q := fmt.Sprintf("SELECT updated_at, source FROM %s WHERE name = $1 AND labels = $2", table)
+	r := db.QueryRowContext(ctx, q, name, labels.String())
+
+	err = r.Scan(&s.Updated, &s.Source)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, ErrMetricNotFound
+	}
+	if err != nil {
+		return s, err
+	}
+
+	s.Metric, err = db.Get(ctx, t, name, labels)
+
+	return s, err
+}
+
+// Delete implements the Storage interface.
+func (db *db) Delete(ctx context.Context, t, name string, labels metrics.Labels) error {
+	table, err := tableFor(t)
+	if err != nil {
+		return err
+	}
+
+	q := fmt.Sprintf("DELETE FROM %s WHERE name = $1 AND labels = $2", table)
+
+	res, err := retry.OnError(func() (sql.Result, error) {
+		return db.ExecContext(ctx, q, name, labels.String())
+	}, isConnectionException)
+	if err := checkFound(res, err); err != nil {
+		return err
+	}
+
+	q = fmt.Sprintf("DELETE FROM %s WHERE type = $1 AND name = $2 AND labels = $3", samplesTable)
+
+	_, err = retry.OnError(func() (sql.Result, error) {
+		return db.ExecContext(ctx, q, t, name, labels.String())
+	}, isConnectionException)
+	return err
+}
+
+// Reset implements the Storage interface.
+func (db *db) Reset(ctx context.Context, t, name string, labels metrics.Labels) error {
+	var q, sample string
+
+	switch t {
+	case metrics.Counter(0).Type():
+		q = fmt.Sprintf("UPDATE %s SET value = 0, updated_at = now(), source = $3 WHERE name = $1 AND labels = $2", countersTable)
+		sample = counterSample
+	case metrics.Gauge(0).Type():
+		q = fmt.Sprintf("UPDATE %s SET value = 0, updated_at = now(), source = $3 WHERE name = $1 AND labels = $2", gaugesTable)
+		sample = gaugeSample
+	case metrics.Histogram{}.Type():
+		q = fmt.Sprintf("UPDATE %s SET counts = array_fill(0::BIGINT, ARRAY[cardinality(counts)]), sum = 0, count = 0, updated_at = now(), source = $3 WHERE name = $1 AND labels = $2", histogramsTable)
+	default:
+		return fmt.Errorf("unknown type %s", t)
+	}
+
+	res, err := db.execRecorded(ctx, q, sample, name, labels, Source(ctx))
+	return checkFound(res, err)
+}
+
+// Range returns the samples of the series recorded between from and to.
+func (db *db) Range(ctx context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]Sample, error) {
+	if db.history <= 0 {
+		return nil, ErrHistoryDisabled
+	}
+
+	if _, err := db.Get(ctx, t, name, labels); err != nil {
+		return nil, err
+	}
+
+	q := fmt.Sprintf("SELECT ts, value FROM %s WHERE type = $1 AND name = $2 AND labels = $3 AND ts BETWEEN $4 AND $5 ORDER BY ts", samplesTable)
+
+	rows, err := retry.OnError(func() (*sql.Rows, error) {
+		return db.QueryContext(ctx, q, t, name, labels.String(), from, to)
+	}, isConnectionException)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			panic(err)
+		}
+	}()
+
+	var res []Sample
+
+	for rows.Next() {
+		var s Sample
+
+		err := rows.Scan(&s.Time, &s.Value)
+		if err != nil {
+			return res, err
+		}
+
+		res = append(res, s)
+	}
+
+	return res, rows.Err()
 }
 
 // BulkUpdate updates multiple metrics in a single transaction.
@@ -143,12 +321,35 @@ func (db *db) BulkUpdate(ctx context.Context, mm []metrics.Named) error {
 		}
 	}()
 
+	stmtHistogram, err := retry.OnError(func() (*sql.Stmt, error) {
+		return tx.PrepareContext(ctx, histogramInsert)
+	}, isConnectionException)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = stmtHistogram.Close()
+		if err != nil {
+			panic(err)
+		}
+	}()
+
+	source := Source(ctx)
+
 	for _, m := range mm {
 		switch v := m.Metric.(type) {
 		case metrics.Counter:
-			_, err = stmtCounter.ExecContext(ctx, m.Name, v)
+			_, err = stmtCounter.ExecContext(ctx, m.Name, m.Labels.String(), v, source)
+			if err == nil && db.history > 0 {
+				_, err = tx.ExecContext(ctx, counterSample, m.Name, m.Labels.String())
+			}
 		case metrics.Gauge:
-			_, err = stmtGauge.ExecContext(ctx, m.Name, v)
+			_, err = stmtGauge.ExecContext(ctx, m.Name, m.Labels.String(), v, source)
+			if err == nil && db.history > 0 {
+				_, err = tx.ExecContext(ctx, gaugeSample, m.Name, m.Labels.String())
+			}
+		case metrics.Histogram:
+			err = execHistogram(ctx, stmtHistogram, m.Name, m.Labels, v, source)
 		default:
 			err = fmt.Errorf("unknown metric type")
 		}
@@ -162,42 +363,111 @@ func (db *db) BulkUpdate(ctx context.Context, mm []metrics.Named) error {
 	}, isConnectionException)
 }
 
-func (db *db) getCounter(ctx context.Context, name string) (metrics.Counter, error) {
+func (db *db) getCounter(ctx context.Context, name string, labels metrics.Labels) (metrics.Counter, error) {
 	var c metrics.Counter
 
-	q := fmt.Sprintf("SELECT value FROM %s WHERE name = $1", countersTable)
-	r := db.QueryRowContext(ctx, q, name)
+	q := fmt.Sprintf("SELECT value FROM %s WHERE name = $1 AND labels = $2", countersTable)
+	r := db.QueryRowContext(ctx, q, name, labels.String())
 
 	err := scanMetric(&c, r)
 	return c, err
 }
 
-func (db *db) getGauge(ctx context.Context, name string) (metrics.Gauge, error) {
+func (db *db) getGauge(ctx context.Context, name string, labels metrics.Labels) (metrics.Gauge, error) {
 	var g metrics.Gauge
 
-	q := fmt.Sprintf("SELECT value FROM %s WHERE name = $1", gaugesTable)
-	r := db.QueryRowContext(ctx, q, name)
+	q := fmt.Sprintf("SELECT value FROM %s WHERE name = $1 AND labels = $2", gaugesTable)
+	r := db.QueryRowContext(ctx, q, name, labels.String())
 
 	err := scanMetric(&g, r)
 	return g, err
 }
 
-func (db *db) saveGauge(ctx context.Context, name string, gauge metrics.Gauge) error {
-	_, err := retry.OnError(func() (sql.Result, error) {
-		return db.ExecContext(ctx, gaugeInsert, name, gauge)
-	}, isConnectionException)
+func (db *db) getHistogram(ctx context.Context, name string, labels metrics.Labels) (metrics.Histogram, error) {
+	var h metrics.Histogram
+
+	q := fmt.Sprintf("SELECT bounds, counts, sum, count FROM %s WHERE name = $1 AND labels = $2", histogramsTable)
+	r := db.QueryRowContext(ctx, q, name, labels.String())
+
+	err := r.Scan(histogramDest(&h)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrMetricNotFound
+	}
+
+	return h, err
+}
+
+func (db *db) saveGauge(ctx context.Context, name string, labels metrics.Labels, gauge metrics.Gauge, source string) error {
+	_, err := db.execRecorded(ctx, gaugeInsert, gaugeSample, name, labels, gauge, source)
 	return err
 }
 
-func (db *db) updateCounter(ctx context.Context, name string, counter metrics.Counter) error {
-	_, err := retry.OnError(func() (sql.Result, error) {
-		return db.ExecContext(ctx, counterInsert, name, counter)
-	}, isConnectionException)
+func (db *db) updateCounter(ctx context.Context, name string, labels metrics.Labels, counter metrics.Counter, source string) error {
+	_, err := db.execRecorded(ctx, counterInsert, counterSample, name, labels, counter, source)
 	return err
 }
 
-func (db *db) appendCounters(ctx context.Context, values []metrics.Named) ([]metrics.Named, error) {
-	q := fmt.Sprintf("SELECT name, value FROM %s", countersTable)
+// execRecorded runs the query that writes the series identified by name and
+// labels, which are the first two arguments of the query, followed by args.
+// If history is enabled and the sample query is not empty, the resulting
+// value is recorded into history in the same transaction.
+func (db *db) execRecorded(ctx context.Context, q, sample, name string, labels metrics.Labels, args ...any) (sql.Result, error) {
+	args = append([]any{name, labels.String()}, args...)
+
+	if db.history <= 0 || sample == "" {
+		return retry.OnError(func() (sql.Result, error) {
+			return db.ExecContext(ctx, q, args...)
+		}, isConnectionException)
+	}
+
+	return retry.OnError(func() (sql.Result, error) {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := tx.ExecContext(ctx, q, args...)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, sample, name, labels.String())
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+
+		return res, tx.Commit()
+	}, isConnectionException)
+}
+
+// pruneLoop periodically deletes the samples that are older than the history
+// retention period.
+func (db *db) pruneLoop() {
+	defer close(db.doneChan)
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	q := fmt.Sprintf("DELETE FROM %s WHERE ts < $1", samplesTable)
+
+	for {
+		select {
+		case <-db.stopChan:
+			return
+		case <-ticker.C:
+			_, _ = db.ExecContext(context.Background(), q, time.Now().Add(-db.history))
+		}
+	}
+}
+
+func (db *db) updateHistogram(ctx context.Context, name string, labels metrics.Labels, histogram metrics.Histogram, source string) error {
+	return retry.Error(func() error {
+		res, err := db.ExecContext(ctx, histogramInsert, name, labels.String(), histogram.Bounds, histogram.Counts, histogram.Sum, histogram.Count, source)
+		return checkHistogramResult(res, err)
+	}, isConnectionException)
+}
+
+func (db *db) appendCounters(ctx context.Context, values []Series, filter metrics.Labels) ([]Series, error) {
+	q := fmt.Sprintf("SELECT name, labels, value, updated_at, source FROM %s", countersTable)
 
 	rows, err := retry.OnError(func() (*sql.Rows, error) {
 		return db.QueryContext(ctx, q)
@@ -213,27 +483,28 @@ func (db *db) appendCounters(ctx context.Context, values []metrics.Named) ([]met
 	}()
 
 	var (
-		n string
-		c metrics.Counter
+		n, l, src string
+		c         metrics.Counter
+		updated   time.Time
 	)
 
 	for rows.Next() {
-		err := rows.Scan(&n, &c)
+		err := rows.Scan(&n, &l, &c, &updated, &src)
 		if err != nil {
 			return values, err
 		}
 
-		values = append(values, metrics.Named{
-			Name:   n,
-			Metric: c,
-		})
+		values, err = appendMatching(values, filter, n, l, c, updated, src)
+		if err != nil {
+			return values, err
+		}
 	}
 
 	return values, rows.Err()
 }
 
-func (db *db) appendGauges(ctx context.Context, values []metrics.Named) ([]metrics.Named, error) {
-	q := fmt.Sprintf("SELECT name, value FROM %s", gaugesTable)
+func (db *db) appendGauges(ctx context.Context, values []Series, filter metrics.Labels) ([]Series, error) {
+	q := fmt.Sprintf("SELECT name, labels, value, updated_at, source FROM %s", gaugesTable)
 
 	rows, err := retry.OnError(func() (*sql.Rows, error) {
 		return db.QueryContext(ctx, q)
@@ -249,25 +520,247 @@ func (db *db) appendGauges(ctx context.Context, values []metrics.Named) ([]metri
 	}()
 
 	var (
-		n string
-		g metrics.Gauge
+		n, l, src string
+		g         metrics.Gauge
+		updated   time.Time
 	)
 
 	for rows.Next() {
-		err := rows.Scan(&n, &g)
+		err := rows.Scan(&n, &l, &g, &updated, &src)
 		if err != nil {
 			return values, err
 		}
 
-		values = append(values, metrics.Named{
-			Name:   n,
-			Metric: g,
-		})
+		values, err = appendMatching(values, filter, n, l, g, updated, src)
+		if err != nil {
+			return values, err
+		}
 	}
 
 	return values, rows.Err()
 }
 
+func (db *db) appendHistograms(ctx context.Context, values []Series, filter metrics.Labels) ([]Series, error) {
+	q := fmt.Sprintf("SELECT name, labels, bounds, counts, sum, count, updated_at, source FROM %s", histogramsTable)
+
+	rows, err := retry.OnError(func() (*sql.Rows, error) {
+		return db.QueryContext(ctx, q)
+	}, isConnectionException)
+	if err != nil {
+		return values, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			panic(err)
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			n, l, src string
+			h         metrics.Histogram
+			updated   time.Time
+		)
+
+		err := rows.Scan(append(append([]any{&n, &l}, histogramDest(&h)...), &updated, &src)...)
+		if err != nil {
+			return values, err
+		}
+
+		values, err = appendMatching(values, filter, n, l, h, updated, src)
+		if err != nil {
+			return values, err
+		}
+	}
+
+	return values, rows.Err()
+}
+
+// expire deletes the series that have expired according to the rules.
+func (db *db) expire(ctx context.Context, rules TTLRules) (int, error) {
+	var (
+		n    int
+		errs []error
+	)
+
+	for t, table := range map[string]string{
+		metrics.Counter(0).Type():  countersTable,
+		metrics.Gauge(0).Type():    gaugesTable,
+		metrics.Histogram{}.Type(): histogramsTable,
+	} {
+		k, err := db.expireTable(ctx, rules, t, table)
+		n += k
+		errs = append(errs, err)
+	}
+
+	return n, errors.Join(errs...)
+}
+
+// expireTable deletes the expired series from the table. A series is only
+// deleted if it has not been written to since it was found expired.
+func (db *db) expireTable(ctx context.Context, rules TTLRules, t, table string) (int, error) {
+	type row struct {
+		name, labels string
+		updated      time.Time
+	}
+
+	q := fmt.Sprintf("SELECT name, labels, updated_at, EXTRACT(EPOCH FROM now() - updated_at) FROM %s", table)
+
+	rows, err := retry.OnError(func() (*sql.Rows, error) {
+		return db.QueryContext(ctx, q)
+	}, isConnectionException)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			panic(err)
+		}
+	}()
+
+	var expired []row
+
+	for rows.Next() {
+		var (
+			r   row
+			age float64
+		)
+
+		err := rows.Scan(&r.name, &r.labels, &r.updated, &age)
+		if err != nil {
+			return 0, err
+		}
+
+		if rules.Expired(t, r.name, time.Duration(age*float64(time.Second))) {
+			expired = append(expired, r)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	q = fmt.Sprintf("DELETE FROM %s WHERE name = $1 AND labels = $2 AND updated_at = $3", table)
+	sq := fmt.Sprintf("DELETE FROM %s WHERE type = $1 AND name = $2 AND labels = $3", samplesTable)
+	n := 0
+
+	for _, r := range expired {
+		res, err := retry.OnError(func() (sql.Result, error) {
+			return db.ExecContext(ctx, q, r.name, r.labels, r.updated)
+		}, isConnectionException)
+		if err != nil {
+			return n, err
+		}
+
+		k, err := res.RowsAffected()
+		if err != nil {
+			return n, err
+		}
+
+		if k == 0 {
+			continue
+		}
+
+		n += int(k)
+
+		_, err = retry.OnError(func() (sql.Result, error) {
+			return db.ExecContext(ctx, sq, t, r.name, r.labels)
+		}, isConnectionException)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// appendMatching appends the series to values if its labels match the filter.
+func appendMatching(values []Series, filter metrics.Labels, name, labels string, m metrics.Metric, updated time.Time, source string) ([]Series, error) {
+	l, err := metrics.ParseLabels(labels)
+	if err != nil {
+		return values, err
+	}
+
+	if !l.Matches(filter) {
+		return values, nil
+	}
+
+	return append(values, Series{
+		Named: metrics.Named{
+			Name:   name,
+			Labels: l,
+			Metric: m,
+		},
+		Updated: updated,
+		Source:  source,
+	}), nil
+}
+
+// execHistogram runs the histogram upsert, which merges the histogram into the
+// stored one if the bucket bounds match.
+func execHistogram(ctx context.Context, stmt *sql.Stmt, name string, labels metrics.Labels, h metrics.Histogram, source string) error {
+	res, err := stmt.ExecContext(ctx, name, labels.String(), h.Bounds, h.Counts, h.Sum, h.Count, source)
+	return checkHistogramResult(res, err)
+}
+
+func checkHistogramResult(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return errHistogramBounds
+	}
+
+	return nil
+}
+
+// checkFound returns ErrMetricNotFound if the statement affected no rows.
+func checkFound(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrMetricNotFound
+	}
+
+	return nil
+}
+
+// tableFor returns the table the series of the type are stored in.
+func tableFor(t string) (string, error) {
+	switch t {
+	case metrics.Counter(0).Type():
+		return countersTable, nil
+	case metrics.Gauge(0).Type():
+		return gaugesTable, nil
+	case metrics.Histogram{}.Type():
+		return histogramsTable, nil
+	default:
+		return "", fmt.Errorf("unknown type %s", t)
+	}
+}
+
+// histogramDest returns the scan destinations for bounds, counts, sum and
+// count of a histogram.
+func histogramDest(h *metrics.Histogram) []any {
+	m := pgtype.NewMap()
+	return []any{m.SQLScanner(&h.Bounds), m.SQLScanner(&h.Counts), &h.Sum, &h.Count}
+}
+
 func scanMetric[M metrics.Counter | metrics.Gauge](m *M, r *sql.Row) error {
 	err := r.Scan(m)
 
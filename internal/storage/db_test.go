@@ -2,6 +2,7 @@ package storage_test
 
 import (
 	"context"
+	"database/sql"
 	"embed"
 	"errors"
 	"testing"
@@ -22,7 +23,10 @@ import (
 //go:embed testdata/migrations/*.sql
 var fs embed.FS
 
-var testStorage storage.Storage
+var (
+	testStorage storage.Storage
+	testDSN     string
+)
 
 const (
 	testCounterName = "test_counter"
@@ -68,7 +72,7 @@ func TestGet(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			got, err := testStorage.Get(ctx, tt.want.Type(), tt.name)
+			got, err := testStorage.Get(ctx, tt.want.Type(), tt.name, nil)
 
 			if tt.wantErr {
 				assert.True(t, errors.Is(err, storage.ErrMetricNotFound))
@@ -82,8 +86,9 @@ func TestGet(t *testing.T) {
 
 func TestUpdate(t *testing.T) {
 	var (
-		newCounterName = "another_counter"
-		newGaugeName   = "another_gauge"
+		newCounterName   = "another_counter"
+		newGaugeName     = "another_gauge"
+		newHistogramName = "another_histogram"
 	)
 	tests := []struct {
 		name      string
@@ -111,6 +116,26 @@ func TestUpdate(t *testing.T) {
 			mName:  newGaugeName,
 			mValue: metrics.Gauge(18.4),
 		},
+		{
+			name:  "new histogram",
+			mName: newHistogramName,
+			mValue: metrics.Histogram{
+				Bounds: []float64{0.5, 1},
+				Counts: []uint64{1, 0, 2},
+				Sum:    7.25,
+				Count:  3,
+			},
+		},
+		{
+			name:  "existing histogram",
+			mName: newHistogramName,
+			mValue: metrics.Histogram{
+				Bounds: []float64{0.5, 1},
+				Counts: []uint64{0, 4, 1},
+				Sum:    5.5,
+				Count:  5,
+			},
+		},
 	}
 
 	ctx := context.Background()
@@ -119,7 +144,7 @@ func TestUpdate(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			want := tt.mValue
 
-			have, err := testStorage.Get(ctx, tt.mValue.Type(), tt.mName)
+			have, err := testStorage.Get(ctx, tt.mValue.Type(), tt.mName, nil)
 			if !errors.Is(err, storage.ErrMetricNotFound) {
 				want, err = have.Update(want)
 				assert.NoError(t, err)
@@ -131,7 +156,7 @@ func TestUpdate(t *testing.T) {
 			})
 			assert.NoError(t, err)
 
-			got, err := testStorage.Get(ctx, tt.mValue.Type(), tt.mName)
+			got, err := testStorage.Get(ctx, tt.mValue.Type(), tt.mName, nil)
 			assert.NoError(t, err)
 
 			assert.Equal(t, want, got)
@@ -144,13 +169,188 @@ func TestList(t *testing.T) {
 
 	ctx := context.Background()
 
-	ms, err := testStorage.List(ctx)
+	ms, err := testStorage.List(ctx, nil)
 	assert.NoError(t, err)
 
 	assert.Contains(t, ms, metrics.Named{Name: testCounterName, Metric: testCounterValue})
 	assert.Contains(t, ms, metrics.Named{Name: testGaugeName, Metric: testGaugeValue})
 }
 
+func TestLabels(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	name := "labelled_gauge"
+	labels := metrics.Labels{"host": "a"}
+
+	err := testStorage.Update(ctx, metrics.Named{Name: name, Labels: labels, Metric: metrics.Gauge(1.5)})
+	assert.NoError(t, err)
+
+	got, err := testStorage.Get(ctx, "gauge", name, labels)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(1.5), got)
+
+	_, err = testStorage.Get(ctx, "gauge", name, nil)
+	assert.ErrorIs(t, err, storage.ErrMetricNotFound)
+
+	ms, err := testStorage.List(ctx, labels)
+	assert.NoError(t, err)
+	assert.Equal(t, []metrics.Named{{Name: name, Labels: labels, Metric: metrics.Gauge(1.5)}}, ms)
+}
+
+func TestRange(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	rq, ok := testStorage.(interface {
+		Range(context.Context, string, string, metrics.Labels, time.Time, time.Time) ([]storage.Sample, error)
+	})
+	if !ok {
+		t.Fatal("storage does not support range queries")
+	}
+
+	labels := metrics.Labels{"range": "yes"}
+	from := time.Now().Add(-time.Minute)
+
+	for _, m := range []metrics.Metric{metrics.Counter(1), metrics.Counter(2), metrics.Gauge(3)} {
+		err := testStorage.Update(ctx, metrics.Named{Name: "range", Labels: labels, Metric: m})
+		assert.NoError(t, err)
+	}
+
+	ss, err := rq.Range(ctx, "counter", "range", labels, from, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	if assert.Len(t, ss, 2) {
+		assert.Equal(t, 1.0, ss[0].Value)
+		assert.Equal(t, 3.0, ss[1].Value)
+	}
+
+	ss, err = rq.Range(ctx, "gauge", "range", labels, from, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, ss, 1)
+
+	_, err = rq.Range(ctx, "gauge", "no_range", nil, from, time.Now())
+	assert.ErrorIs(t, err, storage.ErrMetricNotFound)
+}
+
+func TestExpire(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	st, err := storage.New(zap.NewNop().Sugar(), storage.Config{
+		DatabaseDSN: testDSN,
+		History:     time.Hour,
+		TTL:         storage.TTLRules{{Pattern: "expire_*", TTL: time.Millisecond}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	err = st.Update(ctx, metrics.Named{Name: "expire_me", Metric: metrics.Gauge(1)})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, err := st.Get(ctx, "gauge", "expire_me", nil)
+		return errors.Is(err, storage.ErrMetricNotFound)
+	}, 5*time.Second, 100*time.Millisecond)
+
+	_, err = st.Get(ctx, "gauge", testGaugeName, nil)
+	assert.NoError(t, err)
+
+	conn, err := sql.Open("pgx", testDSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var samples int
+	err = conn.QueryRowContext(ctx, "SELECT count(*) FROM samples WHERE name = 'expire_me'").Scan(&samples)
+	assert.NoError(t, err)
+	assert.Zero(t, samples)
+}
+
+func TestDeleteReset(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	st, err := storage.New(zap.NewNop().Sugar(), storage.Config{
+		DatabaseDSN: testDSN,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	h := metrics.NewHistogram([]float64{1, 2})
+	h.Observe(1.5)
+
+	for _, m := range []metrics.Named{
+		{Name: "delete_me", Metric: metrics.Gauge(1)},
+		{Name: "reset_me", Metric: metrics.Counter(3)},
+		{Name: "reset_me", Metric: h},
+	} {
+		assert.NoError(t, st.Update(ctx, m))
+	}
+
+	assert.NoError(t, st.Delete(ctx, "gauge", "delete_me", nil))
+	assert.ErrorIs(t, st.Delete(ctx, "gauge", "delete_me", nil), storage.ErrMetricNotFound)
+
+	_, err = st.Get(ctx, "gauge", "delete_me", nil)
+	assert.ErrorIs(t, err, storage.ErrMetricNotFound)
+
+	assert.NoError(t, st.Reset(ctx, "counter", "reset_me", nil))
+	assert.NoError(t, st.Reset(ctx, "histogram", "reset_me", nil))
+	assert.ErrorIs(t, st.Reset(ctx, "gauge", "reset_me", nil), storage.ErrMetricNotFound)
+
+	m, err := st.Get(ctx, "counter", "reset_me", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Counter(0), m)
+
+	m, err = st.Get(ctx, "histogram", "reset_me", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.NewHistogram([]float64{1, 2}), m)
+}
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+
+	ctx := storage.WithSource(context.Background(), "10.0.0.7")
+
+	st, err := storage.New(zap.NewNop().Sugar(), storage.Config{
+		DatabaseDSN: testDSN,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	assert.NoError(t, st.Update(ctx, metrics.Named{Name: "describe_me", Labels: metrics.Labels{"host": "a"}, Metric: metrics.Gauge(1.5)}))
+
+	s, err := st.Describe(ctx, "gauge", "describe_me", metrics.Labels{"host": "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(1.5), s.Metric)
+	assert.Equal(t, "10.0.0.7", s.Source)
+	assert.WithinDuration(t, time.Now(), s.Updated, time.Minute)
+
+	_, err = st.Describe(ctx, "counter", "describe_me", metrics.Labels{"host": "a"})
+	assert.ErrorIs(t, err, storage.ErrMetricNotFound)
+
+	ss, err := st.ListSeries(ctx, metrics.Labels{"host": "a"})
+	assert.NoError(t, err)
+
+	found := false
+	for _, s := range ss {
+		if s.Name == "describe_me" {
+			found = true
+			assert.Equal(t, "10.0.0.7", s.Source)
+		}
+	}
+	assert.True(t, found)
+}
+
 func TestMain(m *testing.M) {
 	ctx := context.Background()
 	log := zap.NewNop()
@@ -181,19 +381,20 @@ func TestMain(m *testing.M) {
 		}
 	}()
 
-	dsn, err := postgresContainer.ConnectionString(ctx, "sslmode=disable")
+	testDSN, err = postgresContainer.ConnectionString(ctx, "sslmode=disable")
 	if err != nil {
 		panic(err)
 	}
 
 	testStorage, err = storage.New(log.Sugar(), storage.Config{
-		DatabaseDSN: dsn,
+		DatabaseDSN: testDSN,
+		History:     time.Hour,
 	})
 	if err != nil {
 		panic(err)
 	}
 
-	migrateTestData(dsn)
+	migrateTestData(testDSN)
 
 	m.Run()
 }
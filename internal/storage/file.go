@@ -3,6 +3,7 @@ package storage
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"time"
@@ -19,19 +20,32 @@ type Config struct {
 	Restore     bool
 	DatabaseDSN string
 
+	// History is the retention period for the samples of series, zero
+	// disables history.
+	History time.Duration
+	// HistorySamples is the maximum number of samples kept per series when
+	// the history is kept in memory, zero means DefaultMaxSamples.
+	HistorySamples int
+
+	// TTL sets when the series that are not written to expire.
+	TTL TTLRules
+
 	InMemory bool
 }
 
 type fileStorage struct {
 	c                  Config
-	s                  Storage
+	s                  *memStorage
 	stopChan, doneChan chan struct{}
 }
 
 func newFileStorage(ctx context.Context, log *zap.SugaredLogger, c Config) *fileStorage {
+	ms := newMemStorage()
+	ms.h = newHistory(c.History, c.HistorySamples)
+
 	fs := &fileStorage{
 		c:        c,
-		s:        newMemStorage(),
+		s:        ms,
 		stopChan: make(chan struct{}),
 		doneChan: make(chan struct{}),
 	}
@@ -40,6 +54,8 @@ func newFileStorage(ctx context.Context, log *zap.SugaredLogger, c Config) *file
 		fs.load(ctx, log)
 	}
 
+	ms.r = startReaper(log, ms, c.TTL)
+
 	go func() {
 		interval := c.Interval
 		if interval == 0 {
@@ -71,24 +87,64 @@ func (fs *fileStorage) Update(ctx context.Context, m metrics.Named) error {
 		return err
 	}
 
-	if fs.c.Interval == 0 {
-		err := fs.flush(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to save metrics to file: %w", err)
-		}
+	return fs.syncSave(ctx)
+}
+
+// Delete implements the Storage interface.
+func (fs *fileStorage) Delete(ctx context.Context, t, name string, labels metrics.Labels) error {
+	if err := fs.s.Delete(ctx, t, name, labels); err != nil {
+		return err
+	}
+
+	return fs.syncSave(ctx)
+}
+
+// Reset implements the Storage interface.
+func (fs *fileStorage) Reset(ctx context.Context, t, name string, labels metrics.Labels) error {
+	if err := fs.s.Reset(ctx, t, name, labels); err != nil {
+		return err
+	}
+
+	return fs.syncSave(ctx)
+}
+
+// syncSave saves the metrics to file if saving is synchronous.
+func (fs *fileStorage) syncSave(ctx context.Context) error {
+	if fs.c.Interval != 0 {
+		return nil
+	}
+
+	if err := fs.flush(ctx); err != nil {
+		return fmt.Errorf("failed to save metrics to file: %w", err)
 	}
 
 	return nil
 }
 
-// List returns all metrics.
-func (fs *fileStorage) List(ctx context.Context) ([]metrics.Named, error) {
-	return fs.s.List(ctx)
+// Describe implements the Storage interface.
+func (fs *fileStorage) Describe(ctx context.Context, t, name string, labels metrics.Labels) (Series, error) {
+	return fs.s.Describe(ctx, t, name, labels)
+}
+
+// ListSeries implements the Storage interface.
+func (fs *fileStorage) ListSeries(ctx context.Context, filter metrics.Labels) ([]Series, error) {
+	return fs.s.ListSeries(ctx, filter)
+}
+
+// List returns all metrics that match the filter.
+func (fs *fileStorage) List(ctx context.Context, filter metrics.Labels) ([]metrics.Named, error) {
+	return fs.s.List(ctx, filter)
 }
 
-// Get returns a metric by name.
-func (fs *fileStorage) Get(ctx context.Context, t, name string) (metrics.Metric, error) {
-	return fs.s.Get(ctx, t, name)
+// Get returns a metric by name and labels.
+func (fs *fileStorage) Get(ctx context.Context, t, name string, labels metrics.Labels) (metrics.Metric, error) {
+	return fs.s.Get(ctx, t, name, labels)
+}
+
+// Range returns the samples of the series recorded between from and to. The
+// history is only kept in memory and is not saved to file.
+func (fs *fileStorage) Range(ctx context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]Sample, error) {
+	return fs.s.Range(ctx, t, name, labels, from, to)
 }
 
 // Close breaks the flushing loop and blocks until metrics are saved to file (or
@@ -122,15 +178,26 @@ func (fs *fileStorage) restore(ctx context.Context) error {
 		}
 	}()
 
+	now := time.Now()
+
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
-		jm := scanner.Bytes()
-		named, err := metrics.FromJSON(jm)
+		var rec fileRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("failed to parse json: %w", err)
+		}
+
+		named, err := rec.Named()
 		if err != nil {
 			return fmt.Errorf("failed to parse json: %w", err)
 		}
 
-		err = fs.s.Update(ctx, named)
+		updated := rec.Updated
+		if updated.IsZero() {
+			updated = now
+		}
+
+		err = fs.s.restore(named, updated, rec.Source)
 		if err != nil {
 			return fmt.Errorf("failed to update metric: %w", err)
 		}
@@ -170,15 +237,18 @@ func (fs *fileStorage) flush(ctx context.Context) error {
 		}
 	}()
 
-	nameds, err := fs.s.List(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to list metrics: %w", err)
-	}
+	for _, s := range fs.s.snapshot() {
+		jm, ok := s.JSONMetric()
+		if !ok {
+			continue
+		}
 
-	for _, named := range nameds {
-		jm := metrics.ToJSON(named.Metric, named.Name)
+		b, err := json.Marshal(fileRecord{JSONMetric: jm, Updated: s.Updated, Source: s.Source})
+		if err != nil {
+			return fmt.Errorf("failed to marshal metric: %w", err)
+		}
 
-		if err := writeLine(w, jm); err != nil {
+		if err := writeLine(w, b); err != nil {
 			return fmt.Errorf("failed to write to file: %w", err)
 		}
 	}
@@ -186,6 +256,14 @@ func (fs *fileStorage) flush(ctx context.Context) error {
 	return nil
 }
 
+// fileRecord is a line of the file, a metric along with the time it was last
+// written and the client that wrote it.
+type fileRecord struct {
+	metrics.JSONMetric
+	Updated time.Time `json:"updated"`
+	Source  string    `json:"source,omitempty"`
+}
+
 func writeLine(w *bufio.Writer, b []byte) error {
 	_, err := w.Write(b)
 	if err != nil {
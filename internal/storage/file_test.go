@@ -2,9 +2,11 @@ package storage_test
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,7 +19,7 @@ import (
 func TestStopAndLoad(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.Background()
+	ctx := storage.WithSource(context.Background(), "10.0.0.7")
 
 	tempDir, err := os.MkdirTemp(os.TempDir(), "file_storage_test")
 	require.NoError(t, err)
@@ -54,8 +56,45 @@ func TestStopAndLoad(t *testing.T) {
 	newSt, err := storage.New(log, cfg)
 	require.NoError(t, err)
 
-	m, err := newSt.Get(ctx, met.Type(), metName)
+	m, err := newSt.Get(ctx, met.Type(), metName, nil)
 	assert.NoError(t, err)
 
 	assert.Equal(t, met, m)
+
+	s, err := newSt.Describe(ctx, met.Type(), metName, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.7", s.Source)
+	assert.WithinDuration(t, time.Now(), s.Updated, time.Minute)
+}
+
+func TestRestoreExpired(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	fileName := path.Join(t.TempDir(), "test.sav")
+
+	err := os.WriteFile(fileName, []byte(`{"id":"old","type":"gauge","value":1,"updated":"2001-01-01T00:00:00Z"}
+{"id":"fresh","type":"gauge","value":2}
+`), 0o600)
+	require.NoError(t, err)
+
+	cfg := storage.Config{
+		Filename: fileName,
+		Restore:  true,
+		TTL:      storage.TTLRules{{TTL: time.Hour}},
+	}
+
+	st, err := storage.New(zap.NewNop().Sugar(), cfg)
+	require.NoError(t, err)
+	defer st.Close()
+
+	assert.Eventually(t, func() bool {
+		_, err := st.Get(ctx, "gauge", "old", nil)
+		return errors.Is(err, storage.ErrMetricNotFound)
+	}, 5*time.Second, 100*time.Millisecond)
+
+	m, err := st.Get(ctx, "gauge", "fresh", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(2), m)
 }
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+const (
+	// DefaultRange is the time range queried when the start is not specified.
+	DefaultRange = time.Hour
+	// MaxPoints is the maximum number of points a downsampled query may
+	// return.
+	MaxPoints = 11000
+	// DefaultMaxSamples is the number of samples kept in memory per series
+	// when the limit is not specified.
+	DefaultMaxSamples = 10000
+)
+
+// ErrHistoryDisabled is returned on range queries when the storage does not
+// keep history.
+var ErrHistoryDisabled = fmt.Errorf("history is not enabled")
+
+// Sample is a value of a series at a point in time. For counters, the value is
+// the running total.
+type Sample struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// history keeps samples of series in memory for the retention period, but no
+// more than maxSamples samples per series.
+type history struct {
+	retention  time.Duration
+	maxSamples int
+	ss         map[string][]Sample
+}
+
+// newHistory returns a new history, or nil if retention is zero. If maxSamples
+// is not positive, DefaultMaxSamples is used.
+func newHistory(retention time.Duration, maxSamples int) *history {
+	if retention <= 0 {
+		return nil
+	}
+
+	if maxSamples <= 0 {
+		maxSamples = DefaultMaxSamples
+	}
+
+	return &history{
+		retention:  retention,
+		maxSamples: maxSamples,
+		ss:         make(map[string][]Sample),
+	}
+}
+
+// record adds a sample of the metric and drops the samples that are too old,
+// as well as the oldest ones over the limit.
+func (h *history) record(t, key string, m metrics.Metric, now time.Time) {
+	v, ok := sampleValue(m)
+	if !ok {
+		return
+	}
+
+	k := t + "\x00" + key
+	cutoff := now.Add(-h.retention)
+
+	ss := h.ss[k]
+	i, _ := slices.BinarySearchFunc(ss, cutoff, func(s Sample, t time.Time) int {
+		return s.Time.Compare(t)
+	})
+
+	if n := len(ss) - h.maxSamples + 1; n > i {
+		i = n
+	}
+
+	h.ss[k] = append(ss[i:], Sample{Time: now, Value: v})
+}
+
+// forget drops the samples of the series.
+func (h *history) forget(t, key string) {
+	delete(h.ss, t+"\x00"+key)
+}
+
+// query returns the samples between from and to, inclusive.
+func (h *history) query(t, key string, from, to time.Time) []Sample {
+	var res []Sample
+
+	ss := h.ss[t+"\x00"+key]
+	i, _ := slices.BinarySearchFunc(ss, from, func(s Sample, t time.Time) int {
+		return s.Time.Compare(t)
+	})
+
+	for _, s := range ss[i:] {
+		if s.Time.After(to) {
+			break
+		}
+		res = append(res, s)
+	}
+
+	return res
+}
+
+// Downsample returns one point per step from the sorted samples, starting at
+// from. Each point has the value of the last sample that is not later than
+// the point, and points before the first sample are omitted.
+func Downsample(ss []Sample, from, to time.Time, step time.Duration) []Sample {
+	var res []Sample
+
+	i := 0
+	for t := from; !t.After(to); t = t.Add(step) {
+		for i < len(ss) && !ss[i].Time.After(t) {
+			i++
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		res = append(res, Sample{Time: t, Value: ss[i-1].Value})
+	}
+
+	return res
+}
+
+// sampleValue returns the value to record in history for the metric. Only
+// gauges and counters are recorded.
+func sampleValue(m metrics.Metric) (float64, bool) {
+	switch v := m.(type) {
+	case metrics.Gauge:
+		return float64(v), true
+	case metrics.Counter:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
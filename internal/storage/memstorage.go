@@ -3,6 +3,8 @@ package storage
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/nekr0z/muhame/internal/metrics"
 )
@@ -10,64 +12,248 @@ import (
 var _ Storage = &memStorage{}
 
 type memStorage struct {
-	mm map[string]map[string]metrics.Metric
+	mu sync.RWMutex
+	mm map[string]map[string]Series
+	h  *history
+	r  *reaper
 }
 
 func newMemStorage() *memStorage {
 	return &memStorage{
-		mm: make(map[string]map[string]metrics.Metric),
+		mm: make(map[string]map[string]Series),
 	}
 }
 
 // Update implements the Storage interface.
-func (s *memStorage) Update(_ context.Context, m metrics.Named) error {
+func (s *memStorage) Update(ctx context.Context, m metrics.Named) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.update(m, time.Now(), Source(ctx))
+}
+
+func (s *memStorage) update(m metrics.Named, now time.Time, source string) error {
 	t := m.Type()
+	k := seriesKey(m.Name, m.Labels)
 
 	if _, ok := s.mm[t]; !ok {
-		s.mm[t] = make(map[string]metrics.Metric)
+		s.mm[t] = make(map[string]Series)
 	}
 
-	have, ok := s.mm[t][m.Name]
+	have, ok := s.mm[t][k]
 	if !ok {
-		s.mm[t][m.Name] = m.Metric
-		return nil
+		have.Named = metrics.Named{
+			Name:   m.Name,
+			Labels: m.Labels.Clone(),
+			Metric: m.Metric,
+		}
+	} else {
+		var err error
+		have.Metric, err = have.Update(m.Metric)
+		if err != nil {
+			return err
+		}
 	}
 
-	var err error
-	s.mm[t][m.Name], err = have.Update(m.Metric)
+	have.Updated = now
+	have.Source = source
+	s.mm[t][k] = have
+
+	if s.h != nil {
+		s.h.record(t, k, have.Metric, now)
+	}
+
+	return nil
+}
+
+// restore adds a series that was last written at the given time by the given
+// client.
+func (s *memStorage) restore(m metrics.Named, updated time.Time, source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return err
+	return s.update(m, updated, source)
 }
 
 // Get implements the Storage interface.
-func (s *memStorage) Get(_ context.Context, t, name string) (metrics.Metric, error) {
+func (s *memStorage) Get(_ context.Context, t, name string, labels metrics.Labels) (metrics.Metric, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	mm, ok := s.mm[t]
 	if !ok {
 		return nil, ErrMetricNotFound
 	}
 
-	m, ok := mm[name]
+	m, ok := mm[seriesKey(name, labels)]
 	if !ok {
 		return nil, ErrMetricNotFound
 	}
 
+	return m.Metric, nil
+}
+
+// Describe implements the Storage interface.
+func (s *memStorage) Describe(_ context.Context, t, name string, labels metrics.Labels) (Series, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.mm[t][seriesKey(name, labels)]
+	if !ok {
+		return Series{}, ErrMetricNotFound
+	}
+
 	return m, nil
 }
 
+// ListSeries implements the Storage interface.
+func (s *memStorage) ListSeries(_ context.Context, filter metrics.Labels) ([]Series, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ss []Series
+
+	for _, mm := range s.mm {
+		for _, m := range mm {
+			if m.Labels.Matches(filter) {
+				ss = append(ss, m)
+			}
+		}
+	}
+
+	return ss, nil
+}
+
 // List implements the Storage interface.
-func (s *memStorage) List(_ context.Context) ([]metrics.Named, error) {
+func (s *memStorage) List(_ context.Context, filter metrics.Labels) ([]metrics.Named, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	var mms []metrics.Named
 
 	for _, mm := range s.mm {
-		for name, m := range mm {
-			named := metrics.Named{Name: name, Metric: m}
-			mms = append(mms, named)
+		for _, m := range mm {
+			if m.Labels.Matches(filter) {
+				mms = append(mms, m.Named)
+			}
 		}
 	}
 
 	return mms, nil
 }
 
+// Delete implements the Storage interface.
+func (s *memStorage) Delete(_ context.Context, t, name string, labels metrics.Labels) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := seriesKey(name, labels)
+
+	if _, ok := s.mm[t][k]; !ok {
+		return ErrMetricNotFound
+	}
+
+	delete(s.mm[t], k)
+	if s.h != nil {
+		s.h.forget(t, k)
+	}
+
+	return nil
+}
+
+// Reset implements the Storage interface.
+func (s *memStorage) Reset(ctx context.Context, t, name string, labels metrics.Labels) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := seriesKey(name, labels)
+
+	have, ok := s.mm[t][k]
+	if !ok {
+		return ErrMetricNotFound
+	}
+
+	now := time.Now()
+
+	have.Metric = metrics.Zero(have.Metric)
+	have.Updated = now
+	have.Source = Source(ctx)
+	s.mm[t][k] = have
+
+	if s.h != nil {
+		s.h.record(t, k, have.Metric, now)
+	}
+
+	return nil
+}
+
+// snapshot returns all the stored series.
+func (s *memStorage) snapshot() []Series {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ss []Series
+
+	for _, mm := range s.mm {
+		for _, m := range mm {
+			ss = append(ss, m)
+		}
+	}
+
+	return ss
+}
+
+// expire deletes the series that have expired according to the rules.
+func (s *memStorage) expire(_ context.Context, rules TTLRules) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	n := 0
+
+	for t, mm := range s.mm {
+		for k, m := range mm {
+			if !rules.Expired(t, m.Name, now.Sub(m.Updated)) {
+				continue
+			}
+
+			delete(mm, k)
+			if s.h != nil {
+				s.h.forget(t, k)
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// Range returns the samples of the series recorded between from and to.
+func (s *memStorage) Range(_ context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]Sample, error) {
+	if s.h == nil {
+		return nil, ErrHistoryDisabled
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.mm[t][seriesKey(name, labels)]; !ok {
+		return nil, ErrMetricNotFound
+	}
+
+	return s.h.query(t, seriesKey(name, labels), from, to), nil
+}
+
 // Close implements the Storage interface.
 func (s *memStorage) Close() {
+	s.r.stop()
+}
+
+// seriesKey returns the key that identifies the series of a given type.
+func seriesKey(name string, labels metrics.Labels) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	return name + "\x00" + labels.String()
 }
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -21,7 +22,7 @@ func TestMemStorage(t *testing.T) {
 		err := ms.Update(ctx, v)
 		assert.NoError(t, err)
 
-		m, err := ms.Get(ctx, "gauge", "test")
+		m, err := ms.Get(ctx, "gauge", "test", nil)
 		assert.NoError(t, err)
 		assert.Equal(t, v.Metric, m)
 	})
@@ -34,7 +35,7 @@ func TestMemStorage(t *testing.T) {
 		err := ms.Update(ctx, v)
 		assert.NoError(t, err)
 
-		m, err := ms.Get(ctx, "gauge", "test")
+		m, err := ms.Get(ctx, "gauge", "test", nil)
 		assert.NoError(t, err)
 		assert.Equal(t, v.Metric, m)
 	})
@@ -47,7 +48,7 @@ func TestMemStorage(t *testing.T) {
 		err := ms.Update(ctx, v)
 		assert.NoError(t, err)
 
-		m, err := ms.Get(ctx, "counter", "test")
+		m, err := ms.Get(ctx, "counter", "test", nil)
 		assert.NoError(t, err)
 		assert.Equal(t, v.Metric, m)
 	})
@@ -60,14 +61,44 @@ func TestMemStorage(t *testing.T) {
 		err := ms.Update(ctx, v)
 		assert.NoError(t, err)
 
-		m, err := ms.Get(ctx, "counter", "test")
+		m, err := ms.Get(ctx, "counter", "test", nil)
 		assert.NoError(t, err)
 		assert.Equal(t, m, metrics.Counter(5))
 	})
 
+	t.Run("histogram", func(t *testing.T) {
+		h := metrics.NewHistogram([]float64{1})
+		h.Observe(0.5)
+
+		v := metrics.Named{
+			Name:   "test",
+			Metric: h,
+		}
+		err := ms.Update(ctx, v)
+		assert.NoError(t, err)
+
+		err = ms.Update(ctx, v)
+		assert.NoError(t, err)
+
+		m, err := ms.Get(ctx, "histogram", "test", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, metrics.Histogram{
+			Bounds: []float64{1},
+			Counts: []uint64{2, 0},
+			Sum:    1,
+			Count:  2,
+		}, m)
+
+		err = ms.Update(ctx, metrics.Named{
+			Name:   "test",
+			Metric: metrics.NewHistogram([]float64{2}),
+		})
+		assert.Error(t, err)
+	})
+
 	t.Run("list", func(t *testing.T) {
 		ctx := context.Background()
-		ms, err := ms.List(ctx)
+		ms, err := ms.List(ctx, nil)
 		assert.NoError(t, err)
 		assert.ElementsMatch(t, ms, []metrics.Named{
 			{
@@ -78,6 +109,260 @@ func TestMemStorage(t *testing.T) {
 				Name:   "test",
 				Metric: metrics.Counter(5),
 			},
+			{
+				Name: "test",
+				Metric: metrics.Histogram{
+					Bounds: []float64{1},
+					Counts: []uint64{2, 0},
+					Sum:    1,
+					Count:  2,
+				},
+			},
 		})
 	})
 }
+
+func TestMemStorage_Labels(t *testing.T) {
+	ms := newMemStorage()
+	ctx := context.Background()
+
+	hostA := metrics.Labels{"host": "a", "env": "prod"}
+	hostB := metrics.Labels{"host": "b", "env": "prod"}
+
+	for _, m := range []metrics.Named{
+		{Name: "test", Metric: metrics.Counter(1)},
+		{Name: "test", Labels: hostA, Metric: metrics.Counter(2)},
+		{Name: "test", Labels: hostB, Metric: metrics.Counter(3)},
+		{Name: "test", Labels: hostA, Metric: metrics.Counter(4)},
+	} {
+		err := ms.Update(ctx, m)
+		assert.NoError(t, err)
+	}
+
+	m, err := ms.Get(ctx, "counter", "test", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Counter(1), m)
+
+	m, err = ms.Get(ctx, "counter", "test", metrics.Labels{"env": "prod", "host": "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Counter(6), m)
+
+	_, err = ms.Get(ctx, "counter", "test", metrics.Labels{"host": "a"})
+	assert.ErrorIs(t, err, ErrMetricNotFound)
+
+	list, err := ms.List(ctx, metrics.Labels{"host": "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, []metrics.Named{{Name: "test", Labels: hostB, Metric: metrics.Counter(3)}}, list)
+
+	list, err = ms.List(ctx, metrics.Labels{"env": "prod"})
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	list, err = ms.List(ctx, nil)
+	assert.NoError(t, err)
+	assert.Len(t, list, 3)
+}
+
+func TestMemStorage_Range(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disabled", func(t *testing.T) {
+		ms := newMemStorage()
+
+		err := ms.Update(ctx, metrics.Named{Name: "test", Metric: metrics.Gauge(1)})
+		assert.NoError(t, err)
+
+		_, err = ms.Range(ctx, "gauge", "test", nil, time.Time{}, time.Now())
+		assert.ErrorIs(t, err, ErrHistoryDisabled)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		ms := newMemStorage()
+		ms.h = newHistory(time.Hour, 0)
+
+		from := time.Now()
+
+		for _, m := range []metrics.Named{
+			{Name: "test", Metric: metrics.Counter(1)},
+			{Name: "test", Metric: metrics.Counter(2)},
+			{Name: "test", Labels: metrics.Labels{"host": "a"}, Metric: metrics.Counter(5)},
+			{Name: "test", Metric: metrics.Gauge(1.5)},
+		} {
+			err := ms.Update(ctx, m)
+			assert.NoError(t, err)
+		}
+
+		ss, err := ms.Range(ctx, "counter", "test", nil, from, time.Now())
+		assert.NoError(t, err)
+		assert.Len(t, ss, 2)
+		assert.Equal(t, 1.0, ss[0].Value)
+		assert.Equal(t, 3.0, ss[1].Value)
+
+		ss, err = ms.Range(ctx, "counter", "test", metrics.Labels{"host": "a"}, from, time.Now())
+		assert.NoError(t, err)
+		assert.Len(t, ss, 1)
+		assert.Equal(t, 5.0, ss[0].Value)
+
+		ss, err = ms.Range(ctx, "gauge", "test", nil, time.Now().Add(time.Minute), time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Empty(t, ss)
+
+		_, err = ms.Range(ctx, "gauge", "none", nil, from, time.Now())
+		assert.ErrorIs(t, err, ErrMetricNotFound)
+	})
+}
+
+func TestHistory_Retention(t *testing.T) {
+	h := newHistory(time.Minute, 0)
+	now := time.Now()
+
+	h.record("gauge", "test", metrics.Gauge(1), now.Add(-2*time.Minute))
+	h.record("gauge", "test", metrics.Gauge(2), now.Add(-30*time.Second))
+	h.record("gauge", "test", metrics.Gauge(3), now)
+	h.record("histogram", "test", metrics.NewHistogram(nil), now)
+
+	ss := h.query("gauge", "test", time.Time{}, now)
+	assert.Equal(t, []Sample{
+		{Time: now.Add(-30 * time.Second), Value: 2},
+		{Time: now, Value: 3},
+	}, ss)
+
+	assert.Empty(t, h.query("histogram", "test", time.Time{}, now))
+}
+
+func TestHistory_Limit(t *testing.T) {
+	h := newHistory(time.Hour, 2)
+	now := time.Now()
+
+	for i := range 4 {
+		h.record("gauge", "test", metrics.Gauge(i), now.Add(time.Duration(i)*time.Second))
+	}
+
+	ss := h.query("gauge", "test", time.Time{}, now.Add(time.Minute))
+	assert.Equal(t, []Sample{
+		{Time: now.Add(2 * time.Second), Value: 2},
+		{Time: now.Add(3 * time.Second), Value: 3},
+	}, ss)
+
+	ss = h.query("gauge", "test", now.Add(3*time.Second), now.Add(time.Minute))
+	assert.Equal(t, []Sample{{Time: now.Add(3 * time.Second), Value: 3}}, ss)
+}
+
+func TestMemStorage_Expire(t *testing.T) {
+	ms := newMemStorage()
+	ms.h = newHistory(time.Hour, 0)
+	ctx := context.Background()
+
+	now := time.Now()
+
+	for _, m := range []metrics.Named{
+		{Name: "host_a", Metric: metrics.Gauge(1)},
+		{Name: "host_b", Metric: metrics.Gauge(2)},
+		{Name: "cpu", Metric: metrics.Counter(3)},
+	} {
+		err := ms.restore(m, now.Add(-time.Hour), "")
+		assert.NoError(t, err)
+	}
+
+	err := ms.Update(ctx, metrics.Named{Name: "host_b", Metric: metrics.Gauge(4)})
+	assert.NoError(t, err)
+
+	n, err := ms.expire(ctx, TTLRules{{Pattern: "host_*", TTL: time.Minute}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = ms.Get(ctx, "gauge", "host_a", nil)
+	assert.ErrorIs(t, err, ErrMetricNotFound)
+
+	_, err = ms.Range(ctx, "gauge", "host_a", nil, time.Time{}, now)
+	assert.ErrorIs(t, err, ErrMetricNotFound)
+
+	list, err := ms.List(ctx, nil)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+}
+
+func TestMemStorage_DeleteReset(t *testing.T) {
+	ms := newMemStorage()
+	ms.h = newHistory(time.Hour, 0)
+	ctx := context.Background()
+
+	h := metrics.NewHistogram([]float64{1, 2})
+	h.Observe(1.5)
+
+	for _, m := range []metrics.Named{
+		{Name: "load", Labels: metrics.Labels{"host": "a"}, Metric: metrics.Gauge(1)},
+		{Name: "load", Labels: metrics.Labels{"host": "b"}, Metric: metrics.Gauge(2)},
+		{Name: "requests", Metric: metrics.Counter(3)},
+		{Name: "latency", Metric: h},
+	} {
+		assert.NoError(t, ms.Update(ctx, m))
+	}
+
+	assert.NoError(t, ms.Delete(ctx, "gauge", "load", metrics.Labels{"host": "a"}))
+	assert.ErrorIs(t, ms.Delete(ctx, "gauge", "load", metrics.Labels{"host": "a"}), ErrMetricNotFound)
+	assert.ErrorIs(t, ms.Delete(ctx, "counter", "load", metrics.Labels{"host": "b"}), ErrMetricNotFound)
+
+	_, err := ms.Get(ctx, "gauge", "load", metrics.Labels{"host": "a"})
+	assert.ErrorIs(t, err, ErrMetricNotFound)
+
+	_, err = ms.Range(ctx, "gauge", "load", metrics.Labels{"host": "a"}, time.Time{}, time.Now())
+	assert.ErrorIs(t, err, ErrMetricNotFound)
+
+	m, err := ms.Get(ctx, "gauge", "load", metrics.Labels{"host": "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(2), m)
+
+	assert.NoError(t, ms.Reset(ctx, "counter", "requests", nil))
+	assert.NoError(t, ms.Reset(ctx, "histogram", "latency", nil))
+	assert.ErrorIs(t, ms.Reset(ctx, "counter", "nope", nil), ErrMetricNotFound)
+
+	m, err = ms.Get(ctx, "counter", "requests", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Counter(0), m)
+
+	assert.NoError(t, ms.Update(ctx, metrics.Named{Name: "requests", Metric: metrics.Counter(2)}))
+
+	m, err = ms.Get(ctx, "counter", "requests", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Counter(2), m)
+
+	m, err = ms.Get(ctx, "histogram", "latency", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.NewHistogram([]float64{1, 2}), m)
+
+	samples, err := ms.Range(ctx, "counter", "requests", nil, time.Time{}, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, samples, 3)
+	assert.Equal(t, 0.0, samples[1].Value)
+}
+
+func TestMemStorage_Describe(t *testing.T) {
+	ms := newMemStorage()
+	ctx := WithSource(context.Background(), "10.0.0.7")
+
+	before := time.Now()
+
+	assert.NoError(t, ms.Update(ctx, metrics.Named{Name: "load", Labels: metrics.Labels{"host": "a"}, Metric: metrics.Gauge(1)}))
+	assert.NoError(t, ms.Update(context.Background(), metrics.Named{Name: "requests", Metric: metrics.Counter(3)}))
+
+	s, err := ms.Describe(ctx, "gauge", "load", metrics.Labels{"host": "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.Gauge(1), s.Metric)
+	assert.Equal(t, "10.0.0.7", s.Source)
+	assert.False(t, s.Updated.Before(before))
+	assert.False(t, s.Stale(time.Hour, time.Now()))
+	assert.True(t, s.Stale(time.Hour, time.Now().Add(2*time.Hour)))
+	assert.False(t, s.Stale(0, time.Now().Add(2*time.Hour)))
+
+	_, err = ms.Describe(ctx, "counter", "load", metrics.Labels{"host": "a"})
+	assert.ErrorIs(t, err, ErrMetricNotFound)
+
+	ss, err := ms.ListSeries(ctx, metrics.Labels{"host": "a"})
+	assert.NoError(t, err)
+	assert.Len(t, ss, 1)
+
+	ss, err = ms.ListSeries(ctx, nil)
+	assert.NoError(t, err)
+	assert.Len(t, ss, 2)
+}
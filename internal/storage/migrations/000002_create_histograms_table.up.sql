@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS histograms(
+   name VARCHAR (50) PRIMARY KEY,
+   bounds FLOAT[] NOT NULL,
+   counts BIGINT[] NOT NULL,
+   sum FLOAT NOT NULL,
+   count BIGINT NOT NULL
+);
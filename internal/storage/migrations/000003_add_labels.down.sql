@@ -0,0 +1,18 @@
+BEGIN;
+
+DELETE FROM counters WHERE labels != '';
+ALTER TABLE counters DROP CONSTRAINT IF EXISTS counters_pkey;
+ALTER TABLE counters ADD PRIMARY KEY (name);
+ALTER TABLE counters DROP COLUMN IF EXISTS labels;
+
+DELETE FROM gauges WHERE labels != '';
+ALTER TABLE gauges DROP CONSTRAINT IF EXISTS gauges_pkey;
+ALTER TABLE gauges ADD PRIMARY KEY (name);
+ALTER TABLE gauges DROP COLUMN IF EXISTS labels;
+
+DELETE FROM histograms WHERE labels != '';
+ALTER TABLE histograms DROP CONSTRAINT IF EXISTS histograms_pkey;
+ALTER TABLE histograms ADD PRIMARY KEY (name);
+ALTER TABLE histograms DROP COLUMN IF EXISTS labels;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+ALTER TABLE counters ADD COLUMN IF NOT EXISTS labels TEXT NOT NULL DEFAULT '';
+ALTER TABLE counters DROP CONSTRAINT IF EXISTS counters_pkey;
+ALTER TABLE counters ADD PRIMARY KEY (name, labels);
+
+ALTER TABLE gauges ADD COLUMN IF NOT EXISTS labels TEXT NOT NULL DEFAULT '';
+ALTER TABLE gauges DROP CONSTRAINT IF EXISTS gauges_pkey;
+ALTER TABLE gauges ADD PRIMARY KEY (name, labels);
+
+ALTER TABLE histograms ADD COLUMN IF NOT EXISTS labels TEXT NOT NULL DEFAULT '';
+ALTER TABLE histograms DROP CONSTRAINT IF EXISTS histograms_pkey;
+ALTER TABLE histograms ADD PRIMARY KEY (name, labels);
+
+COMMIT;
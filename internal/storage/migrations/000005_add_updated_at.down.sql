@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE counters DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE gauges DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE histograms DROP COLUMN IF EXISTS updated_at;
+
+COMMIT;
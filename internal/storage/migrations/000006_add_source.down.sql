@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE counters DROP COLUMN IF EXISTS source;
+ALTER TABLE gauges DROP COLUMN IF EXISTS source;
+ALTER TABLE histograms DROP COLUMN IF EXISTS source;
+
+COMMIT;
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// Series is a stored series along with the time it was last written and the
+// address of the client that wrote it, if known.
+type Series struct {
+	metrics.Named
+	Updated time.Time
+	Source  string
+}
+
+// Stale reports whether the series has not been written to for longer than
+// the interval. Series never go stale if the interval is not positive.
+func (s Series) Stale(interval time.Duration, now time.Time) bool {
+	return interval > 0 && now.Sub(s.Updated) > interval
+}
+
+type sourceKey struct{}
+
+// WithSource returns a copy of the context that carries the address of the
+// client the updates come from.
+func WithSource(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, addr)
+}
+
+// Source returns the address of the client stored in the context, if any.
+func Source(ctx context.Context) string {
+	s, _ := ctx.Value(sourceKey{}).(string)
+	return s
+}
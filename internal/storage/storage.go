@@ -13,11 +13,23 @@ import (
 // ErrMetricNotFound is returned when metric is not found.
 var ErrMetricNotFound = fmt.Errorf("metric not found")
 
-// Storage provides a metric storage.
+// Storage provides a metric storage. A series is identified by its type, name
+// and labels.
 type Storage interface {
-	Get(ctx context.Context, t, name string) (metrics.Metric, error)
+	Get(ctx context.Context, t, name string, labels metrics.Labels) (metrics.Metric, error)
 	Update(context.Context, metrics.Named) error
-	List(context.Context) ([]metrics.Named, error)
+	// List returns all the series that have the labels in the filter.
+	List(ctx context.Context, filter metrics.Labels) ([]metrics.Named, error)
+	// Describe returns the series along with when and by whom it was last
+	// written. The client is taken from the context of the updates, see
+	// WithSource.
+	Describe(ctx context.Context, t, name string, labels metrics.Labels) (Series, error)
+	// ListSeries is like List, but describes the series.
+	ListSeries(ctx context.Context, filter metrics.Labels) ([]Series, error)
+	// Delete deletes the series.
+	Delete(ctx context.Context, t, name string, labels metrics.Labels) error
+	// Reset sets the series to the zero value of its type.
+	Reset(ctx context.Context, t, name string, labels metrics.Labels) error
 	Close()
 }
 
@@ -25,11 +37,19 @@ type Storage interface {
 func New(log *zap.SugaredLogger, cfg Config) (Storage, error) {
 	if cfg.DatabaseDSN != "" {
 		log.Info("using database for storage")
-		return newDB(cfg.DatabaseDSN)
+		db, err := newDB(cfg.DatabaseDSN, cfg.History)
+		if err != nil {
+			return nil, err
+		}
+		db.r = startReaper(log, db, cfg.TTL)
+		return db, nil
 	}
 
 	if cfg.InMemory {
-		return newMemStorage(), nil
+		ms := newMemStorage()
+		ms.h = newHistory(cfg.History, cfg.HistorySamples)
+		ms.r = startReaper(log, ms, cfg.TTL)
+		return ms, nil
 	}
 
 	return newFileStorage(context.TODO(), log, cfg), nil
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS samples(
+   type VARCHAR (20) NOT NULL,
+   name VARCHAR (50) NOT NULL,
+   labels TEXT NOT NULL DEFAULT '',
+   ts TIMESTAMPTZ NOT NULL,
+   value FLOAT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS samples_series_ts_idx ON samples(type, name, labels, ts);
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE counters ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT '';
+ALTER TABLE gauges ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT '';
+ALTER TABLE histograms ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT '';
+
+COMMIT;
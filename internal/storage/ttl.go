@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	minReapInterval = time.Second
+	maxReapInterval = time.Minute
+)
+
+// TTLRule sets the time to live for the series of the type and with the name
+// that matches the pattern (see path.Match). Empty type or pattern matches any
+// series.
+type TTLRule struct {
+	Type    string
+	Pattern string
+	TTL     time.Duration
+}
+
+// TTLRules is a list of TTL rules. The first rule that matches a series
+// applies; the series that match no rule never expire.
+type TTLRules []TTLRule
+
+// ParseTTLRules parses a comma-separated list of rules, each in the form of
+// "selector=duration", where selector is either "type:<type>",
+// "name:<pattern>" or "*", e.g. "name:host_*=10m,type:gauge=1h,*=24h".
+func ParseTTLRules(s string) (TTLRules, error) {
+	var rr TTLRules
+
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+
+		sel, d, ok := strings.Cut(r, "=")
+		if !ok {
+			return nil, fmt.Errorf("bad TTL rule %q: no duration", r)
+		}
+
+		ttl, err := time.ParseDuration(d)
+		if err != nil {
+			return nil, fmt.Errorf("bad TTL rule %q: %w", r, err)
+		}
+
+		if ttl <= 0 {
+			return nil, fmt.Errorf("bad TTL rule %q: TTL must be positive", r)
+		}
+
+		rule := TTLRule{TTL: ttl}
+
+		switch k, v, _ := strings.Cut(sel, ":"); k {
+		case "*":
+		case "type":
+			rule.Type = v
+		case "name":
+			if _, err := path.Match(v, ""); err != nil {
+				return nil, fmt.Errorf("bad TTL rule %q: %w", r, err)
+			}
+			rule.Pattern = v
+		default:
+			return nil, fmt.Errorf("bad TTL rule %q: unknown selector", r)
+		}
+
+		rr = append(rr, rule)
+	}
+
+	return rr, nil
+}
+
+// For returns the TTL of the series, or zero if the series never expires.
+func (rr TTLRules) For(t, name string) time.Duration {
+	for _, r := range rr {
+		if r.Type != "" && r.Type != t {
+			continue
+		}
+
+		if r.Pattern != "" {
+			if ok, _ := path.Match(r.Pattern, name); !ok {
+				continue
+			}
+		}
+
+		return r.TTL
+	}
+
+	return 0
+}
+
+// Expired reports whether the series that was last written age ago is
+// expired.
+func (rr TTLRules) Expired(t, name string, age time.Duration) bool {
+	ttl := rr.For(t, name)
+	return ttl > 0 && age > ttl
+}
+
+// reapInterval returns how often the expired series should be looked for.
+func (rr TTLRules) reapInterval() time.Duration {
+	interval := maxReapInterval
+
+	for _, r := range rr {
+		interval = min(interval, r.TTL/2)
+	}
+
+	return max(interval, minReapInterval)
+}
+
+// expirer is a storage that can delete its expired series.
+type expirer interface {
+	expire(ctx context.Context, rules TTLRules) (int, error)
+}
+
+// reaper periodically deletes the expired series.
+type reaper struct {
+	stopChan, doneChan chan struct{}
+}
+
+// startReaper starts deleting the series that expire according to the rules,
+// right away and then periodically. It returns nil if there are no rules.
+func startReaper(log *zap.SugaredLogger, e expirer, rules TTLRules) *reaper {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	r := &reaper{
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+
+	reap := func() {
+		n, err := e.expire(context.Background(), rules)
+		if err != nil {
+			log.Errorf("failed to delete expired metrics: %s", err)
+		}
+		if n > 0 {
+			log.Infof("deleted %d expired metrics", n)
+		}
+	}
+
+	go func() {
+		defer close(r.doneChan)
+
+		ticker := time.NewTicker(rules.reapInterval())
+		defer ticker.Stop()
+
+		reap()
+
+		for {
+			select {
+			case <-r.stopChan:
+				return
+			case <-ticker.C:
+				reap()
+			}
+		}
+	}()
+
+	return r
+}
+
+// stop stops the reaper and waits for it to finish.
+func (r *reaper) stop() {
+	if r == nil {
+		return
+	}
+
+	close(r.stopChan)
+	<-r.doneChan
+}
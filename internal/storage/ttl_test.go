@@ -0,0 +1,65 @@
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+func TestParseTTLRules(t *testing.T) {
+	t.Parallel()
+
+	rules, err := storage.ParseTTLRules("name:host_*=10m, type:gauge=1h,*=24h")
+	require.NoError(t, err)
+	assert.Equal(t, storage.TTLRules{
+		{Pattern: "host_*", TTL: 10 * time.Minute},
+		{Type: "gauge", TTL: time.Hour},
+		{TTL: 24 * time.Hour},
+	}, rules)
+
+	rules, err = storage.ParseTTLRules("")
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+
+	for _, s := range []string{
+		"type:gauge",
+		"type:gauge=soon",
+		"type:gauge=-1h",
+		"host:a=1h",
+		"name:[=1h",
+	} {
+		_, err := storage.ParseTTLRules(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestTTLRules_For(t *testing.T) {
+	t.Parallel()
+
+	rules := storage.TTLRules{
+		{Pattern: "host_*", TTL: 10 * time.Minute},
+		{Type: "gauge", TTL: time.Hour},
+	}
+
+	tests := []struct {
+		t, name string
+		want    time.Duration
+	}{
+		{"gauge", "host_a", 10 * time.Minute},
+		{"counter", "host_a", 10 * time.Minute},
+		{"gauge", "cpu", time.Hour},
+		{"counter", "cpu", 0},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, rules.For(tt.t, tt.name), tt.t+" "+tt.name)
+	}
+
+	assert.True(t, rules.Expired("gauge", "cpu", 2*time.Hour))
+	assert.False(t, rules.Expired("gauge", "cpu", time.Minute))
+	assert.False(t, rules.Expired("counter", "cpu", 1000*time.Hour))
+}
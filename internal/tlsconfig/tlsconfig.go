@@ -0,0 +1,104 @@
+// Package tlsconfig builds TLS configurations for the servers and clients.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Files are the PEM-encoded files to set up TLS with.
+type Files struct {
+	// Cert and Key are the certificate to present and its private key.
+	Cert string
+	Key  string
+	// CA is the certificate authority to verify the other side against. For
+	// a server it enables client certificate authentication, for a client it
+	// replaces the system roots.
+	CA string
+}
+
+// Enabled reports whether any of the files are set.
+func (f Files) Enabled() bool {
+	return f.Cert != "" || f.Key != "" || f.CA != ""
+}
+
+// Server returns the TLS configuration for a server, or nil if no files are
+// set. If the CA is set, the clients are required to present a certificate
+// signed by it.
+func (f Files) Server() (*tls.Config, error) {
+	if !f.Enabled() {
+		return nil, nil
+	}
+
+	if f.Cert == "" || f.Key == "" {
+		return nil, errors.New("both certificate and key are needed")
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.Cert, f.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if f.CA != "" {
+		cfg.ClientCAs, err = loadPool(f.CA)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// Client returns the TLS configuration for a client. If the CA is not set,
+// the system roots are used. If the certificate and key are set, they are
+// presented to the server.
+func (f Files) Client() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if (f.Cert == "") != (f.Key == "") {
+		return nil, errors.New("both certificate and key are needed")
+	}
+
+	if f.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(f.Cert, f.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if f.CA != "" {
+		var err error
+		cfg.RootCAs, err = loadPool(f.CA)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+func loadPool(fileName string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", fileName)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,184 @@
+package tlsconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nekr0z/muhame/internal/tlsconfig"
+)
+
+func TestFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := newCA(t, dir)
+	server := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	client := ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+
+	tests := []struct {
+		name   string
+		server tlsconfig.Files
+		client tlsconfig.Files
+		ok     bool
+	}{
+		{
+			name:   "tls",
+			server: server,
+			client: tlsconfig.Files{CA: ca.file},
+			ok:     true,
+		},
+		{
+			name:   "mtls",
+			server: tlsconfig.Files{Cert: server.Cert, Key: server.Key, CA: ca.file},
+			client: tlsconfig.Files{Cert: client.Cert, Key: client.Key, CA: ca.file},
+			ok:     true,
+		},
+		{
+			name:   "no client certificate",
+			server: tlsconfig.Files{Cert: server.Cert, Key: server.Key, CA: ca.file},
+			client: tlsconfig.Files{CA: ca.file},
+		},
+		{
+			name:   "unknown server",
+			server: server,
+			client: tlsconfig.Files{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srvCfg, err := tt.server.Server()
+			require.NoError(t, err)
+
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			srv.TLS = srvCfg
+			srv.StartTLS()
+			defer srv.Close()
+
+			clCfg, err := tt.client.Client()
+			require.NoError(t, err)
+
+			cl := &http.Client{Transport: &http.Transport{TLSClientConfig: clCfg}}
+
+			resp, err := cl.Get(srv.URL)
+			if !tt.ok {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
+
+func TestFiles_Errors(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := tlsconfig.Files{}.Server()
+	assert.NoError(t, err)
+	assert.Nil(t, cfg)
+
+	_, err = tlsconfig.Files{CA: "ca.pem"}.Server()
+	assert.Error(t, err)
+
+	_, err = tlsconfig.Files{Cert: "cert.pem"}.Client()
+	assert.Error(t, err)
+
+	_, err = tlsconfig.Files{CA: filepath.Join(t.TempDir(), "missing.pem")}.Client()
+	assert.Error(t, err)
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newCA(t *testing.T, dir string) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	file := filepath.Join(dir, "ca.pem")
+	writePEM(t, file, "CERTIFICATE", der)
+
+	return testCA{cert: cert, key: key, file: file}
+}
+
+func (ca testCA) issue(t *testing.T, dir, name string, usage x509.ExtKeyUsage) tlsconfig.Files {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:     []string{"localhost"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	f := tlsconfig.Files{
+		Cert: filepath.Join(dir, name+".pem"),
+		Key:  filepath.Join(dir, name+".key"),
+	}
+
+	writePEM(t, f.Cert, "CERTIFICATE", der)
+	writePEM(t, f.Key, "EC PRIVATE KEY", keyDER)
+
+	return f
+}
+
+func writePEM(t *testing.T, fileName, blockType string, b []byte) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(fileName, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: b}), 0600))
+}
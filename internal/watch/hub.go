@@ -0,0 +1,165 @@
+// Package watch implements live notifications of the metric updates.
+package watch
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+)
+
+// DefaultBuffer is used when no buffer size is set.
+const DefaultBuffer = 100
+
+// Event is an update of a series. The metric is the value of the series after
+// the update.
+type Event struct {
+	metrics.Named
+	Time time.Time
+}
+
+// Filter selects the series to watch. Empty fields match everything.
+type Filter struct {
+	NamePrefix string
+	Type       string
+}
+
+// Matches reports whether the series of the metric is selected by the filter.
+func (f Filter) Matches(m metrics.Named) bool {
+	if f.Type != "" && m.Type() != f.Type {
+		return false
+	}
+
+	return strings.HasPrefix(m.Name, f.NamePrefix)
+}
+
+// Hub fans the events out to the subscribers. Publishing never blocks: the
+// events that do not fit into the buffer of a slow subscriber are dropped for
+// that subscriber and counted.
+type Hub struct {
+	buffer int
+
+	mu     sync.RWMutex
+	closed bool
+	subs   map[*Subscription]struct{}
+}
+
+// Subscription receives the events that match its filter.
+type Subscription struct {
+	hub     *Hub
+	filter  Filter
+	ch      chan Event
+	dropped atomic.Uint64
+}
+
+// NewHub returns a new Hub with the given buffer size per subscriber.
+func NewHub(buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+
+	return &Hub{
+		buffer: buffer,
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe returns a new subscription. The subscription must be closed when
+// no longer needed.
+func (h *Hub) Subscribe(f Filter) *Subscription {
+	s := &Subscription{
+		hub:    h,
+		filter: f,
+		ch:     make(chan Event, h.buffer),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(s.ch)
+		return s
+	}
+
+	h.subs[s] = struct{}{}
+
+	return s
+}
+
+// Wants reports whether any of the subscribers is interested in the series of
+// the metric.
+func (h *Hub) Wants(m metrics.Named) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for s := range h.subs {
+		if s.filter.Matches(m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Publish sends the events to the subscribers interested in them.
+func (h *Hub) Publish(ee ...Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for s := range h.subs {
+		for _, e := range ee {
+			if !s.filter.Matches(e.Named) {
+				continue
+			}
+
+			select {
+			case s.ch <- e:
+			default:
+				s.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// Close ends all the subscriptions, the ones made later end at once.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+
+	for s := range h.subs {
+		close(s.ch)
+		delete(h.subs, s)
+	}
+}
+
+// Events returns the channel the events are received from. The channel is
+// closed when the subscription or the hub is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns the number of events dropped because the subscriber was too
+// slow to receive them.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	if _, ok := s.hub.subs[s]; !ok {
+		return
+	}
+
+	delete(s.hub.subs, s)
+	close(s.ch)
+}
@@ -0,0 +1,115 @@
+package watch_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+	"github.com/nekr0z/muhame/internal/watch"
+)
+
+func TestFilter_Matches(t *testing.T) {
+	load := metrics.Named{Name: "load", Metric: metrics.Gauge(1)}
+
+	assert.True(t, watch.Filter{}.Matches(load))
+	assert.True(t, watch.Filter{NamePrefix: "lo"}.Matches(load))
+	assert.True(t, watch.Filter{NamePrefix: "lo", Type: "gauge"}.Matches(load))
+	assert.False(t, watch.Filter{NamePrefix: "loads"}.Matches(load))
+	assert.False(t, watch.Filter{Type: "counter"}.Matches(load))
+}
+
+func TestHub(t *testing.T) {
+	h := watch.NewHub(2)
+
+	all := h.Subscribe(watch.Filter{})
+	counters := h.Subscribe(watch.Filter{Type: "counter"})
+
+	load := metrics.Named{Name: "load", Metric: metrics.Gauge(1)}
+	assert.True(t, h.Wants(load))
+
+	counters.Close()
+	counters.Close()
+
+	_, ok := <-counters.Events()
+	assert.False(t, ok)
+
+	// the publisher is never blocked by a slow subscriber
+	for range 5 {
+		h.Publish(watch.Event{Named: load})
+	}
+
+	assert.Len(t, all.Events(), 2)
+	assert.Equal(t, uint64(3), all.Dropped())
+
+	all.Close()
+	assert.False(t, h.Wants(load))
+
+	sub := h.Subscribe(watch.Filter{})
+	h.Close()
+
+	_, ok = <-sub.Events()
+	assert.False(t, ok)
+
+	_, ok = <-h.Subscribe(watch.Filter{}).Events()
+	assert.False(t, ok)
+}
+
+func TestWrap(t *testing.T) {
+	ctx := context.Background()
+
+	ms, err := storage.New(zap.NewNop().Sugar(), storage.Config{InMemory: true})
+	require.NoError(t, err)
+
+	h := watch.NewHub(0)
+	st := watch.Wrap(ms, h)
+	defer st.Close()
+
+	sub := h.Subscribe(watch.Filter{NamePrefix: "req"})
+	defer sub.Close()
+
+	gauges := h.Subscribe(watch.Filter{Type: "gauge"})
+	defer gauges.Close()
+
+	// gauges are published as written, without reading them back
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "load", Metric: metrics.Gauge(2)}))
+	select {
+	case e := <-gauges.Events():
+		assert.Equal(t, metrics.Gauge(2), e.Metric)
+	default:
+		t.Fatal("no event for the gauge")
+	}
+
+	// counters are published with the totals read back
+	next := func() watch.Event {
+		t.Helper()
+
+		select {
+		case e := <-sub.Events():
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+			return watch.Event{}
+		}
+	}
+
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "requests", Metric: metrics.Counter(2)}))
+	e := next()
+	assert.Equal(t, "requests", e.Name)
+	assert.Equal(t, metrics.Counter(2), e.Metric)
+	assert.WithinDuration(t, time.Now(), e.Time, time.Minute)
+
+	require.NoError(t, st.Update(ctx, metrics.Named{Name: "requests", Metric: metrics.Counter(3)}))
+	assert.Equal(t, metrics.Counter(5), next().Metric)
+
+	require.NoError(t, st.Reset(ctx, "counter", "requests", nil))
+	assert.Equal(t, metrics.Counter(0), next().Metric)
+
+	assert.Empty(t, sub.Events())
+	assert.Empty(t, gauges.Events())
+}
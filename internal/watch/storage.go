@@ -0,0 +1,220 @@
+package watch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nekr0z/muhame/internal/metrics"
+	"github.com/nekr0z/muhame/internal/storage"
+)
+
+// Wrap returns the storage that publishes every accepted update to the hub.
+// Bulk updates and pings are supported if the storage supports them.
+func Wrap(st storage.Storage, h *Hub) storage.Storage {
+	ws := &watched{
+		Storage: st,
+		h:       h,
+		pending: make(map[string]read),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go ws.readLoop()
+
+	bu, isBulk := st.(bulkUpdater)
+	p, isPing := st.(pingable)
+
+	switch {
+	case isBulk && isPing:
+		return struct {
+			*watched
+			bulkWatched
+			pinger
+		}{ws, bulkWatched{ws: ws, bu: bu}, pinger{p}}
+	case isBulk:
+		return struct {
+			*watched
+			bulkWatched
+		}{ws, bulkWatched{ws: ws, bu: bu}}
+	case isPing:
+		return struct {
+			*watched
+			pinger
+		}{ws, pinger{p}}
+	default:
+		return ws
+	}
+}
+
+type watched struct {
+	storage.Storage
+	h *Hub
+
+	mu      sync.Mutex
+	pending map[string]read
+
+	wake, stop, done chan struct{}
+}
+
+// read is a series to read the value of after an update.
+type read struct {
+	t, name string
+	labels  metrics.Labels
+	time    time.Time
+}
+
+// Update implements storage.Storage.
+func (s *watched) Update(ctx context.Context, m metrics.Named) error {
+	if err := s.Storage.Update(ctx, m); err != nil {
+		return err
+	}
+
+	s.publish(m)
+
+	return nil
+}
+
+// Reset implements storage.Storage.
+func (s *watched) Reset(ctx context.Context, t, name string, labels metrics.Labels) error {
+	if err := s.Storage.Reset(ctx, t, name, labels); err != nil {
+		return err
+	}
+
+	s.enqueue(read{t: t, name: name, labels: labels, time: time.Now()})
+
+	return nil
+}
+
+// Close implements storage.Storage.
+func (s *watched) Close() {
+	close(s.stop)
+	<-s.done
+
+	s.Storage.Close()
+}
+
+// Range queries the history of the underlying storage.
+func (s *watched) Range(ctx context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error) {
+	rq, ok := s.Storage.(rangeQuerier)
+	if !ok {
+		return nil, storage.ErrHistoryDisabled
+	}
+
+	return rq.Range(ctx, t, name, labels, from, to)
+}
+
+// publish publishes the values of the series of the metrics that anyone
+// watches. Gauges are published as written, while the totals of counters and
+// histograms are read from the storage in the background, so that the update
+// does not wait for the read.
+func (s *watched) publish(mm ...metrics.Named) {
+	now := time.Now()
+
+	var ee []Event
+
+	for _, m := range mm {
+		if !s.h.Wants(m) {
+			continue
+		}
+
+		if _, ok := m.Metric.(metrics.Gauge); ok {
+			ee = append(ee, Event{Named: m, Time: now})
+			continue
+		}
+
+		s.enqueue(read{t: m.Type(), name: m.Name, labels: m.Labels, time: now})
+	}
+
+	if len(ee) > 0 {
+		s.h.Publish(ee...)
+	}
+}
+
+// enqueue schedules the series to be read and published. Several updates of
+// the same series that happen before it is read are published once.
+func (s *watched) enqueue(r read) {
+	s.mu.Lock()
+	s.pending[r.t+"\x00"+r.name+"\x00"+r.labels.String()] = r
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// readLoop reads and publishes the values of the pending series until the
+// storage is closed.
+func (s *watched) readLoop() {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		}
+
+		s.mu.Lock()
+		pending := s.pending
+		s.pending = make(map[string]read)
+		s.mu.Unlock()
+
+		var ee []Event
+
+		for _, r := range pending {
+			m, err := s.Storage.Get(context.Background(), r.t, r.name, r.labels)
+			if err != nil {
+				continue
+			}
+
+			ee = append(ee, Event{
+				Named: metrics.Named{Name: r.name, Labels: r.labels, Metric: m},
+				Time:  r.time,
+			})
+		}
+
+		if len(ee) > 0 {
+			s.h.Publish(ee...)
+		}
+	}
+}
+
+type bulkWatched struct {
+	ws *watched
+	bu bulkUpdater
+}
+
+// BulkUpdate updates the metrics in the underlying storage.
+func (s bulkWatched) BulkUpdate(ctx context.Context, mm []metrics.Named) error {
+	if err := s.bu.BulkUpdate(ctx, mm); err != nil {
+		return err
+	}
+
+	s.ws.publish(mm...)
+
+	return nil
+}
+
+type pinger struct {
+	p pingable
+}
+
+// Ping pings the underlying storage.
+func (p pinger) Ping(ctx context.Context) error {
+	return p.p.Ping(ctx)
+}
+
+type bulkUpdater interface {
+	BulkUpdate(context.Context, []metrics.Named) error
+}
+
+type pingable interface {
+	Ping(context.Context) error
+}
+
+type rangeQuerier interface {
+	Range(ctx context.Context, t, name string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error)
+}